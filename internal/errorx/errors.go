@@ -12,14 +12,28 @@ import (
 // Logic functions return these so the global error handler can map
 // them to the correct HTTP response.
 type CodeError struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Details any    `json:"details,omitempty"`
 }
 
 func (e *CodeError) Error() string {
 	return e.Msg
 }
 
+// CodeErrorResponse is the JSON body written for a CodeError. It is not an
+// error itself, because httpx writes error bodies as plain text.
+type CodeErrorResponse struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Details any    `json:"details,omitempty"`
+}
+
+// Data returns the response body for the error.
+func (e *CodeError) Data() *CodeErrorResponse {
+	return &CodeErrorResponse{Code: e.Code, Msg: e.Msg, Details: e.Details}
+}
+
 // ErrNotFound returns a 404 error.
 func ErrNotFound(msg string) error {
 	return &CodeError{Code: http.StatusNotFound, Msg: msg}
@@ -30,6 +44,16 @@ func ErrBadRequest(msg string) error {
 	return &CodeError{Code: http.StatusBadRequest, Msg: msg}
 }
 
+// ErrBadRequestWithDetails returns a 400 error carrying structured details.
+func ErrBadRequestWithDetails(msg string, details any) error {
+	return &CodeError{Code: http.StatusBadRequest, Msg: msg, Details: details}
+}
+
+// ErrConflict returns a 409 error.
+func ErrConflict(msg string) error {
+	return &CodeError{Code: http.StatusConflict, Msg: msg}
+}
+
 // ErrInternal returns a 500 error.
 func ErrInternal(msg string) error {
 	return &CodeError{Code: http.StatusInternalServerError, Msg: msg}
@@ -41,10 +65,10 @@ func RegisterErrorHandler() {
 	httpx.SetErrorHandlerCtx(func(ctx context.Context, err error) (int, any) {
 		switch e := err.(type) {
 		case *CodeError:
-			return e.Code, &CodeError{Code: e.Code, Msg: e.Msg}
+			return e.Code, e.Data()
 		default:
 			logx.WithContext(ctx).Errorf("unexpected error: %v", err)
-			return http.StatusInternalServerError, &CodeError{
+			return http.StatusInternalServerError, &CodeErrorResponse{
 				Code: http.StatusInternalServerError,
 				Msg:  "internal server error",
 			}
@@ -8,7 +8,9 @@ import (
 
 	email "github.com/joeblew999/plat-mjml/internal/handler/email"
 	stats "github.com/joeblew999/plat-mjml/internal/handler/stats"
+	suppression "github.com/joeblew999/plat-mjml/internal/handler/suppression"
 	template "github.com/joeblew999/plat-mjml/internal/handler/template"
+	webhook "github.com/joeblew999/plat-mjml/internal/handler/webhook"
 	"github.com/joeblew999/plat-mjml/internal/svc"
 
 	"github.com/zeromicro/go-zero/rest"
@@ -17,6 +19,26 @@ import (
 func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 	server.AddRoutes(
 		[]rest.Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/deadletters",
+				Handler: email.ListDeadLettersHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/deadletters/:id",
+				Handler: email.GetDeadLetterHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/deadletters/purge",
+				Handler: email.PurgeDeadLettersHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/deadletters/requeue",
+				Handler: email.RequeueDeadLettersHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodPost,
 				Path:    "/emails",
@@ -32,6 +54,26 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/emails/:id",
 				Handler: email.GetEmailStatusHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/emails/:id/cancel",
+				Handler: email.CancelEmailHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/emails/:id/events",
+				Handler: email.GetEmailEventsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/emails/:id/reschedule",
+				Handler: email.RescheduleEmailHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/emails/:id/retry",
+				Handler: email.RetryEmailHandler(serverCtx),
+			},
 		},
 		rest.WithPrefix("/api/v1"),
 	)
@@ -47,6 +89,37 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 		rest.WithPrefix("/api/v1"),
 	)
 
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/suppressions",
+				Handler: suppression.ListSuppressionsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/suppressions",
+				Handler: suppression.CreateSuppressionHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/suppressions/:address",
+				Handler: suppression.DeleteSuppressionHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/suppressions/export",
+				Handler: suppression.ExportSuppressionsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/suppressions/import",
+				Handler: suppression.ImportSuppressionsHandler(serverCtx),
+			},
+		},
+		rest.WithPrefix("/api/v1"),
+	)
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
@@ -54,16 +127,77 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/templates",
 				Handler: template.ListTemplatesHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/templates",
+				Handler: template.CreateTemplateHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/templates/:slug",
 				Handler: template.GetTemplateHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPut,
+				Path:    "/templates/:slug",
+				Handler: template.UpdateTemplateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodDelete,
+				Path:    "/templates/:slug",
+				Handler: template.DeleteTemplateHandler(serverCtx),
+			},
 			{
 				Method:  http.MethodGet,
 				Path:    "/templates/:slug/render",
 				Handler: template.RenderTemplateHandler(serverCtx),
 			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/templates/:slug/render",
+				Handler: template.PreviewTemplateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/templates/validate",
+				Handler: template.ValidateTemplateHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/templates/:slug/versions",
+				Handler: template.ListTemplateVersionsHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/templates/:slug/versions/:version/publish",
+				Handler: template.PublishTemplateVersionHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/templates/:slug/rollback",
+				Handler: template.RollbackTemplateHandler(serverCtx),
+			},
+		},
+		rest.WithPrefix("/api/v1"),
+	)
+
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				Method:  http.MethodGet,
+				Path:    "/webhooks",
+				Handler: webhook.ListWebhooksHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodGet,
+				Path:    "/webhooks/deliveries",
+				Handler: webhook.ListWebhookDeliveriesHandler(serverCtx),
+			},
+			{
+				Method:  http.MethodPost,
+				Path:    "/webhooks/deliveries/:id/replay",
+				Handler: webhook.ReplayWebhookDeliveryHandler(serverCtx),
+			},
 		},
 		rest.WithPrefix("/api/v1"),
 	)
@@ -0,0 +1,27 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"net/http"
+
+	"github.com/joeblew999/plat-mjml/internal/logic/suppression"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func ExportSuppressionsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := suppression.NewExportSuppressionsLogic(r.Context(), svcCtx)
+		data, err := l.ExportSuppressions()
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+		w.Write(data)
+	}
+}
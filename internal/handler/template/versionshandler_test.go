@@ -0,0 +1,137 @@
+package template
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/zeromicro/go-zero/rest/router"
+)
+
+const testContent = `<mjml>
+	<mj-body>
+		<mj-section>
+			<mj-column>
+				<mj-text>%s {{.Name}}</mj-text>
+			</mj-column>
+		</mj-section>
+	</mj-body>
+</mjml>`
+
+func content(greeting string) string {
+	return fmt.Sprintf(testContent, greeting)
+}
+
+func newTestRouter(t *testing.T) (http.Handler, *svc.ServiceContext) {
+	t.Helper()
+	errorx.RegisterErrorHandler()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	renderer := mjml.NewRenderer(mjml.WithFonts(false))
+	svcCtx := svc.NewServiceContext(renderer, nil, tmplstore.NewStore(database.DB, renderer), nil)
+
+	rt := router.NewRouter()
+	for _, route := range []struct {
+		method, path string
+		handler      http.HandlerFunc
+	}{
+		{http.MethodGet, "/api/v1/templates/:slug/versions", ListTemplateVersionsHandler(svcCtx)},
+		{http.MethodPost, "/api/v1/templates/:slug/versions/:version/publish", PublishTemplateVersionHandler(svcCtx)},
+		{http.MethodPost, "/api/v1/templates/:slug/rollback", RollbackTemplateHandler(svcCtx)},
+	} {
+		if err := rt.Handle(route.method, route.path, route.handler); err != nil {
+			t.Fatalf("Failed to add route %s: %v", route.path, err)
+		}
+	}
+	return rt, svcCtx
+}
+
+func serve(t *testing.T, h http.Handler, method, path, body string, out any) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("Failed to decode %s %s response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+	return rec.Code
+}
+
+func TestTemplateVersionHandlers(t *testing.T) {
+	h, svcCtx := newTestRouter(t)
+	ctx := context.Background()
+
+	if err := svcCtx.Templates.Create(ctx, &tmplstore.Template{Slug: "greeting", Content: content("Hello")}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svcCtx.Templates.SaveDraft(ctx, "greeting", content("Howdy"), ""); err != nil {
+		t.Fatalf("SaveDraft failed: %v", err)
+	}
+
+	var list types.ListTemplateVersionsResponse
+	if code := serve(t, h, http.MethodGet, "/api/v1/templates/greeting/versions", "", &list); code != http.StatusOK {
+		t.Fatalf("Expected 200 listing versions, got %d", code)
+	}
+	if list.Count != 2 || list.Versions[0].Version != 2 || list.Versions[0].Live || !list.Versions[1].Live {
+		t.Fatalf("Expected v2 draft above live v1, got %+v", list.Versions)
+	}
+
+	var published types.GetTemplateResponse
+	if code := serve(t, h, http.MethodPost, "/api/v1/templates/greeting/versions/2/publish", "", &published); code != http.StatusOK {
+		t.Fatalf("Expected 200 publishing v2, got %d", code)
+	}
+	if published.Version != 2 || !strings.Contains(published.Content, "Howdy") {
+		t.Errorf("Expected v2 to be live, got v%d", published.Version)
+	}
+	if html, err := svcCtx.Renderer.RenderTemplate("greeting", map[string]any{"Name": "Ada"}); err != nil || !strings.Contains(html, "Howdy Ada") {
+		t.Errorf("Expected the renderer to serve v2, got %v", err)
+	}
+
+	var restored types.GetTemplateResponse
+	if code := serve(t, h, http.MethodPost, "/api/v1/templates/greeting/rollback", `{"version": 1}`, &restored); code != http.StatusOK {
+		t.Fatalf("Expected 200 rolling back to v1, got %d", code)
+	}
+	if restored.Version != 3 || restored.LatestVersion != 3 || !strings.Contains(restored.Content, "Hello") {
+		t.Errorf("Expected v1 restored as v3, got v%d of %d", restored.Version, restored.LatestVersion)
+	}
+	if html, err := svcCtx.Renderer.RenderTemplate("greeting", map[string]any{"Name": "Ada"}); err != nil || !strings.Contains(html, "Hello Ada") {
+		t.Errorf("Expected the renderer to serve the restored content, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/api/v1/templates/missing/versions", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/templates/greeting/versions/9/publish", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/templates/missing/versions/1/publish", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/templates/greeting/rollback", `{"version": 9}`, http.StatusNotFound},
+	} {
+		if code := serve(t, h, tc.method, tc.path, tc.body, nil); code != tc.code {
+			t.Errorf("%s %s %s: expected %d, got %d", tc.method, tc.path, tc.body, tc.code, code)
+		}
+	}
+}
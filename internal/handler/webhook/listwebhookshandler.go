@@ -0,0 +1,24 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/joeblew999/plat-mjml/internal/logic/webhook"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func ListWebhooksHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := webhook.NewListWebhooksLogic(r.Context(), svcCtx)
+		resp, err := l.ListWebhooks()
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
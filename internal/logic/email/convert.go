@@ -0,0 +1,167 @@
+package email
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/mail"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+func emailResponse(job *queue.EmailJob) types.GetEmailStatusResponse {
+	resp := types.GetEmailStatusResponse{
+		Id:              job.ID,
+		Template:        job.TemplateSlug,
+		TemplateVersion: job.TemplateVersion,
+		Recipients:      job.Recipients,
+		Cc:              job.Cc,
+		Bcc:             job.Bcc,
+		ReplyTo:         job.ReplyTo,
+		Subject:         job.Subject,
+		Status:          job.Status,
+		Priority:        queue.PriorityName(job.Priority),
+		Attempts:        job.Attempts,
+		Error:           job.Error,
+		Tenant:          job.Tenant,
+		Provider:        job.Provider,
+		IdempotencyKey:  job.IdempotencyKey,
+		CreatedAt:       job.CreatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+	if job.ScheduledAt != nil {
+		resp.ScheduledAt = job.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	return resp
+}
+
+// sendResponse describes a stored email as the response to sending it,
+// reporting a pending email as queued or scheduled.
+func sendResponse(job *queue.EmailJob) *types.SendEmailResponse {
+	resp := &types.SendEmailResponse{
+		Id:         job.ID,
+		Status:     job.Status,
+		Recipients: len(job.Addresses()),
+		Template:   job.TemplateSlug,
+	}
+	if job.ScheduledAt != nil {
+		resp.ScheduledAt = job.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	if job.Status == "pending" {
+		resp.Status = "queued"
+		if job.ScheduledAt != nil && job.ScheduledAt.After(time.Now()) {
+			resp.Status = "scheduled"
+		}
+	}
+	return resp
+}
+
+func recipientResponses(recipients []*queue.Recipient) []types.RecipientStatus {
+	out := make([]types.RecipientStatus, 0, len(recipients))
+	for _, r := range recipients {
+		status := types.RecipientStatus{
+			Address:   r.Address,
+			Status:    r.Status,
+			Attempts:  r.Attempts,
+			Provider:  r.Provider,
+			MessageId: r.MessageID,
+			Error:     r.Error,
+			UpdatedAt: r.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		if r.SentAt != nil {
+			status.SentAt = r.SentAt.UTC().Format(time.RFC3339)
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+func deadLetterResponse(d *queue.DeadLetter) types.DeadLetterItem {
+	return types.DeadLetterItem{
+		Email:    emailResponse(d.Job),
+		Class:    d.Class,
+		Error:    d.Error,
+		Attempts: d.Attempts,
+		FailedAt: d.FailedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func failureResponses(failures []*queue.Failure) []types.FailureItem {
+	out := make([]types.FailureItem, 0, len(failures))
+	for _, f := range failures {
+		out = append(out, types.FailureItem{
+			Attempt:  f.Attempt,
+			Class:    f.Class,
+			Error:    f.Error,
+			FailedAt: f.FailedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
+// deadLetterFilter selects the dead letters of a bulk request. Selecting
+// every dead letter has to be asked for with all, so an empty request
+// cannot requeue or purge them by accident.
+func deadLetterFilter(req *types.DeadLetterBulkRequest) (queue.DeadLetterFilter, error) {
+	filter := queue.DeadLetterFilter{
+		IDs:      req.Ids,
+		Class:    req.Class,
+		Template: req.Template,
+		Tenant:   req.Tenant,
+	}
+	if len(filter.IDs) == 0 && filter.Class == "" && filter.Template == "" && filter.Tenant == "" && !req.All {
+		return filter, errorx.ErrBadRequest("select dead letters by ids, class, template or tenant, or set all")
+	}
+	return filter, nil
+}
+
+// bulkResponse reports a bulk dead-letter operation. Emails the operation
+// failed for are listed in the response; any other error fails the request.
+func bulkResponse(n int, err error) (*types.DeadLetterBulkResponse, error) {
+	resp := &types.DeadLetterBulkResponse{Count: n}
+	if err == nil {
+		return resp, nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return nil, errorx.ErrInternal(err.Error())
+	}
+	for _, e := range joined.Unwrap() {
+		resp.Errors = append(resp.Errors, e.Error())
+	}
+	return resp, nil
+}
+
+// queueError maps an error from a queue operation to an API error.
+func queueError(err error) error {
+	switch {
+	case errors.Is(err, queue.ErrNotFound):
+		return errorx.ErrNotFound(err.Error())
+	case errors.Is(err, queue.ErrStatus):
+		return errorx.ErrConflict(err.Error())
+	default:
+		return errorx.ErrInternal(err.Error())
+	}
+}
+
+// attachments decodes the base64 content of request attachments.
+func attachments(reqs []types.Attachment) ([]mail.Attachment, error) {
+	atts := make([]mail.Attachment, 0, len(reqs))
+	for _, a := range reqs {
+		if a.Filename == "" {
+			return nil, errorx.ErrBadRequest("attachment filename is required")
+		}
+		data, err := base64.StdEncoding.DecodeString(a.Content)
+		if err != nil {
+			return nil, errorx.ErrBadRequest("attachment " + a.Filename + ": content must be base64-encoded")
+		}
+		atts = append(atts, mail.Attachment{
+			Filename:    a.Filename,
+			ContentType: a.ContentType,
+			Data:        data,
+			ContentID:   a.ContentId,
+		})
+	}
+	return atts, nil
+}
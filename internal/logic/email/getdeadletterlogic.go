@@ -0,0 +1,46 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetDeadLetterLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetDeadLetterLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetDeadLetterLogic {
+	return &GetDeadLetterLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetDeadLetterLogic) GetDeadLetter(req *types.EmailIdRequest) (resp *types.DeadLetterItem, err error) {
+	d, err := l.svcCtx.Queue.GetDeadLetter(l.ctx, req.Id)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to get dead letter: " + err.Error())
+	}
+	if d == nil {
+		return nil, errorx.ErrNotFound("dead letter not found: " + req.Id)
+	}
+
+	item := deadLetterResponse(d)
+	if err := json.Unmarshal(d.Payload, &item.Payload); err != nil {
+		return nil, errorx.ErrInternal("failed to decode payload: " + err.Error())
+	}
+	item.History = failureResponses(d.History)
+	return &item, nil
+}
@@ -0,0 +1,59 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetEmailEventsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetEmailEventsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetEmailEventsLogic {
+	return &GetEmailEventsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetEmailEventsLogic) GetEmailEvents(req *types.EmailIdRequest) (resp *types.EmailEventsResponse, err error) {
+	job, err := l.svcCtx.Queue.GetStatus(l.ctx, req.Id)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to get email status: " + err.Error())
+	}
+	if job == nil {
+		return nil, errorx.ErrNotFound("email not found: " + req.Id)
+	}
+
+	events, err := l.svcCtx.Queue.Events(l.ctx, req.Id)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to get events: " + err.Error())
+	}
+
+	out := make([]types.EmailEvent, 0, len(events))
+	for _, e := range events {
+		out = append(out, types.EmailEvent{
+			Type:      e.Type,
+			Timestamp: e.Timestamp.UTC().Format(time.RFC3339),
+			Details:   e.Details,
+		})
+	}
+
+	return &types.EmailEventsResponse{
+		Id:     req.Id,
+		Events: out,
+		Count:  len(out),
+	}, nil
+}
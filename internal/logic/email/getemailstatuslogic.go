@@ -36,14 +36,12 @@ func (l *GetEmailStatusLogic) GetEmailStatus(req *types.GetEmailStatusRequest) (
 		return nil, errorx.ErrNotFound("email not found: " + req.Id)
 	}
 
-	return &types.GetEmailStatusResponse{
-		Id:         job.ID,
-		Template:   job.TemplateSlug,
-		Recipients: job.Recipients,
-		Subject:    job.Subject,
-		Status:     job.Status,
-		Attempts:   job.Attempts,
-		Error:      job.Error,
-		CreatedAt:  job.CreatedAt.Format("2006-01-02T15:04:05Z"),
-	}, nil
+	recipients, err := l.svcCtx.Queue.Recipients(l.ctx, req.Id)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to get recipients: " + err.Error())
+	}
+
+	status := emailResponse(job)
+	status.Deliveries = recipientResponses(recipients)
+	return &status, nil
 }
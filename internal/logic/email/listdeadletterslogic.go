@@ -0,0 +1,51 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ListDeadLettersLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewListDeadLettersLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ListDeadLettersLogic {
+	return &ListDeadLettersLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ListDeadLettersLogic) ListDeadLetters(req *types.ListDeadLettersRequest) (resp *types.ListDeadLettersResponse, err error) {
+	letters, err := l.svcCtx.Queue.DeadLetters(l.ctx, queue.DeadLetterFilter{
+		Class:    req.Class,
+		Template: req.Template,
+		Tenant:   req.Tenant,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to list dead letters: " + err.Error())
+	}
+
+	items := make([]types.DeadLetterItem, 0, len(letters))
+	for _, d := range letters {
+		items = append(items, deadLetterResponse(d))
+	}
+
+	return &types.ListDeadLettersResponse{
+		DeadLetters: items,
+		Count:       len(items),
+	}, nil
+}
@@ -35,16 +35,7 @@ func (l *ListEmailsLogic) ListEmails(req *types.ListEmailsRequest) (resp *types.
 
 	emails := make([]types.GetEmailStatusResponse, 0, len(jobs))
 	for _, job := range jobs {
-		emails = append(emails, types.GetEmailStatusResponse{
-			Id:         job.ID,
-			Template:   job.TemplateSlug,
-			Recipients: job.Recipients,
-			Subject:    job.Subject,
-			Status:     job.Status,
-			Attempts:   job.Attempts,
-			Error:      job.Error,
-			CreatedAt:  job.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		})
+		emails = append(emails, emailResponse(job))
 	}
 
 	return &types.ListEmailsResponse{
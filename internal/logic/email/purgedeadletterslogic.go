@@ -0,0 +1,37 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type PurgeDeadLettersLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewPurgeDeadLettersLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PurgeDeadLettersLogic {
+	return &PurgeDeadLettersLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *PurgeDeadLettersLogic) PurgeDeadLetters(req *types.DeadLetterBulkRequest) (resp *types.DeadLetterBulkResponse, err error) {
+	filter, err := deadLetterFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := l.svcCtx.Queue.PurgeDeadLetters(l.ctx, filter)
+	return bulkResponse(n, err)
+}
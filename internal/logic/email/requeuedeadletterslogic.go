@@ -0,0 +1,37 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RequeueDeadLettersLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRequeueDeadLettersLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RequeueDeadLettersLogic {
+	return &RequeueDeadLettersLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RequeueDeadLettersLogic) RequeueDeadLetters(req *types.DeadLetterBulkRequest) (resp *types.DeadLetterBulkResponse, err error) {
+	filter, err := deadLetterFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := l.svcCtx.Queue.RequeueDeadLetters(l.ctx, filter)
+	return bulkResponse(n, err)
+}
@@ -0,0 +1,42 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RescheduleEmailLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRescheduleEmailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RescheduleEmailLogic {
+	return &RescheduleEmailLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RescheduleEmailLogic) RescheduleEmail(req *types.RescheduleEmailRequest) (resp *types.GetEmailStatusResponse, err error) {
+	at, err := time.Parse(time.RFC3339, req.ScheduledAt)
+	if err != nil {
+		return nil, errorx.ErrBadRequest("scheduled_at must be an RFC 3339 timestamp, e.g. 2025-01-02T15:04:05Z")
+	}
+
+	if err := l.svcCtx.Queue.Reschedule(l.ctx, req.Id, at); err != nil {
+		return nil, queueError(err)
+	}
+
+	return NewGetEmailStatusLogic(l.ctx, l.svcCtx).GetEmailStatus(&types.GetEmailStatusRequest{Id: req.Id})
+}
@@ -0,0 +1,35 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package email
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RetryEmailLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRetryEmailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RetryEmailLogic {
+	return &RetryEmailLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RetryEmailLogic) RetryEmail(req *types.EmailIdRequest) (resp *types.GetEmailStatusResponse, err error) {
+	if err := l.svcCtx.Queue.Retry(l.ctx, req.Id); err != nil {
+		return nil, queueError(err)
+	}
+
+	return NewGetEmailStatusLogic(l.ctx, l.svcCtx).GetEmailStatus(&types.GetEmailStatusRequest{Id: req.Id})
+}
@@ -5,12 +5,16 @@ package email
 
 import (
 	"context"
+	"errors"
+	"net/mail"
+	"time"
 
 	"github.com/joeblew999/plat-mjml/internal/errorx"
 	"github.com/joeblew999/plat-mjml/internal/svc"
 	"github.com/joeblew999/plat-mjml/internal/types"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
 
+	"github.com/google/uuid"
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
@@ -29,22 +33,100 @@ func NewSendEmailLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SendEma
 }
 
 func (l *SendEmailLogic) SendEmail(req *types.SendEmailRequest) (resp *types.SendEmailResponse, err error) {
+	if req.Template == "" {
+		return nil, errorx.ErrBadRequest("template is required")
+	}
+	if len(req.To) == 0 {
+		return nil, errorx.ErrBadRequest("at least one recipient is required")
+	}
+	for _, list := range [][]string{req.To, req.Cc, req.Bcc} {
+		if err := validateAddresses(list); err != nil {
+			return nil, err
+		}
+	}
+	if req.ReplyTo != "" {
+		if err := validateAddresses([]string{req.ReplyTo}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(req.IdempotencyKey) > 255 {
+		return nil, errorx.ErrBadRequest("Idempotency-Key must be at most 255 characters")
+	}
+
+	priority, err := queue.ParsePriority(req.Priority)
+	if err != nil {
+		return nil, errorx.ErrBadRequest(err.Error())
+	}
+
+	atts, err := attachments(req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+
 	job := queue.EmailJob{
-		TemplateSlug: req.Template,
-		Recipients:   req.To,
-		Subject:      req.Subject,
-		Priority:     queue.PriorityNormal,
+		ID:              uuid.New().String(),
+		TemplateSlug:    req.Template,
+		TemplateVersion: req.TemplateVersion,
+		Recipients:      req.To,
+		Cc:              req.Cc,
+		Bcc:             req.Bcc,
+		ReplyTo:         req.ReplyTo,
+		Subject:         req.Subject,
+		Data:            req.Data,
+		Headers:         req.Headers,
+		Attachments:     atts,
+		Tenant:          req.Tenant,
+		Priority:        priority,
+		IdempotencyKey:  req.IdempotencyKey,
+	}
+
+	status := "queued"
+	if req.ScheduledAt != "" {
+		at, err := time.Parse(time.RFC3339, req.ScheduledAt)
+		if err != nil {
+			return nil, errorx.ErrBadRequest("scheduled_at must be an RFC 3339 timestamp, e.g. 2025-01-02T15:04:05Z")
+		}
+		job.ScheduledAt = &at
+		if at.After(time.Now()) {
+			status = "scheduled"
+		}
 	}
 
 	id, err := l.svcCtx.Queue.Enqueue(l.ctx, job)
+	var suppressed *queue.SuppressedError
+	if errors.As(err, &suppressed) {
+		return nil, errorx.ErrBadRequestWithDetails(err.Error(), map[string]any{"suppressed": suppressed.Addresses})
+	}
 	if err != nil {
 		return nil, errorx.ErrInternal("failed to enqueue email: " + err.Error())
 	}
+	if id != job.ID {
+		// A reused idempotency key: report the email it first created
+		existing, err := l.svcCtx.Queue.GetStatus(l.ctx, id)
+		if err != nil || existing == nil {
+			return nil, errorx.ErrInternal("failed to get existing email: " + id)
+		}
+		return sendResponse(existing), nil
+	}
 
-	return &types.SendEmailResponse{
+	resp = &types.SendEmailResponse{
 		Id:         id,
-		Status:     "queued",
-		Recipients: len(req.To),
+		Status:     status,
+		Recipients: len(req.To) + len(req.Cc) + len(req.Bcc),
 		Template:   req.Template,
-	}, nil
+	}
+	if job.ScheduledAt != nil {
+		resp.ScheduledAt = job.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	return resp, nil
+}
+
+func validateAddresses(addrs []string) error {
+	for _, addr := range addrs {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return errorx.ErrBadRequest("invalid email address: " + addr)
+		}
+	}
+	return nil
 }
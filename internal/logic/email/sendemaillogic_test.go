@@ -0,0 +1,65 @@
+package email
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+func newTestContext(t *testing.T) *svc.ServiceContext {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	return svc.NewServiceContext(nil, q, nil, nil)
+}
+
+func TestSendEmailReusedKeyReportsExistingEmail(t *testing.T) {
+	svcCtx := newTestContext(t)
+	ctx := context.Background()
+	l := NewSendEmailLogic(ctx, svcCtx)
+
+	first, err := l.SendEmail(&types.SendEmailRequest{
+		Template:       "receipt",
+		To:             []string{"a@example.com"},
+		Cc:             []string{"b@example.com"},
+		Subject:        "Your receipt",
+		IdempotencyKey: "order-42",
+	})
+	if err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	if first.Status != "queued" || first.Recipients != 2 {
+		t.Fatalf("Unexpected response %+v", first)
+	}
+	if err := svcCtx.Queue.UpdateStatus(ctx, first.Id, "sent", nil); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	again, err := l.SendEmail(&types.SendEmailRequest{
+		Template:       "receipt",
+		To:             []string{"c@example.com"},
+		Subject:        "Your receipt",
+		ScheduledAt:    "2099-01-01T00:00:00Z",
+		IdempotencyKey: "order-42",
+	})
+	if err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	if again.Id != first.Id || again.Status != "sent" || again.Recipients != 2 || again.ScheduledAt != "" {
+		t.Errorf("Expected the sent email %s with 2 recipients, got %+v", first.Id, again)
+	}
+}
@@ -0,0 +1,33 @@
+package suppression
+
+import (
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+// validReasons lists the reasons a suppression can be created with; empty
+// defaults to manual.
+var validReasons = map[string]bool{
+	"":                        true,
+	queue.SuppressBounce:      true,
+	queue.SuppressComplaint:   true,
+	queue.SuppressUnsubscribe: true,
+	queue.SuppressManual:      true,
+}
+
+func suppressionResponse(s *queue.Suppression) types.SuppressionItem {
+	item := types.SuppressionItem{
+		Address:   s.Address,
+		Category:  s.Category,
+		Reason:    s.Reason,
+		Source:    s.Source,
+		EmailId:   s.EmailID,
+		CreatedAt: s.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if s.ExpiresAt != nil {
+		item.ExpiresAt = s.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return item
+}
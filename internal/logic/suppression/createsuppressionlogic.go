@@ -0,0 +1,68 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type CreateSuppressionLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewCreateSuppressionLogic(ctx context.Context, svcCtx *svc.ServiceContext) *CreateSuppressionLogic {
+	return &CreateSuppressionLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *CreateSuppressionLogic) CreateSuppression(req *types.CreateSuppressionRequest) (resp *types.SuppressionItem, err error) {
+	addr, err := mail.ParseAddress(req.Address)
+	if err != nil {
+		return nil, errorx.ErrBadRequest("invalid address: " + req.Address)
+	}
+	if !validReasons[req.Reason] {
+		return nil, errorx.ErrBadRequest("reason must be bounce, complaint, unsubscribe or manual")
+	}
+
+	s := queue.Suppression{
+		Address:   strings.ToLower(addr.Address),
+		Category:  req.Category,
+		Reason:    req.Reason,
+		Source:    "api",
+		CreatedAt: time.Now(),
+	}
+	if s.Reason == "" {
+		s.Reason = queue.SuppressManual
+	}
+	if req.ExpiresAt != "" {
+		at, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return nil, errorx.ErrBadRequest("expires_at must be an RFC 3339 timestamp, e.g. 2025-01-02T15:04:05Z")
+		}
+		s.ExpiresAt = &at
+	}
+
+	if err := l.svcCtx.Queue.Suppress(l.ctx, s); err != nil {
+		return nil, errorx.ErrInternal("failed to suppress address: " + err.Error())
+	}
+	l.Infow("Address suppressed", logx.Field("address", s.Address), logx.Field("category", s.Category))
+
+	item := suppressionResponse(&s)
+	return &item, nil
+}
@@ -0,0 +1,47 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DeleteSuppressionLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewDeleteSuppressionLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DeleteSuppressionLogic {
+	return &DeleteSuppressionLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *DeleteSuppressionLogic) DeleteSuppression(req *types.DeleteSuppressionRequest) (resp *types.DeleteSuppressionResponse, err error) {
+	err = l.svcCtx.Queue.Unsuppress(l.ctx, req.Address, req.Category)
+	if errors.Is(err, queue.ErrNotSuppressed) {
+		return nil, errorx.ErrNotFound(err.Error())
+	}
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to remove suppression: " + err.Error())
+	}
+	l.Infow("Suppression removed", logx.Field("address", req.Address), logx.Field("category", req.Category))
+
+	return &types.DeleteSuppressionResponse{
+		Address:  req.Address,
+		Category: req.Category,
+		Deleted:  true,
+	}, nil
+}
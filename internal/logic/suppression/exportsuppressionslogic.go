@@ -0,0 +1,36 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ExportSuppressionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewExportSuppressionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ExportSuppressionsLogic {
+	return &ExportSuppressionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ExportSuppressionsLogic) ExportSuppressions() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := l.svcCtx.Queue.ExportSuppressions(l.ctx, &buf); err != nil {
+		return nil, errorx.ErrInternal("failed to export suppressions: " + err.Error())
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ImportSuppressionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewImportSuppressionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ImportSuppressionsLogic {
+	return &ImportSuppressionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ImportSuppressionsLogic) ImportSuppressions(body io.Reader) (resp *types.ImportSuppressionsResponse, err error) {
+	n, err := l.svcCtx.Queue.ImportSuppressions(l.ctx, body, "import")
+	if err != nil {
+		return nil, errorx.ErrBadRequest(fmt.Sprintf("import stopped after %d suppressions: %v", n, err))
+	}
+	l.Infow("Suppressions imported", logx.Field("count", n))
+
+	return &types.ImportSuppressionsResponse{Imported: n}, nil
+}
@@ -0,0 +1,51 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package suppression
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ListSuppressionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewListSuppressionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ListSuppressionsLogic {
+	return &ListSuppressionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ListSuppressionsLogic) ListSuppressions(req *types.ListSuppressionsRequest) (resp *types.ListSuppressionsResponse, err error) {
+	list, err := l.svcCtx.Queue.Suppressions(l.ctx, queue.SuppressionFilter{
+		Address:  req.Address,
+		Category: req.Category,
+		Reason:   req.Reason,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to list suppressions: " + err.Error())
+	}
+
+	items := make([]types.SuppressionItem, 0, len(list))
+	for _, s := range list {
+		items = append(items, suppressionResponse(s))
+	}
+
+	return &types.ListSuppressionsResponse{
+		Suppressions: items,
+		Count:        len(items),
+	}, nil
+}
@@ -0,0 +1,100 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func validateSlug(slug string) error {
+	if !slugPattern.MatchString(slug) {
+		return errorx.ErrBadRequest("invalid slug: use lowercase letters, digits, '_' and '-'")
+	}
+	return nil
+}
+
+func validateStatus(status string) error {
+	switch status {
+	case "", tmplstore.StatusDraft, tmplstore.StatusPublished, tmplstore.StatusArchived:
+		return nil
+	}
+	return errorx.ErrBadRequest("invalid status: " + status)
+}
+
+// validationError maps renderer validation failures to a structured 400.
+func validationError(err error) error {
+	var verr *mjml.ValidationError
+	if !errors.As(err, &verr) {
+		return errorx.ErrBadRequest(err.Error())
+	}
+
+	details := make([]types.TemplateError, len(verr.Errors))
+	for i, e := range verr.Errors {
+		details[i] = types.TemplateError{
+			Stage:   e.Stage,
+			Line:    e.Line,
+			Tag:     e.Tag,
+			Message: e.Message,
+		}
+	}
+	return errorx.ErrBadRequestWithDetails("invalid template", details)
+}
+
+// checkVersion reports whether a version of a template exists and still
+// renders, so it can be made live again.
+func checkVersion(ctx context.Context, svcCtx *svc.ServiceContext, slug string, version int) error {
+	t, err := svcCtx.Templates.Get(ctx, slug)
+	if err != nil {
+		return errorx.ErrInternal("failed to load template: " + err.Error())
+	}
+	if t == nil {
+		return errorx.ErrNotFound("template not found: " + slug)
+	}
+
+	v, err := svcCtx.Templates.GetVersion(ctx, slug, version)
+	if err != nil {
+		return errorx.ErrInternal("failed to load template version: " + err.Error())
+	}
+	if v == nil {
+		return errorx.ErrNotFound(fmt.Sprintf("template version not found: %s version %d", slug, version))
+	}
+
+	if err := svcCtx.Renderer.Validate(slug, v.Content, nil); err != nil {
+		return validationError(err)
+	}
+	if err := svcCtx.Renderer.CheckTextTemplate(slug, v.Text); err != nil {
+		return errorx.ErrBadRequest(err.Error())
+	}
+	return nil
+}
+
+func templateResponse(t *tmplstore.Template) *types.GetTemplateResponse {
+	description := templateDescription(t.Slug)
+	if desc, ok := t.Metadata["description"].(string); ok && desc != "" {
+		description = desc
+	}
+
+	return &types.GetTemplateResponse{
+		Slug:          t.Slug,
+		Description:   description,
+		Name:          t.Name,
+		Content:       t.Content,
+		Text:          t.Text,
+		Version:       t.Version,
+		LatestVersion: t.LatestVersion,
+		Status:        t.Status,
+		Category:      t.Category,
+		Metadata:      t.Metadata,
+		UpdatedAt:     t.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,67 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type CreateTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewCreateTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *CreateTemplateLogic {
+	return &CreateTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *CreateTemplateLogic) CreateTemplate(req *types.CreateTemplateRequest) (resp *types.GetTemplateResponse, err error) {
+	if err := validateSlug(req.Slug); err != nil {
+		return nil, err
+	}
+	if err := validateStatus(req.Status); err != nil {
+		return nil, err
+	}
+	if req.Content == "" {
+		return nil, errorx.ErrBadRequest("content is required")
+	}
+	if err := l.svcCtx.Renderer.Validate(req.Slug, req.Content, nil); err != nil {
+		return nil, validationError(err)
+	}
+	if err := l.svcCtx.Renderer.CheckTextTemplate(req.Slug, req.Text); err != nil {
+		return nil, errorx.ErrBadRequest(err.Error())
+	}
+
+	t := &tmplstore.Template{
+		Slug:     req.Slug,
+		Name:     req.Name,
+		Content:  req.Content,
+		Text:     req.Text,
+		Status:   req.Status,
+		Category: req.Category,
+		Metadata: req.Metadata,
+	}
+	if err := l.svcCtx.Templates.Create(l.ctx, t); err != nil {
+		if errors.Is(err, tmplstore.ErrExists) {
+			return nil, errorx.ErrConflict("template already exists: " + req.Slug)
+		}
+		return nil, errorx.ErrInternal("failed to create template: " + err.Error())
+	}
+
+	l.Infow("Template created", logx.Field("slug", t.Slug), logx.Field("status", t.Status))
+	return templateResponse(t), nil
+}
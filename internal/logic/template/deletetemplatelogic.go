@@ -0,0 +1,45 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DeleteTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewDeleteTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DeleteTemplateLogic {
+	return &DeleteTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *DeleteTemplateLogic) DeleteTemplate(req *types.DeleteTemplateRequest) (resp *types.DeleteTemplateResponse, err error) {
+	if err := l.svcCtx.Templates.Delete(l.ctx, req.Slug); err != nil {
+		if errors.Is(err, tmplstore.ErrNotFound) {
+			return nil, errorx.ErrNotFound("template not found: " + req.Slug)
+		}
+		return nil, errorx.ErrInternal("failed to delete template: " + err.Error())
+	}
+
+	l.Infow("Template deleted", logx.Field("slug", req.Slug))
+	return &types.DeleteTemplateResponse{
+		Slug:    req.Slug,
+		Deleted: true,
+	}, nil
+}
@@ -28,6 +28,14 @@ func NewGetTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetTe
 }
 
 func (l *GetTemplateLogic) GetTemplate(req *types.GetTemplateRequest) (resp *types.GetTemplateResponse, err error) {
+	t, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to load template: " + err.Error())
+	}
+	if t != nil {
+		return templateResponse(t), nil
+	}
+
 	slugs := l.svcCtx.Renderer.ListTemplates()
 	for _, slug := range slugs {
 		if slug == req.Slug {
@@ -0,0 +1,61 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ListTemplateVersionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewListTemplateVersionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ListTemplateVersionsLogic {
+	return &ListTemplateVersionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ListTemplateVersionsLogic) ListTemplateVersions(req *types.ListTemplateVersionsRequest) (resp *types.ListTemplateVersionsResponse, err error) {
+	t, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to load template: " + err.Error())
+	}
+	if t == nil {
+		return nil, errorx.ErrNotFound("template not found: " + req.Slug)
+	}
+
+	versions, err := l.svcCtx.Templates.ListVersions(l.ctx, req.Slug)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to list template versions: " + err.Error())
+	}
+
+	items := make([]types.TemplateVersion, len(versions))
+	for i, v := range versions {
+		items[i] = types.TemplateVersion{
+			Version:   v.Version,
+			Content:   v.Content,
+			Text:      v.Text,
+			CreatedAt: v.CreatedAt.Format(time.RFC3339),
+			Live:      v.Live,
+		}
+	}
+
+	return &types.ListTemplateVersionsResponse{
+		Slug:     req.Slug,
+		Versions: items,
+		Count:    len(items),
+	}, nil
+}
@@ -0,0 +1,91 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type PreviewTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewPreviewTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PreviewTemplateLogic {
+	return &PreviewTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *PreviewTemplateLogic) PreviewTemplate(req *types.PreviewTemplateRequest) (resp *types.PreviewTemplateResponse, err error) {
+	// Fall back to the canned test data when the caller sends none
+	var data any = req.Data
+	if req.Data == nil {
+		testData := mjml.TestData()
+		data = testData[req.Slug]
+		if data == nil {
+			data = testData["simple"]
+		}
+	}
+
+	start := time.Now()
+	var result *mjml.Result
+	var version int
+
+	// Versions are rendered with their own text part; otherwise the live
+	// one is used
+	switch {
+	case req.Mjml != "":
+		if err := l.svcCtx.Renderer.Validate(req.Slug, req.Mjml, data); err != nil {
+			return nil, validationError(err)
+		}
+		var html string
+		if html, err = l.svcCtx.Renderer.RenderContent(req.Slug, req.Mjml, data); err == nil {
+			result, err = l.svcCtx.Renderer.ResultFor(req.Slug, html, data)
+		}
+
+	case req.Version > 0:
+		version = req.Version
+		result, err = l.svcCtx.Templates.RenderVersion(l.ctx, req.Slug, req.Version, data)
+		if errors.Is(err, tmplstore.ErrNotFound) {
+			return nil, errorx.ErrNotFound(err.Error())
+		}
+
+	default:
+		if !l.svcCtx.Renderer.HasTemplate(req.Slug) {
+			return nil, errorx.ErrNotFound("template not found: " + req.Slug)
+		}
+		result, err = l.svcCtx.Renderer.Render(req.Slug, data)
+		if t, _ := l.svcCtx.Templates.Get(l.ctx, req.Slug); t != nil && t.Status == tmplstore.StatusPublished {
+			version = t.Version
+		}
+	}
+	if err != nil {
+		return nil, errorx.ErrBadRequest("failed to render template: " + err.Error())
+	}
+	elapsed := time.Since(start)
+
+	return &types.PreviewTemplateResponse{
+		Html:     result.HTML,
+		Text:     result.Text,
+		Subject:  result.Subject,
+		Template: req.Slug,
+		Version:  version,
+		Size:     len(result.HTML),
+		RenderMs: float64(elapsed.Microseconds()) / 1000,
+	}, nil
+}
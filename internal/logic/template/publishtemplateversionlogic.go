@@ -0,0 +1,49 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type PublishTemplateVersionLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewPublishTemplateVersionLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PublishTemplateVersionLogic {
+	return &PublishTemplateVersionLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *PublishTemplateVersionLogic) PublishTemplateVersion(req *types.PublishTemplateVersionRequest) (resp *types.GetTemplateResponse, err error) {
+	if err := checkVersion(l.ctx, l.svcCtx, req.Slug, req.Version); err != nil {
+		return nil, err
+	}
+
+	if _, err := l.svcCtx.Templates.Publish(l.ctx, req.Slug, req.Version); err != nil {
+		return nil, errorx.ErrInternal("failed to publish template: " + err.Error())
+	}
+
+	published, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil || published == nil {
+		return nil, errorx.ErrInternal("failed to reload template")
+	}
+
+	l.Infow("Template version published",
+		logx.Field("slug", published.Slug),
+		logx.Field("version", published.Version),
+	)
+	return templateResponse(published), nil
+}
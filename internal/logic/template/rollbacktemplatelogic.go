@@ -0,0 +1,50 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RollbackTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewRollbackTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RollbackTemplateLogic {
+	return &RollbackTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *RollbackTemplateLogic) RollbackTemplate(req *types.RollbackTemplateRequest) (resp *types.GetTemplateResponse, err error) {
+	if err := checkVersion(l.ctx, l.svcCtx, req.Slug, req.Version); err != nil {
+		return nil, err
+	}
+
+	if _, err := l.svcCtx.Templates.Rollback(l.ctx, req.Slug, req.Version); err != nil {
+		return nil, errorx.ErrInternal("failed to roll back template: " + err.Error())
+	}
+
+	restored, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil || restored == nil {
+		return nil, errorx.ErrInternal("failed to reload template")
+	}
+
+	l.Infow("Template rolled back",
+		logx.Field("slug", restored.Slug),
+		logx.Field("from_version", req.Version),
+		logx.Field("version", restored.Version),
+	)
+	return templateResponse(restored), nil
+}
@@ -0,0 +1,107 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	tmplstore "github.com/joeblew999/plat-mjml/pkg/template"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpdateTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUpdateTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpdateTemplateLogic {
+	return &UpdateTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *UpdateTemplateLogic) UpdateTemplate(req *types.UpdateTemplateRequest) (resp *types.GetTemplateResponse, err error) {
+	if err := validateStatus(req.Status); err != nil {
+		return nil, err
+	}
+	if req.Draft && req.Content == "" {
+		return nil, errorx.ErrBadRequest("content is required when saving a draft")
+	}
+	if req.Content != "" {
+		if err := l.svcCtx.Renderer.Validate(req.Slug, req.Content, nil); err != nil {
+			return nil, validationError(err)
+		}
+	}
+	if err := l.svcCtx.Renderer.CheckTextTemplate(req.Slug, req.Text); err != nil {
+		return nil, errorx.ErrBadRequest(err.Error())
+	}
+
+	current, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to load template: " + err.Error())
+	}
+	if current == nil {
+		return nil, errorx.ErrNotFound("template not found: " + req.Slug)
+	}
+
+	text := current.Text
+	if req.Text != "" {
+		text = req.Text
+	}
+
+	// Drafts are staged as a new version without touching the live content
+	if req.Draft {
+		if _, err := l.svcCtx.Templates.SaveDraft(l.ctx, req.Slug, req.Content, text); err != nil {
+			return nil, errorx.ErrInternal("failed to save draft: " + err.Error())
+		}
+		if current, err = l.svcCtx.Templates.Get(l.ctx, req.Slug); err != nil {
+			return nil, errorx.ErrInternal("failed to load template: " + err.Error())
+		}
+	}
+
+	t := &tmplstore.Template{
+		Slug:     req.Slug,
+		Name:     req.Name,
+		Content:  current.Content,
+		Text:     current.Text,
+		Status:   req.Status,
+		Category: current.Category,
+		Metadata: current.Metadata,
+	}
+	if !req.Draft {
+		if req.Content != "" {
+			t.Content = req.Content
+		}
+		t.Text = text
+	}
+	if req.Category != "" {
+		t.Category = req.Category
+	}
+	if req.Metadata != nil {
+		t.Metadata = req.Metadata
+	}
+
+	if err := l.svcCtx.Templates.Update(l.ctx, t); err != nil {
+		return nil, errorx.ErrInternal("failed to update template: " + err.Error())
+	}
+
+	updated, err := l.svcCtx.Templates.Get(l.ctx, req.Slug)
+	if err != nil || updated == nil {
+		return nil, errorx.ErrInternal("failed to reload template")
+	}
+
+	l.Infow("Template updated",
+		logx.Field("slug", updated.Slug),
+		logx.Field("version", updated.Version),
+		logx.Field("latest_version", updated.LatestVersion),
+	)
+	return templateResponse(updated), nil
+}
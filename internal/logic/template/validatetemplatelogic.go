@@ -0,0 +1,46 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package template
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ValidateTemplateLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewValidateTemplateLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ValidateTemplateLogic {
+	return &ValidateTemplateLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ValidateTemplateLogic) ValidateTemplate(req *types.ValidateTemplateRequest) (resp *types.ValidateTemplateResponse, err error) {
+	if req.Content == "" {
+		return nil, errorx.ErrBadRequest("content is required")
+	}
+
+	// Only execute strictly when the caller supplied sample data
+	var data any
+	if req.Data != nil {
+		data = req.Data
+	}
+
+	if err := l.svcCtx.Renderer.Validate("validate", req.Content, data); err != nil {
+		return nil, validationError(err)
+	}
+
+	return &types.ValidateTemplateResponse{Valid: true}, nil
+}
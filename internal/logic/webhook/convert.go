@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/webhook"
+)
+
+func deliveryResponse(d *webhook.Delivery) (types.WebhookDelivery, error) {
+	item := types.WebhookDelivery{
+		Id:           d.ID,
+		Webhook:      d.Webhook,
+		Url:          d.URL,
+		EventId:      d.EventID,
+		EventType:    d.EventType,
+		EmailId:      d.EmailID,
+		Status:       d.Status,
+		Attempts:     d.Attempts,
+		ResponseCode: d.ResponseCode,
+		Error:        d.Error,
+		CreatedAt:    d.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if d.DeliveredAt != nil {
+		item.DeliveredAt = d.DeliveredAt.UTC().Format(time.RFC3339)
+	}
+	if err := json.Unmarshal(d.Payload, &item.Payload); err != nil {
+		return item, errorx.ErrInternal("failed to decode payload: " + err.Error())
+	}
+	return item, nil
+}
@@ -0,0 +1,55 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/webhook"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ListWebhookDeliveriesLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewListWebhookDeliveriesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ListWebhookDeliveriesLogic {
+	return &ListWebhookDeliveriesLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ListWebhookDeliveriesLogic) ListWebhookDeliveries(req *types.ListWebhookDeliveriesRequest) (resp *types.ListWebhookDeliveriesResponse, err error) {
+	deliveries, err := l.svcCtx.Webhooks.Deliveries(l.ctx, webhook.DeliveryFilter{
+		Webhook: req.Webhook,
+		Status:  req.Status,
+		EmailID: req.EmailId,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to list webhook deliveries: " + err.Error())
+	}
+
+	items := make([]types.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		item, err := deliveryResponse(d)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return &types.ListWebhookDeliveriesResponse{
+		Deliveries: items,
+		Count:      len(items),
+	}, nil
+}
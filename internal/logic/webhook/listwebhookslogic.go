@@ -0,0 +1,44 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ListWebhooksLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewListWebhooksLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ListWebhooksLogic {
+	return &ListWebhooksLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ListWebhooksLogic) ListWebhooks() (resp *types.ListWebhooksResponse, err error) {
+	endpoints := l.svcCtx.Webhooks.Endpoints()
+	items := make([]types.WebhookItem, 0, len(endpoints))
+	for _, e := range endpoints {
+		items = append(items, types.WebhookItem{
+			Name:   e.Name,
+			Url:    e.URL,
+			Events: e.Events,
+		})
+	}
+
+	return &types.ListWebhooksResponse{
+		Webhooks: items,
+		Count:    len(items),
+	}, nil
+}
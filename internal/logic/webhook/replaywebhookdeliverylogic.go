@@ -0,0 +1,49 @@
+// Code scaffolded by goctl. Safe to edit.
+// goctl 1.9.2
+
+package webhook
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joeblew999/plat-mjml/internal/errorx"
+	"github.com/joeblew999/plat-mjml/internal/svc"
+	"github.com/joeblew999/plat-mjml/internal/types"
+	"github.com/joeblew999/plat-mjml/pkg/webhook"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type ReplayWebhookDeliveryLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewReplayWebhookDeliveryLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ReplayWebhookDeliveryLogic {
+	return &ReplayWebhookDeliveryLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ReplayWebhookDeliveryLogic) ReplayWebhookDelivery(req *types.WebhookDeliveryIdRequest) (resp *types.WebhookDelivery, err error) {
+	d, err := l.svcCtx.Webhooks.Replay(l.ctx, req.Id)
+	if errors.Is(err, webhook.ErrNotFound) {
+		return nil, errorx.ErrNotFound("webhook delivery not found: " + req.Id)
+	}
+	if errors.Is(err, webhook.ErrInFlight) {
+		return nil, errorx.ErrConflict(err.Error())
+	}
+	if err != nil {
+		return nil, errorx.ErrInternal("failed to replay webhook delivery: " + err.Error())
+	}
+
+	item, err := deliveryResponse(d)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -16,6 +16,12 @@ type Config struct {
 	Database  DatabaseConfig  `json:",optional"`
 	Delivery  DeliveryConfig  `json:",optional"`
 	SMTP      SMTPConfig      `json:",optional"`
+	Transport TransportConfig `json:",optional"`
+	DKIM      []DKIMConfig    `json:",optional"`
+	Webhooks  []WebhookConfig `json:",optional"`
+	Bounces   BouncesConfig   `json:",optional"`
+
+	Unsubscribe UnsubscribeConfig `json:",optional"`
 }
 
 // UIConfig holds the Web UI server settings.
@@ -49,6 +55,9 @@ type DeliveryConfig struct {
 	RetryBackoff string `json:",default=5m"`
 	MaxBackoff   string `json:",default=4h"`
 	RateLimit    int    `json:",default=60"`
+	MaxDeferral  string `json:",default=24h"` // how long throttled emails keep being retried
+
+	IdempotencyWindow string `json:",default=24h"` // how long idempotency keys are remembered
 }
 
 // SMTPConfig holds SMTP email delivery settings.
@@ -59,4 +68,66 @@ type SMTPConfig struct {
 	Password  string `json:",optional"`
 	FromEmail string `json:",optional"`
 	FromName  string `json:",optional"`
+
+	// Connection pool
+	MaxConns    int    `json:",default=4"`   // concurrent connections
+	IdleTimeout string `json:",default=30s"` // close connections unused for this long
+
+	// Security
+	TLS                string `json:",optional"` // opportunistic, starttls, tls or none; tls by default on port 465
+	CAFile             string `json:",optional"` // PEM certificates trusted for an internal relay
+	InsecureSkipVerify bool   `json:",optional"`
+	Auth               string `json:",optional"` // plain (default), login, cram-md5, xoauth2 or none
+
+	// XOAUTH2: without a refresh token, Password is used as the access token
+	OAuthClientID     string `json:",optional"`
+	OAuthClientSecret string `json:",optional"`
+	OAuthRefreshToken string `json:",optional"`
+	OAuthTokenURL     string `json:",optional"` // defaults to Google's
+}
+
+// TransportConfig selects how email is delivered. The sender identity
+// (FromEmail, FromName) is taken from SMTPConfig for every transport.
+type TransportConfig struct {
+	Type      string `json:",default=smtp,options=smtp|file|sendgrid|postmark|ses"`
+	Path      string `json:",default=./.data/mail"` // file: .eml directory or mbox file
+	Format    string `json:",default=eml,options=eml|mbox"`
+	Endpoint  string `json:",optional"` // override the provider API URL
+	APIKey    string `json:",optional"`
+	SecretKey string `json:",optional"` // ses only
+	Region    string `json:",optional"` // ses only
+}
+
+// DKIMConfig holds the signing key for one sending domain. Keys can also
+// be stored in the dkim_keys table.
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	KeyFile  string // PEM-encoded RSA or Ed25519 private key
+}
+
+// WebhookConfig holds an endpoint that receives email events. Endpoints
+// can also be stored in the webhooks table.
+type WebhookConfig struct {
+	Name   string
+	URL    string
+	Secret string   // signs callbacks with HMAC-SHA256
+	Events []string `json:",optional"` // event types to send; all when empty
+}
+
+// BouncesConfig holds how bounces and complaints reaching us after
+// delivery are received. Provider webhooks and raw reports are accepted on
+// the API server under /api/v1/bounces when Token is set.
+type BouncesConfig struct {
+	Maildir      string `json:",optional"`   // Maildir of the bounce address, polled for DSN and ARF reports
+	PollInterval string `json:",default=1m"` // how often the Maildir is polled
+	Token        string `json:",optional"`   // required as ?token= on the bounce endpoints, which are off without it
+}
+
+// UnsubscribeConfig enables per-recipient one-click unsubscribe links,
+// served by the UI server and added to emails as List-Unsubscribe headers.
+type UnsubscribeConfig struct {
+	Secret     string   `json:",optional"` // signs the links; they are off when empty
+	BaseURL    string   `json:",optional"` // public URL of the UI server; defaults to its host and port
+	Categories []string `json:",optional"` // template categories that get links; all when empty
 }
@@ -3,10 +3,16 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/mail"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joeblew999/plat-mjml/pkg/mjml"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
 	"github.com/zeromicro/go-zero/mcp"
 )
 
@@ -19,23 +25,71 @@ type renderTemplateArgs struct {
 
 type listTemplatesArgs struct{}
 
+type templateSlugArgs struct {
+	Template string `json:"template" jsonschema:"template slug, e.g. welcome, reset_password"`
+}
+
+type templateVersionArgs struct {
+	Template string `json:"template" jsonschema:"template slug, e.g. welcome, reset_password"`
+	Version  int    `json:"version" jsonschema:"template version number, as listed by list_template_versions"`
+}
+
 type sendEmailArgs struct {
 	Template string         `json:"template" jsonschema:"template slug, e.g. welcome, reset_password"`
 	To       []string       `json:"to" jsonschema:"list of recipient email addresses"`
 	Subject  string         `json:"subject" jsonschema:"email subject line"`
 	Data     map[string]any `json:"data,omitempty" jsonschema:"template variables as key-value pairs"`
+	Version  int            `json:"template_version,omitempty" jsonschema:"pin a specific template version; defaults to the live version"`
+
+	IdempotencyKey string `json:"idempotency_key,omitempty" jsonschema:"unique key for this send; retrying with the same key returns the original email instead of sending twice"`
 }
 
 type getEmailStatusArgs struct {
 	ID string `json:"id" jsonschema:"email job ID returned from send_email"`
 }
 
+type emailIDArgs struct {
+	ID string `json:"id" jsonschema:"email job ID returned from send_email"`
+}
+
+type rescheduleEmailArgs struct {
+	ID          string `json:"id" jsonschema:"email job ID returned from send_email"`
+	ScheduledAt string `json:"scheduled_at" jsonschema:"new send time as an RFC 3339 timestamp, e.g. 2025-06-01T09:00:00Z"`
+}
+
+type listSuppressionsArgs struct {
+	Address  string `json:"address,omitempty" jsonschema:"part of the address to search for"`
+	Category string `json:"category,omitempty" jsonschema:"only suppressions for this template category"`
+	Reason   string `json:"reason,omitempty" jsonschema:"bounce, complaint, unsubscribe or manual"`
+}
+
+type suppressAddressArgs struct {
+	Address   string `json:"address" jsonschema:"email address to stop sending to"`
+	Category  string `json:"category,omitempty" jsonschema:"only suppress templates of this category; every template when empty"`
+	Reason    string `json:"reason,omitempty" jsonschema:"bounce, complaint, unsubscribe or manual (the default)"`
+	ExpiresAt string `json:"expires_at,omitempty" jsonschema:"when the suppression ends, as an RFC 3339 timestamp; never when empty"`
+}
+
+type unsuppressAddressArgs struct {
+	Address  string `json:"address" jsonschema:"suppressed email address"`
+	Category string `json:"category,omitempty" jsonschema:"category the address was suppressed for; empty for every template"`
+}
+
 // RegisterMCPTools registers all MCP tools for the email platform.
-func RegisterMCPTools(s mcp.McpServer, renderer *mjml.Renderer, q *queue.Queue) {
+func RegisterMCPTools(s mcp.McpServer, renderer *mjml.Renderer, templates *template.Store, q *queue.Queue) {
 	registerRenderTool(s, renderer)
 	registerListTemplatesTool(s, renderer)
+	registerListTemplateVersionsTool(s, templates)
+	registerPublishTemplateVersionTool(s, templates)
+	registerRollbackTemplateTool(s, templates)
 	registerSendEmailTool(s, q)
 	registerGetEmailStatusTool(s, q)
+	registerCancelEmailTool(s, q)
+	registerRescheduleEmailTool(s, q)
+	registerRetryEmailTool(s, q)
+	registerListSuppressionsTool(s, q)
+	registerSuppressAddressTool(s, q)
+	registerUnsuppressAddressTool(s, q)
 }
 
 func registerRenderTool(s mcp.McpServer, renderer *mjml.Renderer) {
@@ -113,6 +167,94 @@ func registerListTemplatesTool(s mcp.McpServer, renderer *mjml.Renderer) {
 	})
 }
 
+func registerListTemplateVersionsTool(s mcp.McpServer, templates *template.Store) {
+	tool := &mcp.Tool{
+		Name:        "list_template_versions",
+		Description: "List the version history of a template, newest first, with the content of each version and which one is live.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args templateSlugArgs) (*mcp.CallToolResult, any, error) {
+		t, err := templates.Get(ctx, args.Template)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load template: %w", err)
+		}
+		if t == nil {
+			return nil, nil, fmt.Errorf("%w: %s", template.ErrNotFound, args.Template)
+		}
+
+		versions, err := templates.ListVersions(ctx, args.Template)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list template versions: %w", err)
+		}
+
+		result := map[string]any{
+			"template": args.Template,
+			"versions": versions,
+			"count":    len(versions),
+		}
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal result: %w", err)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: string(resultJSON)},
+			},
+		}, nil, nil
+	})
+}
+
+func registerPublishTemplateVersionTool(s mcp.McpServer, templates *template.Store) {
+	tool := &mcp.Tool{
+		Name:        "publish_template_version",
+		Description: "Make a version of a template live, such as a draft saved earlier. Emails sent afterwards use it.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args templateVersionArgs) (*mcp.CallToolResult, any, error) {
+		if _, err := templates.Publish(ctx, args.Template, args.Version); err != nil {
+			return nil, nil, fmt.Errorf("failed to publish template: %w", err)
+		}
+		return templateResult(ctx, templates, args.Template)
+	})
+}
+
+func registerRollbackTemplateTool(s mcp.McpServer, templates *template.Store) {
+	tool := &mcp.Tool{
+		Name:        "rollback_template",
+		Description: "Restore the content of an earlier template version. It is saved as a new version and made live, so the history is kept.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args templateVersionArgs) (*mcp.CallToolResult, any, error) {
+		if _, err := templates.Rollback(ctx, args.Template, args.Version); err != nil {
+			return nil, nil, fmt.Errorf("failed to roll back template: %w", err)
+		}
+		return templateResult(ctx, templates, args.Template)
+	})
+}
+
+// templateResult returns a template as it is after an operation.
+func templateResult(ctx context.Context, templates *template.Store, slug string) (*mcp.CallToolResult, any, error) {
+	t, err := templates.Get(ctx, slug)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load template: %w", err)
+	}
+	if t == nil {
+		return nil, nil, fmt.Errorf("%w: %s", template.ErrNotFound, slug)
+	}
+
+	resultJSON, err := json.Marshal(t)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: string(resultJSON)},
+		},
+	}, nil, nil
+}
+
 func registerSendEmailTool(s mcp.McpServer, q *queue.Queue) {
 	tool := &mcp.Tool{
 		Name:        "send_email",
@@ -139,11 +281,14 @@ func registerSendEmailTool(s mcp.McpServer, q *queue.Queue) {
 		}
 
 		job := queue.EmailJob{
-			TemplateSlug: args.Template,
-			Recipients:   args.To,
-			Subject:      args.Subject,
-			Data:         data,
-			Priority:     queue.PriorityNormal,
+			ID:              uuid.New().String(),
+			TemplateSlug:    args.Template,
+			TemplateVersion: args.Version,
+			Recipients:      args.To,
+			Subject:         args.Subject,
+			Data:            data,
+			Priority:        queue.PriorityNormal,
+			IdempotencyKey:  args.IdempotencyKey,
 		}
 
 		id, err := q.Enqueue(ctx, job)
@@ -157,6 +302,18 @@ func registerSendEmailTool(s mcp.McpServer, q *queue.Queue) {
 			"recipients": len(args.To),
 			"template":   args.Template,
 		}
+		if id != job.ID {
+			// A reused idempotency key: report the email it first created
+			existing, err := q.GetStatus(ctx, id)
+			if err != nil || existing == nil {
+				return nil, nil, fmt.Errorf("failed to get existing email %s: %v", id, err)
+			}
+			if existing.Status != "pending" {
+				result["status"] = existing.Status
+			}
+			result["recipients"] = len(existing.Addresses())
+			result["template"] = existing.TemplateSlug
+		}
 		resultJSON, err := json.Marshal(result)
 		if err != nil {
 			return nil, nil, fmt.Errorf("marshal result: %w", err)
@@ -184,16 +341,22 @@ func registerGetEmailStatusTool(s mcp.McpServer, q *queue.Queue) {
 		if job == nil {
 			return nil, nil, fmt.Errorf("email not found: %s", args.ID)
 		}
+		recipients, err := q.Recipients(ctx, args.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get recipients: %w", err)
+		}
 
 		result := map[string]any{
-			"id":         job.ID,
-			"template":   job.TemplateSlug,
-			"recipients": job.Recipients,
-			"subject":    job.Subject,
-			"status":     job.Status,
-			"attempts":   job.Attempts,
-			"error":      job.Error,
-			"created_at": job.CreatedAt,
+			"id":               job.ID,
+			"template":         job.TemplateSlug,
+			"template_version": job.TemplateVersion,
+			"recipients":       job.Recipients,
+			"subject":          job.Subject,
+			"status":           job.Status,
+			"attempts":         job.Attempts,
+			"error":            job.Error,
+			"deliveries":       recipients,
+			"created_at":       job.CreatedAt,
 		}
 		resultJSON, err := json.Marshal(result)
 		if err != nil {
@@ -208,6 +371,185 @@ func registerGetEmailStatusTool(s mcp.McpServer, q *queue.Queue) {
 	})
 }
 
+func registerCancelEmailTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "cancel_email",
+		Description: "Cancel a pending, scheduled or retrying email so it is not sent.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args emailIDArgs) (*mcp.CallToolResult, any, error) {
+		if err := q.Cancel(ctx, args.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to cancel email: %w", err)
+		}
+		return emailStatusResult(ctx, q, args.ID)
+	})
+}
+
+func registerRescheduleEmailTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "reschedule_email",
+		Description: "Move the send time of a pending, scheduled or retrying email.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args rescheduleEmailArgs) (*mcp.CallToolResult, any, error) {
+		at, err := time.Parse(time.RFC3339, args.ScheduledAt)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scheduled_at must be an RFC 3339 timestamp: %w", err)
+		}
+		if err := q.Reschedule(ctx, args.ID, at); err != nil {
+			return nil, nil, fmt.Errorf("failed to reschedule email: %w", err)
+		}
+		return emailStatusResult(ctx, q, args.ID)
+	})
+}
+
+func registerRetryEmailTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "retry_email",
+		Description: "Put a failed, partially sent or cancelled email back in the queue. Recipients already sent to are not sent to again.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args emailIDArgs) (*mcp.CallToolResult, any, error) {
+		if err := q.Retry(ctx, args.ID); err != nil {
+			return nil, nil, fmt.Errorf("failed to retry email: %w", err)
+		}
+		return emailStatusResult(ctx, q, args.ID)
+	})
+}
+
+func registerListSuppressionsTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "list_suppressions",
+		Description: "List the addresses emails are not sent to because they bounced, complained, unsubscribed or were suppressed by hand.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args listSuppressionsArgs) (*mcp.CallToolResult, any, error) {
+		list, err := q.Suppressions(ctx, queue.SuppressionFilter{
+			Address:  args.Address,
+			Category: args.Category,
+			Reason:   args.Reason,
+			Limit:    100,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list suppressions: %w", err)
+		}
+
+		result := map[string]any{
+			"suppressions": list,
+			"count":        len(list),
+		}
+		resultJSON, err := json.Marshal(result)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal result: %w", err)
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: string(resultJSON)},
+			},
+		}, nil, nil
+	})
+}
+
+func registerSuppressAddressTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "suppress_address",
+		Description: "Stop sending email to an address, for every template or only one category, such as for a GDPR erasure or opt-out request.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args suppressAddressArgs) (*mcp.CallToolResult, any, error) {
+		addr, err := mail.ParseAddress(args.Address)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid address %q: %w", args.Address, err)
+		}
+		switch args.Reason {
+		case "", queue.SuppressBounce, queue.SuppressComplaint, queue.SuppressUnsubscribe, queue.SuppressManual:
+		default:
+			return nil, nil, errors.New("reason must be bounce, complaint, unsubscribe or manual")
+		}
+
+		sup := queue.Suppression{
+			Address:  strings.ToLower(addr.Address),
+			Category: args.Category,
+			Reason:   args.Reason,
+			Source:   "mcp",
+		}
+		if args.ExpiresAt != "" {
+			at, err := time.Parse(time.RFC3339, args.ExpiresAt)
+			if err != nil {
+				return nil, nil, fmt.Errorf("expires_at must be an RFC 3339 timestamp: %w", err)
+			}
+			sup.ExpiresAt = &at
+		}
+		if err := q.Suppress(ctx, sup); err != nil {
+			return nil, nil, fmt.Errorf("failed to suppress address: %w", err)
+		}
+		return suppressionResult(sup.Address, sup.Category, true)
+	})
+}
+
+func registerUnsuppressAddressTool(s mcp.McpServer, q *queue.Queue) {
+	tool := &mcp.Tool{
+		Name:        "unsuppress_address",
+		Description: "Allow email to an address again by removing its suppression.",
+	}
+
+	mcp.AddTool(s, tool, func(ctx context.Context, req *mcp.CallToolRequest, args unsuppressAddressArgs) (*mcp.CallToolResult, any, error) {
+		if err := q.Unsuppress(ctx, args.Address, args.Category); err != nil {
+			return nil, nil, fmt.Errorf("failed to remove suppression: %w", err)
+		}
+		return suppressionResult(args.Address, args.Category, false)
+	})
+}
+
+// suppressionResult returns whether an address is suppressed after an
+// operation.
+func suppressionResult(address, category string, suppressed bool) (*mcp.CallToolResult, any, error) {
+	resultJSON, err := json.Marshal(map[string]any{
+		"address":    address,
+		"category":   category,
+		"suppressed": suppressed,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: string(resultJSON)},
+		},
+	}, nil, nil
+}
+
+// emailStatusResult returns the new status of an email after an operation.
+func emailStatusResult(ctx context.Context, q *queue.Queue, id string) (*mcp.CallToolResult, any, error) {
+	job, err := q.GetStatus(ctx, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get status: %w", err)
+	}
+	if job == nil {
+		return nil, nil, fmt.Errorf("email not found: %s", id)
+	}
+
+	result := map[string]any{
+		"id":     job.ID,
+		"status": job.Status,
+	}
+	if job.ScheduledAt != nil {
+		result["scheduled_at"] = job.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return nil, nil, fmt.Errorf("marshal result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: string(resultJSON)},
+		},
+	}, nil, nil
+}
+
 func getTemplateDescription(slug string) string {
 	descriptions := map[string]string{
 		"simple":                "Basic email template",
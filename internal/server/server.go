@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -10,11 +11,15 @@ import (
 	"github.com/joeblew999/plat-mjml/internal/handler"
 	"github.com/joeblew999/plat-mjml/internal/svc"
 	"github.com/joeblew999/plat-mjml/internal/ui"
+	"github.com/joeblew999/plat-mjml/pkg/bounce"
 	"github.com/joeblew999/plat-mjml/pkg/db"
 	"github.com/joeblew999/plat-mjml/pkg/delivery"
 	"github.com/joeblew999/plat-mjml/pkg/mail"
 	"github.com/joeblew999/plat-mjml/pkg/mjml"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/joeblew999/plat-mjml/pkg/unsubscribe"
+	"github.com/joeblew999/plat-mjml/pkg/webhook"
 	gomjml "github.com/preslavrachev/gomjml/mjml"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -45,9 +50,9 @@ func New(c Config) (*Server, error) {
 		mjml.WithCache(true),
 	)
 
-	// Load templates
-	if err := renderer.LoadTemplatesFromDir(c.Templates.Dir); err != nil {
-		return nil, fmt.Errorf("failed to load templates: %w", err)
+	// Load partials and layouts; the templates themselves come from the store
+	if err := renderer.LoadPartialsFromDir(c.Templates.Dir); err != nil {
+		return nil, fmt.Errorf("failed to load partials: %w", err)
 	}
 
 	// Open database
@@ -56,12 +61,38 @@ func New(c Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	// Create template store: seed it from the templates directory, then serve
+	// exactly its published templates, including runtime edits
+	templateStore := template.NewStore(database.DB, renderer)
+	imported, err := templateStore.ImportDir(context.Background(), c.Templates.Dir)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to import templates: %w", err)
+	}
+	if imported > 0 {
+		logx.Infow("Templates imported into database", logx.Field("count", imported))
+	}
+	if err := templateStore.Load(context.Background()); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to load templates from database: %w", err)
+	}
+
 	// Create queue
 	emailQueue, err := queue.NewQueue(database.DB, "emails", 2)
 	if err != nil {
 		database.Close()
 		return nil, fmt.Errorf("failed to create queue: %w", err)
 	}
+	if window, _ := time.ParseDuration(c.Delivery.IdempotencyWindow); window > 0 {
+		emailQueue.SetIdempotencyWindow(window)
+	}
+	emailQueue.SetCategoryFunc(func(ctx context.Context, slug string) string {
+		t, err := templateStore.Get(ctx, slug)
+		if err != nil || t == nil {
+			return ""
+		}
+		return t.Category
+	})
 
 	// Parse delivery config
 	retryBackoff, _ := time.ParseDuration(c.Delivery.RetryBackoff)
@@ -72,6 +103,10 @@ func New(c Config) (*Server, error) {
 	if maxBackoff == 0 {
 		maxBackoff = 4 * time.Hour
 	}
+	maxDeferral, _ := time.ParseDuration(c.Delivery.MaxDeferral)
+	if maxDeferral == 0 {
+		maxDeferral = 24 * time.Hour
+	}
 
 	// Create delivery engine
 	deliveryConfig := delivery.Config{
@@ -79,20 +114,119 @@ func New(c Config) (*Server, error) {
 		RetryBackoff: retryBackoff,
 		MaxBackoff:   maxBackoff,
 		RateLimit:    c.Delivery.RateLimit,
+		MaxDeferral:  maxDeferral,
 	}
 
+	// DKIM keys from config files, then the dkim_keys table, which wins
+	// for domains in both
+	var dkimKeys []*mail.DKIMKey
+	for _, k := range c.DKIM {
+		key, err := mail.LoadDKIMKey(k.Domain, k.Selector, k.KeyFile)
+		if err != nil {
+			database.Close()
+			return nil, fmt.Errorf("failed to load DKIM key: %w", err)
+		}
+		dkimKeys = append(dkimKeys, key)
+	}
+	storedKeys, err := delivery.LoadDKIMKeys(context.Background(), database.DB)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to load DKIM keys: %w", err)
+	}
+	dkimKeys = append(dkimKeys, storedKeys...)
+	var dkim *mail.DKIMSigner
+	if len(dkimKeys) > 0 {
+		dkim = mail.NewDKIMSigner(dkimKeys...)
+	}
+	for _, key := range dkimKeys {
+		record, _ := key.DKIMRecord()
+		logx.Infow("DKIM signing enabled",
+			logx.Field("domain", key.Domain),
+			logx.Field("dns", key.Selector+"._domainkey."+key.Domain),
+			logx.Field("record", record),
+		)
+	}
+
+	idleTimeout, _ := time.ParseDuration(c.SMTP.IdleTimeout)
 	smtpConfig := mail.Config{
-		SMTPHost:  c.SMTP.Host,
-		SMTPPort:  c.SMTP.Port,
-		Username:  c.SMTP.Username,
-		Password:  c.SMTP.Password,
-		FromEmail: c.SMTP.FromEmail,
-		FromName:  c.SMTP.FromName,
+		SMTPHost:    c.SMTP.Host,
+		SMTPPort:    c.SMTP.Port,
+		Username:    c.SMTP.Username,
+		Password:    c.SMTP.Password,
+		FromEmail:   c.SMTP.FromEmail,
+		FromName:    c.SMTP.FromName,
+		MaxConns:    c.SMTP.MaxConns,
+		IdleTimeout: idleTimeout,
+
+		TLSMode:            c.SMTP.TLS,
+		CAFile:             c.SMTP.CAFile,
+		InsecureSkipVerify: c.SMTP.InsecureSkipVerify,
+		Auth:               c.SMTP.Auth,
+		OAuthClientID:      c.SMTP.OAuthClientID,
+		OAuthClientSecret:  c.SMTP.OAuthClientSecret,
+		OAuthRefreshToken:  c.SMTP.OAuthRefreshToken,
+		OAuthTokenURL:      c.SMTP.OAuthTokenURL,
+
+		DKIM: dkim,
+	}
+	transport, err := mail.NewTransport(mail.TransportConfig{
+		Type:      c.Transport.Type,
+		Path:      c.Transport.Path,
+		Format:    c.Transport.Format,
+		Endpoint:  c.Transport.Endpoint,
+		APIKey:    c.Transport.APIKey,
+		SecretKey: c.Transport.SecretKey,
+		Region:    c.Transport.Region,
+	}, smtpConfig)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to create mail transport: %w", err)
+	}
+	deliveryEngine := delivery.NewEngine(emailQueue, renderer, templateStore, transport, deliveryConfig)
+
+	// Per-recipient unsubscribe links, served by the UI server
+	var links *unsubscribe.Links
+	if c.Unsubscribe.Secret != "" {
+		baseURL := c.Unsubscribe.BaseURL
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("http://%s:%d", c.UI.Host, c.UI.Port)
+		}
+		links = unsubscribe.NewLinks(c.Unsubscribe.Secret, baseURL, c.Unsubscribe.Categories)
+		deliveryEngine.SetUnsubscribeLinks(links)
+		logx.Infow("Unsubscribe links enabled",
+			logx.Field("url", baseURL+"/unsubscribe"),
+			logx.Field("categories", c.Unsubscribe.Categories),
+		)
+	}
+
+	// Route across the providers in smtp_providers, if any, falling back
+	// to the configured transport
+	providers, err := delivery.LoadProviders(context.Background(), database.DB, dkim)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to load mail providers: %w", err)
+	}
+	if len(providers) > 0 {
+		deliveryEngine.SetProviders(providers)
+		logx.Infow("Mail providers loaded", logx.Field("count", len(providers)))
 	}
-	deliveryEngine := delivery.NewEngine(emailQueue, renderer, smtpConfig, deliveryConfig)
+
+	// Webhook endpoints from config files, then the webhooks table, which
+	// wins for names in both
+	var endpoints []*webhook.Endpoint
+	for _, w := range c.Webhooks {
+		endpoints = append(endpoints, &webhook.Endpoint{Name: w.Name, URL: w.URL, Secret: w.Secret, Events: w.Events})
+	}
+	storedEndpoints, err := webhook.LoadEndpoints(context.Background(), database.DB)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to load webhooks: %w", err)
+	}
+	endpoints = append(endpoints, storedEndpoints...)
+	webhooks := webhook.NewDispatcher(database.DB, emailQueue, endpoints, webhook.DefaultConfig())
 
 	// Register MCP tools
-	RegisterMCPTools(mcpServer, renderer, emailQueue)
+	RegisterMCPTools(mcpServer, renderer, templateStore, emailQueue)
 
 	// Create UI rest server (Datastar web UI)
 	uiServer, err := rest.NewServer(c.UI.RestConf)
@@ -101,7 +235,7 @@ func New(c Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to create UI server: %w", err)
 	}
 
-	uiHandlers := ui.NewHandlers(renderer, emailQueue)
+	uiHandlers := ui.NewHandlers(renderer, emailQueue, templateStore, links)
 	uiServer.AddRoutes(uiHandlers.Routes())
 	uiServer.AddRoutes(uiHandlers.SSERoutes(), rest.WithSSE())
 
@@ -112,7 +246,7 @@ func New(c Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to create API server: %w", err)
 	}
 
-	apiCtx := svc.NewServiceContext(renderer, emailQueue)
+	apiCtx := svc.NewServiceContext(renderer, emailQueue, templateStore, webhooks)
 	handler.RegisterHandlers(apiServer, apiCtx)
 
 	// Expose Prometheus metrics endpoint
@@ -122,6 +256,22 @@ func New(c Config) (*Server, error) {
 		Handler: promhttp.Handler().ServeHTTP,
 	})
 
+	// Receive bounces and complaints: raw DSN/ARF reports and provider
+	// webhooks over HTTP, when a token protects them, and the bounce
+	// mailbox if there is one
+	bounces := bounce.NewProcessor(emailQueue)
+	if c.Bounces.Token != "" {
+		for _, source := range []string{bounce.SourceDSN, bounce.SourceSES, bounce.SourceSendGrid, bounce.SourcePostmark} {
+			apiServer.AddRoute(rest.Route{
+				Method:  http.MethodPost,
+				Path:    "/api/v1/bounces/" + source,
+				Handler: bounce.Handler(bounces, source, c.Bounces.Token),
+			})
+		}
+	} else {
+		logx.Info("Bounce endpoints disabled: bounces.token is not set")
+	}
+
 	// Register cleanup via proc shutdown listeners
 	proc.AddShutdownListener(func() {
 		logx.Info("Closing database")
@@ -131,9 +281,18 @@ func New(c Config) (*Server, error) {
 		gomjml.StopASTCacheCleanup()
 	})
 
-	// Build service group: delivery + UI + API + MCP (stopped in reverse order)
+	// Build service group: webhooks + delivery + bounce mailbox + UI + API +
+	// MCP (stopped in reverse order)
 	group := service.NewServiceGroup()
+	group.Add(webhooks)
 	group.Add(newDeliveryService(deliveryEngine, 2))
+	if c.Bounces.Maildir != "" {
+		interval, _ := time.ParseDuration(c.Bounces.PollInterval)
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		group.Add(bounce.NewMailbox(c.Bounces.Maildir, interval, bounces))
+	}
 	group.Add(uiServer)
 	group.Add(apiServer)
 	group.Add(mcpServer)
@@ -144,6 +303,7 @@ func New(c Config) (*Server, error) {
 		logx.Field("api", fmt.Sprintf("http://%s:%d/api/v1", c.API.Host, c.API.Port)),
 		logx.Field("templates", c.Templates.Dir),
 		logx.Field("database", c.Database.Path),
+		logx.Field("transport", c.Transport.Type),
 	)
 	logx.Infof("To add to Claude: claude mcp add plat-mjml -- npx -y mcp-remote http://localhost:%d/sse", c.Port)
 
@@ -6,16 +6,22 @@ package svc
 import (
 	"github.com/joeblew999/plat-mjml/pkg/mjml"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/joeblew999/plat-mjml/pkg/webhook"
 )
 
 type ServiceContext struct {
-	Renderer *mjml.Renderer
-	Queue    *queue.Queue
+	Renderer  *mjml.Renderer
+	Queue     *queue.Queue
+	Templates *template.Store
+	Webhooks  *webhook.Dispatcher
 }
 
-func NewServiceContext(renderer *mjml.Renderer, q *queue.Queue) *ServiceContext {
+func NewServiceContext(renderer *mjml.Renderer, q *queue.Queue, templates *template.Store, webhooks *webhook.Dispatcher) *ServiceContext {
 	return &ServiceContext{
-		Renderer: renderer,
-		Queue:    q,
+		Renderer:  renderer,
+		Queue:     q,
+		Templates: templates,
+		Webhooks:  webhooks,
 	}
 }
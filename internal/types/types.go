@@ -3,19 +3,120 @@
 
 package types
 
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,optional"`
+	Content     string `json:"content"`             // base64-encoded file content
+	ContentId   string `json:"content_id,optional"` // embed inline, referenced as cid:<content_id>
+}
+
+type CreateSuppressionRequest struct {
+	Address   string `json:"address"`
+	Category  string `json:"category,optional"`   // suppress only this category
+	Reason    string `json:"reason,optional"`     // defaults to manual
+	ExpiresAt string `json:"expires_at,optional"` // RFC 3339; never expires when empty
+}
+
+type CreateTemplateRequest struct {
+	Slug     string                 `json:"slug"`
+	Name     string                 `json:"name,optional"`
+	Content  string                 `json:"content"`
+	Text     string                 `json:"text,optional"` // plain-text companion; derived from the HTML when empty
+	Status   string                 `json:"status,optional"`
+	Category string                 `json:"category,optional"`
+	Metadata map[string]interface{} `json:"metadata,optional"`
+}
+
+type DeadLetterBulkRequest struct {
+	Ids      []string `json:"ids,optional"`
+	Class    string   `json:"class,optional"`
+	Template string   `json:"template,optional"`
+	Tenant   string   `json:"tenant,optional"`
+	All      bool     `json:"all,optional"` // required to select every dead letter
+}
+
+type DeadLetterBulkResponse struct {
+	Count  int      `json:"count"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type DeadLetterItem struct {
+	Email    GetEmailStatusResponse `json:"email"`
+	Class    string                 `json:"class"` // class of the failure that ended delivery
+	Error    string                 `json:"error,omitempty"`
+	Attempts int                    `json:"attempts"`
+	FailedAt string                 `json:"failed_at"`
+	// Queued payload and failure history; only returned for a single email
+	Payload map[string]interface{} `json:"payload,omitempty"`
+	History []FailureItem          `json:"history,omitempty"`
+}
+
+type DeleteSuppressionRequest struct {
+	Address  string `path:"address"`
+	Category string `form:"category,optional"`
+}
+
+type DeleteSuppressionResponse struct {
+	Address  string `json:"address"`
+	Category string `json:"category,omitempty"`
+	Deleted  bool   `json:"deleted"`
+}
+
+type DeleteTemplateRequest struct {
+	Slug string `path:"slug"`
+}
+
+type DeleteTemplateResponse struct {
+	Slug    string `json:"slug"`
+	Deleted bool   `json:"deleted"`
+}
+
+type EmailEvent struct {
+	Type      string                 `json:"type"` // queued, rendered, processing, deferred, sent, failed, cancelled, bounced, opened or clicked
+	Timestamp string                 `json:"timestamp"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+type EmailEventsResponse struct {
+	Id     string       `json:"id"`
+	Events []EmailEvent `json:"events"`
+	Count  int          `json:"count"`
+}
+
+type EmailIdRequest struct {
+	Id string `path:"id"`
+}
+
+type FailureItem struct {
+	Attempt  int    `json:"attempt"`
+	Class    string `json:"class"`
+	Error    string `json:"error,omitempty"`
+	FailedAt string `json:"failed_at"`
+}
+
 type GetEmailStatusRequest struct {
 	Id string `path:"id"`
 }
 
 type GetEmailStatusResponse struct {
-	Id         string   `json:"id"`
-	Template   string   `json:"template"`
-	Recipients []string `json:"recipients"`
-	Subject    string   `json:"subject"`
-	Status     string   `json:"status"`
-	Attempts   int      `json:"attempts"`
-	Error      string   `json:"error,omitempty"`
-	CreatedAt  string   `json:"created_at"`
+	Id              string            `json:"id"`
+	Template        string            `json:"template"`
+	TemplateVersion int               `json:"template_version,omitempty"`
+	Recipients      []string          `json:"recipients"`
+	Cc              []string          `json:"cc,omitempty"`
+	Bcc             []string          `json:"bcc,omitempty"`
+	ReplyTo         string            `json:"reply_to,omitempty"`
+	Subject         string            `json:"subject"`
+	Status          string            `json:"status"`
+	Priority        string            `json:"priority"`
+	Attempts        int               `json:"attempts"`
+	Error           string            `json:"error,omitempty"`
+	ScheduledAt     string            `json:"scheduled_at,omitempty"`
+	Tenant          string            `json:"tenant,omitempty"`
+	Provider        string            `json:"provider,omitempty"` // mail provider the email was sent through
+	IdempotencyKey  string            `json:"idempotency_key,omitempty"`
+	CreatedAt       string            `json:"created_at"`
+	Deliveries      []RecipientStatus `json:"deliveries,omitempty"`
 }
 
 type GetTemplateRequest struct {
@@ -23,8 +124,33 @@ type GetTemplateRequest struct {
 }
 
 type GetTemplateResponse struct {
-	Slug        string `json:"slug"`
-	Description string `json:"description"`
+	Slug          string                 `json:"slug"`
+	Description   string                 `json:"description"`
+	Name          string                 `json:"name,omitempty"`
+	Content       string                 `json:"content,omitempty"`
+	Text          string                 `json:"text,omitempty"` // plain-text companion, a Go text/template
+	Version       int                    `json:"version,omitempty"`
+	LatestVersion int                    `json:"latest_version,omitempty"`
+	Status        string                 `json:"status,omitempty"`
+	Category      string                 `json:"category,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	UpdatedAt     string                 `json:"updated_at,omitempty"`
+}
+
+type ImportSuppressionsResponse struct {
+	Imported int `json:"imported"`
+}
+
+type ListDeadLettersRequest struct {
+	Class    string `form:"class,optional"` // transient, throttled or permanent
+	Template string `form:"template,optional"`
+	Tenant   string `form:"tenant,optional"`
+	Limit    int    `form:"limit,default=50"`
+}
+
+type ListDeadLettersResponse struct {
+	DeadLetters []DeadLetterItem `json:"dead_letters"`
+	Count       int              `json:"count"`
 }
 
 type ListEmailsRequest struct {
@@ -37,11 +163,83 @@ type ListEmailsResponse struct {
 	Count  int                      `json:"count"`
 }
 
+type ListSuppressionsRequest struct {
+	Address  string `form:"address,optional"` // part of the address
+	Category string `form:"category,optional"`
+	Reason   string `form:"reason,optional"`
+	Limit    int    `form:"limit,default=50"`
+}
+
+type ListSuppressionsResponse struct {
+	Suppressions []SuppressionItem `json:"suppressions"`
+	Count        int               `json:"count"`
+}
+
+type ListTemplateVersionsRequest struct {
+	Slug string `path:"slug"`
+}
+
+type ListTemplateVersionsResponse struct {
+	Slug     string            `json:"slug"`
+	Versions []TemplateVersion `json:"versions"`
+	Count    int               `json:"count"`
+}
+
 type ListTemplatesResponse struct {
 	Templates []TemplateItem `json:"templates"`
 	Count     int            `json:"count"`
 }
 
+type ListWebhookDeliveriesRequest struct {
+	Webhook string `form:"webhook,optional"`
+	Status  string `form:"status,optional"` // pending, retry, delivered or failed
+	EmailId string `form:"email_id,optional"`
+	Limit   int    `form:"limit,default=50"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Count      int               `json:"count"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []WebhookItem `json:"webhooks"`
+	Count    int           `json:"count"`
+}
+
+type PreviewTemplateRequest struct {
+	Slug    string                 `path:"slug"`
+	Data    map[string]interface{} `json:"data,optional"`
+	Mjml    string                 `json:"mjml,optional"`
+	Version int                    `json:"version,optional"`
+}
+
+type PreviewTemplateResponse struct {
+	Html     string  `json:"html"`
+	Text     string  `json:"text"`
+	Subject  string  `json:"subject"`
+	Template string  `json:"template"`
+	Version  int     `json:"version,omitempty"`
+	Size     int     `json:"size"`
+	RenderMs float64 `json:"render_ms"`
+}
+
+type PublishTemplateVersionRequest struct {
+	Slug    string `path:"slug"`
+	Version int    `path:"version"`
+}
+
+type RecipientStatus struct {
+	Address   string `json:"address"`
+	Status    string `json:"status"` // pending, retry, sent or failed
+	Attempts  int    `json:"attempts"`
+	Provider  string `json:"provider,omitempty"`
+	MessageId string `json:"message_id,omitempty"` // provider message ID, or the Message-ID header
+	Error     string `json:"error,omitempty"`
+	SentAt    string `json:"sent_at,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
 type RenderTemplateRequest struct {
 	Slug string `path:"slug"`
 }
@@ -52,17 +250,39 @@ type RenderTemplateResponse struct {
 	Size     int    `json:"size"`
 }
 
+type RescheduleEmailRequest struct {
+	Id          string `path:"id"`
+	ScheduledAt string `json:"scheduled_at"` // RFC 3339; a past time sends as soon as possible
+}
+
+type RollbackTemplateRequest struct {
+	Slug    string `path:"slug"`
+	Version int    `json:"version"`
+}
+
 type SendEmailRequest struct {
-	Template string   `json:"template"`
-	To       []string `json:"to"`
-	Subject  string   `json:"subject"`
+	Template        string                 `json:"template"`
+	TemplateVersion int                    `json:"template_version,optional"`
+	To              []string               `json:"to"`
+	Cc              []string               `json:"cc,optional"`
+	Bcc             []string               `json:"bcc,optional"`
+	ReplyTo         string                 `json:"reply_to,optional"`
+	Subject         string                 `json:"subject"`
+	Data            map[string]interface{} `json:"data,optional"`
+	Priority        string                 `json:"priority,optional"` // low, normal or high
+	ScheduledAt     string                 `json:"scheduled_at,optional"`
+	Headers         map[string]string      `json:"headers,optional"`
+	Attachments     []Attachment           `json:"attachments,optional"`
+	Tenant          string                 `json:"tenant,optional"`            // routes to the tenant's mail providers
+	IdempotencyKey  string                 `header:"Idempotency-Key,optional"` // retries with the same key return the original email
 }
 
 type SendEmailResponse struct {
-	Id         string `json:"id"`
-	Status     string `json:"status"`
-	Recipients int    `json:"recipients"`
-	Template   string `json:"template"`
+	Id          string `json:"id"`
+	Status      string `json:"status"`
+	Recipients  int    `json:"recipients"`
+	Template    string `json:"template"`
+	ScheduledAt string `json:"scheduled_at,omitempty"`
 }
 
 type StatsResponse struct {
@@ -70,7 +290,78 @@ type StatsResponse struct {
 	Total int            `json:"total"`
 }
 
+type SuppressionItem struct {
+	Address   string `json:"address"`
+	Category  string `json:"category,omitempty"` // empty for every category
+	Reason    string `json:"reason"`             // bounce, complaint, unsubscribe or manual
+	Source    string `json:"source,omitempty"`
+	EmailId   string `json:"email_id,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+type TemplateError struct {
+	Stage   string `json:"stage"`
+	Line    int    `json:"line,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
 type TemplateItem struct {
 	Slug        string `json:"slug"`
 	Description string `json:"description"`
 }
+
+type TemplateVersion struct {
+	Version   int    `json:"version"`
+	Content   string `json:"content"`
+	Text      string `json:"text,omitempty"`
+	CreatedAt string `json:"created_at"`
+	Live      bool   `json:"live"`
+}
+
+type UpdateTemplateRequest struct {
+	Slug     string                 `path:"slug"`
+	Name     string                 `json:"name,optional"`
+	Content  string                 `json:"content,optional"`
+	Text     string                 `json:"text,optional"` // plain-text companion; kept when empty
+	Status   string                 `json:"status,optional"`
+	Category string                 `json:"category,optional"`
+	Metadata map[string]interface{} `json:"metadata,optional"`
+	Draft    bool                   `json:"draft,optional"`
+}
+
+type ValidateTemplateRequest struct {
+	Content string                 `json:"content"`
+	Data    map[string]interface{} `json:"data,optional"`
+}
+
+type ValidateTemplateResponse struct {
+	Valid bool `json:"valid"`
+}
+
+type WebhookDelivery struct {
+	Id           string                 `json:"id"`
+	Webhook      string                 `json:"webhook"`
+	Url          string                 `json:"url"`
+	EventId      string                 `json:"event_id"`
+	EventType    string                 `json:"event_type"`
+	EmailId      string                 `json:"email_id"`
+	Payload      map[string]interface{} `json:"payload"` // the signed JSON body
+	Status       string                 `json:"status"`
+	Attempts     int                    `json:"attempts"`
+	ResponseCode int                    `json:"response_code,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	CreatedAt    string                 `json:"created_at"`
+	DeliveredAt  string                 `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveryIdRequest struct {
+	Id string `path:"id"`
+}
+
+type WebhookItem struct {
+	Name   string   `json:"name"`
+	Url    string   `json:"url"`
+	Events []string `json:"events,omitempty"` // all events when empty
+}
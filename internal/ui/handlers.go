@@ -2,13 +2,20 @@ package ui
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"net/http"
+	"net/mail"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/joeblew999/plat-mjml/pkg/mjml"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/joeblew999/plat-mjml/pkg/unsubscribe"
 	"github.com/starfederation/datastar-go/datastar"
 	"github.com/zeromicro/go-zero/core/logx"
 	"github.com/zeromicro/go-zero/rest"
@@ -17,27 +24,52 @@ import (
 
 // Handlers provides HTTP handlers for the UI.
 type Handlers struct {
-	renderer *mjml.Renderer
-	queue    *queue.Queue
+	renderer  *mjml.Renderer
+	queue     *queue.Queue
+	templates *template.Store
+	links     *unsubscribe.Links
 }
 
-// NewHandlers creates new UI handlers.
-func NewHandlers(renderer *mjml.Renderer, q *queue.Queue) *Handlers {
+// NewHandlers creates new UI handlers. The template store lists the
+// categories on the preference page. links is optional; without it the
+// unsubscribe and preference pages are not served.
+func NewHandlers(renderer *mjml.Renderer, q *queue.Queue, templates *template.Store, links *unsubscribe.Links) *Handlers {
 	return &Handlers{
-		renderer: renderer,
-		queue:    q,
+		renderer:  renderer,
+		queue:     q,
+		templates: templates,
+		links:     links,
 	}
 }
 
 // Routes returns the standard UI routes for registration with rest.Server.
 func (h *Handlers) Routes() []rest.Route {
-	return []rest.Route{
+	routes := []rest.Route{
 		{Method: http.MethodGet, Path: "/", Handler: h.handleDashboard},
 		{Method: http.MethodGet, Path: "/templates", Handler: h.handleTemplates},
 		{Method: http.MethodGet, Path: "/queue", Handler: h.handleQueue},
 		{Method: http.MethodGet, Path: "/send", Handler: h.handleSendPage},
+		{Method: http.MethodGet, Path: "/suppressions", Handler: h.handleSuppressionsPage},
+		{Method: http.MethodGet, Path: "/suppressions/export", Handler: h.handleExportSuppressions},
 		{Method: http.MethodPost, Path: "/api/send", Handler: h.handleSend},
+		{Method: http.MethodPost, Path: "/api/queue/:id/cancel", Handler: h.handleCancel},
+		{Method: http.MethodPost, Path: "/api/queue/:id/reschedule", Handler: h.handleReschedule},
+		{Method: http.MethodPost, Path: "/api/queue/:id/retry", Handler: h.handleRetry},
+		{Method: http.MethodPost, Path: "/api/suppressions", Handler: h.handleSuppress},
+		{Method: http.MethodPost, Path: "/api/suppressions/remove", Handler: h.handleUnsuppress},
+		{Method: http.MethodPost, Path: "/api/suppressions/import", Handler: h.handleImportSuppressions},
+	}
+	if h.links != nil {
+		// Public: reached from the links and List-Unsubscribe headers in
+		// sent emails
+		routes = append(routes,
+			rest.Route{Method: http.MethodGet, Path: "/unsubscribe", Handler: h.handleUnsubscribePage},
+			rest.Route{Method: http.MethodPost, Path: "/unsubscribe", Handler: h.handleUnsubscribe},
+			rest.Route{Method: http.MethodGet, Path: "/preferences", Handler: h.handlePreferencesPage},
+			rest.Route{Method: http.MethodPost, Path: "/preferences", Handler: h.handleSavePreferences},
+		)
 	}
+	return routes
 }
 
 // SSERoutes returns the SSE-based API routes (require rest.WithSSE option).
@@ -45,6 +77,8 @@ func (h *Handlers) SSERoutes() []rest.Route {
 	return []rest.Route{
 		{Method: http.MethodGet, Path: "/api/stats", Handler: h.handleStats},
 		{Method: http.MethodGet, Path: "/api/queue", Handler: h.handleQueueAPI},
+		{Method: http.MethodGet, Path: "/api/queue/:id/events", Handler: h.handleEventsAPI},
+		{Method: http.MethodGet, Path: "/api/suppressions", Handler: h.handleSuppressionsAPI},
 		{Method: http.MethodGet, Path: "/api/preview/:slug", Handler: h.handlePreview},
 	}
 }
@@ -79,6 +113,149 @@ func (h *Handlers) handleSendPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handlers) handleSuppressionsPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := SuppressionsPage().Render(w); err != nil {
+		logx.Errorf("render suppressions page: %v", err)
+	}
+}
+
+func (h *Handlers) handleExportSuppressions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+	if err := h.queue.ExportSuppressions(r.Context(), w); err != nil {
+		logx.Errorf("export suppressions: %v", err)
+	}
+}
+
+// handleUnsubscribePage asks the recipient to confirm, as link scanners
+// and prefetchers follow links in emails with GET.
+func (h *Handlers) handleUnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	claims, err := h.links.Verify(token)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	if err := UnsubscribePage(claims, token, false).Render(w); err != nil {
+		logx.Errorf("render unsubscribe page: %v", err)
+	}
+}
+
+// handleUnsubscribe records an opt-out, confirmed on the unsubscribe page
+// or posted by a mail client for List-Unsubscribe-Post (RFC 8058).
+func (h *Handlers) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	claims, err := h.links.Verify(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source := unsubscribe.SourceLink
+	if r.FormValue("List-Unsubscribe") == "One-Click" {
+		source = unsubscribe.SourceOneClick
+	}
+	if err := unsubscribe.Record(r.Context(), h.queue, claims, source); err != nil {
+		logx.Errorf("record unsubscribe of %s: %v", claims.Address, err)
+		http.Error(w, "Could not unsubscribe, please try again later", http.StatusInternalServerError)
+		return
+	}
+	logx.Infow("Recipient unsubscribed",
+		logx.Field("address", claims.Address),
+		logx.Field("category", claims.Category),
+		logx.Field("email", claims.EmailID),
+		logx.Field("source", source),
+	)
+
+	if source == unsubscribe.SourceOneClick {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := UnsubscribePage(claims, token, true).Render(w); err != nil {
+		logx.Errorf("render unsubscribe page: %v", err)
+	}
+}
+
+func (h *Handlers) handlePreferencesPage(w http.ResponseWriter, r *http.Request) {
+	h.runPreferences(w, r, nil)
+}
+
+// handleSavePreferences stores the categories checked on the preference
+// page as subscribed and the others as opted out.
+func (h *Handlers) handleSavePreferences(w http.ResponseWriter, r *http.Request) {
+	h.runPreferences(w, r, func(info *PreferencesInfo) error {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		checked := make(map[string]bool)
+		for _, category := range r.PostForm["subscribed"] {
+			checked[category] = true
+		}
+		prefs := make(map[string]bool, len(info.Categories))
+		for _, category := range info.Categories {
+			prefs[category] = checked[category]
+		}
+		return h.queue.SetPreferences(r.Context(), info.Address, prefs)
+	})
+}
+
+// runPreferences verifies the link to the preference page, applies save
+// if given, and renders the page.
+func (h *Handlers) runPreferences(w http.ResponseWriter, r *http.Request, save func(info *PreferencesInfo) error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	token := r.URL.Query().Get("token")
+	claims, err := h.links.Verify(token)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := PreferencesPage(nil).Render(w); err != nil {
+			logx.Errorf("render preferences page: %v", err)
+		}
+		return
+	}
+
+	ctx := r.Context()
+	info := &PreferencesInfo{Address: claims.Address, Token: token}
+	if h.templates != nil {
+		if info.Categories, err = h.templates.Categories(ctx); err != nil {
+			logx.Errorf("list template categories: %v", err)
+		}
+	}
+	if save != nil {
+		if err := save(info); err != nil {
+			logx.Errorf("save preferences of %s: %v", claims.Address, err)
+			http.Error(w, "Could not save your preferences, please try again later", http.StatusInternalServerError)
+			return
+		}
+		info.Saved = true
+		logx.Infow("Recipient preferences saved", logx.Field("address", claims.Address))
+	}
+
+	prefs, err := h.queue.Preferences(ctx, claims.Address)
+	if err != nil {
+		logx.Errorf("load preferences of %s: %v", claims.Address, err)
+		http.Error(w, "Could not load your preferences, please try again later", http.StatusInternalServerError)
+		return
+	}
+	info.Preferences = prefs
+	// Categories that have no templates any more but were chosen stay
+	for category := range prefs {
+		if !slices.Contains(info.Categories, category) {
+			info.Categories = append(info.Categories, category)
+		}
+	}
+	slices.Sort(info.Categories)
+
+	if s, err := h.queue.Suppressed(ctx, []string{claims.Address}, ""); err == nil {
+		info.Unsubscribed = s[claims.Address] != nil && s[claims.Address].Reason == queue.SuppressUnsubscribe
+	}
+
+	if err := PreferencesPage(info).Render(w); err != nil {
+		logx.Errorf("render preferences page: %v", err)
+	}
+}
+
 func (h *Handlers) handleStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := h.queue.Stats(r.Context())
 	if err != nil {
@@ -114,6 +291,40 @@ func (h *Handlers) handleQueueAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (h *Handlers) handleEventsAPI(w http.ResponseWriter, r *http.Request) {
+	id := pathvar.Vars(r)["id"]
+
+	events, err := h.queue.Events(r.Context(), id)
+	if err != nil {
+		h.sendDatastarError(w, r, err)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.PatchElementf(`<div id="timeline" class="timeline">%s</div>`, renderTimeline(id, events)); err != nil {
+		logx.Errorf("datastar patch timeline: %v", err)
+	}
+}
+
+func (h *Handlers) handleSuppressionsAPI(w http.ResponseWriter, r *http.Request) {
+	list, err := h.queue.Suppressions(r.Context(), queue.SuppressionFilter{
+		Address: r.URL.Query().Get("q"),
+		Limit:   100,
+	})
+	if err != nil {
+		h.sendDatastarError(w, r, err)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.PatchElementf(`<div id="suppression-items">%s</div>`, renderSuppressionItems(list)); err != nil {
+		logx.Errorf("datastar patch suppression items: %v", err)
+	}
+	if err := sse.MarshalAndPatchSignals(map[string]any{"loading": false}); err != nil {
+		logx.Errorf("datastar patch signals: %v", err)
+	}
+}
+
 func (h *Handlers) handlePreview(w http.ResponseWriter, r *http.Request) {
 	slug := pathvar.Vars(r)["slug"]
 	if slug == "" {
@@ -179,6 +390,142 @@ func (h *Handlers) handleSend(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// queueAction is the body posted by the queue page's action buttons.
+type queueAction struct {
+	Filter      string `json:"filter"`
+	ScheduledAt string `json:"scheduled_at"`
+}
+
+func (h *Handlers) handleCancel(w http.ResponseWriter, r *http.Request) {
+	h.runQueueAction(w, r, func(id string, _ queueAction) (string, error) {
+		return "Cancelled email " + id, h.queue.Cancel(r.Context(), id)
+	})
+}
+
+func (h *Handlers) handleReschedule(w http.ResponseWriter, r *http.Request) {
+	h.runQueueAction(w, r, func(id string, req queueAction) (string, error) {
+		at, err := time.Parse(time.RFC3339, req.ScheduledAt)
+		if err != nil {
+			return "", errors.New("pick a new send time first")
+		}
+		return "Rescheduled email " + id + " to " + at.Local().Format("Jan 2 15:04"), h.queue.Reschedule(r.Context(), id, at)
+	})
+}
+
+func (h *Handlers) handleRetry(w http.ResponseWriter, r *http.Request) {
+	h.runQueueAction(w, r, func(id string, _ queueAction) (string, error) {
+		return "Requeued email " + id, h.queue.Retry(r.Context(), id)
+	})
+}
+
+// runQueueAction applies action to the email in the request path, then
+// reports the outcome and refreshes the queue list for the current filter.
+func (h *Handlers) runQueueAction(w http.ResponseWriter, r *http.Request, action func(id string, req queueAction) (string, error)) {
+	var req queueAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendDatastarSignals(w, r, map[string]any{"result": "Error: Invalid request"})
+		return
+	}
+
+	result, err := action(pathvar.Vars(r)["id"], req)
+	if err != nil {
+		result = "Error: " + err.Error()
+	}
+
+	status := req.Filter
+	if status == "all" {
+		status = ""
+	}
+	jobs, err := h.queue.List(r.Context(), status, 50)
+	if err != nil {
+		h.sendDatastarError(w, r, err)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.PatchElementf(`<div id="queue-items">%s</div>`, renderQueueItems(jobs)); err != nil {
+		logx.Errorf("datastar patch queue items: %v", err)
+	}
+	if err := sse.MarshalAndPatchSignals(map[string]any{"result": result}); err != nil {
+		logx.Errorf("datastar patch signals: %v", err)
+	}
+}
+
+// suppressionAction is the body posted by the suppressions page.
+type suppressionAction struct {
+	Search    string `json:"search"`
+	Address   string `json:"address"`
+	Category  string `json:"category"`
+	Reason    string `json:"reason"`
+	ExpiresAt string `json:"expires_at"`
+	CSV       string `json:"csv"`
+}
+
+func (h *Handlers) handleSuppress(w http.ResponseWriter, r *http.Request) {
+	h.runSuppressionAction(w, r, func(req suppressionAction) (string, error) {
+		addr, err := mail.ParseAddress(req.Address)
+		if err != nil {
+			return "", fmt.Errorf("invalid address %q", req.Address)
+		}
+		s := queue.Suppression{
+			Address:  addr.Address,
+			Category: strings.TrimSpace(req.Category),
+			Reason:   req.Reason,
+			Source:   "ui",
+		}
+		if req.ExpiresAt != "" {
+			at, err := time.Parse(time.RFC3339, req.ExpiresAt)
+			if err != nil {
+				return "", fmt.Errorf("invalid expiry %q", req.ExpiresAt)
+			}
+			s.ExpiresAt = &at
+		}
+		return "Suppressed " + addr.Address, h.queue.Suppress(r.Context(), s)
+	})
+}
+
+func (h *Handlers) handleUnsuppress(w http.ResponseWriter, r *http.Request) {
+	h.runSuppressionAction(w, r, func(req suppressionAction) (string, error) {
+		return "Removed " + req.Address, h.queue.Unsuppress(r.Context(), req.Address, req.Category)
+	})
+}
+
+func (h *Handlers) handleImportSuppressions(w http.ResponseWriter, r *http.Request) {
+	h.runSuppressionAction(w, r, func(req suppressionAction) (string, error) {
+		n, err := h.queue.ImportSuppressions(r.Context(), strings.NewReader(req.CSV), "import")
+		return fmt.Sprintf("Imported %d suppressions", n), err
+	})
+}
+
+// runSuppressionAction applies action to the suppression list, then reports
+// the outcome and refreshes the list for the current search.
+func (h *Handlers) runSuppressionAction(w http.ResponseWriter, r *http.Request, action func(req suppressionAction) (string, error)) {
+	var req suppressionAction
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendDatastarSignals(w, r, map[string]any{"result": "Error: Invalid request"})
+		return
+	}
+
+	result, err := action(req)
+	if err != nil {
+		result = "Error: " + err.Error()
+	}
+
+	list, err := h.queue.Suppressions(r.Context(), queue.SuppressionFilter{Address: req.Search, Limit: 100})
+	if err != nil {
+		h.sendDatastarError(w, r, err)
+		return
+	}
+
+	sse := datastar.NewSSE(w, r)
+	if err := sse.PatchElementf(`<div id="suppression-items">%s</div>`, renderSuppressionItems(list)); err != nil {
+		logx.Errorf("datastar patch suppression items: %v", err)
+	}
+	if err := sse.MarshalAndPatchSignals(map[string]any{"result": result}); err != nil {
+		logx.Errorf("datastar patch signals: %v", err)
+	}
+}
+
 func (h *Handlers) getTemplateInfos() []TemplateInfo {
 	slugs := h.renderer.ListTemplates()
 	infos := make([]TemplateInfo, 0, len(slugs))
@@ -222,6 +569,7 @@ func renderQueueItems(jobs []*queue.EmailJob) string {
 	b.WriteString(`<th style="text-align:left;padding:0.75rem 1rem;border-bottom:2px solid var(--border);color:var(--text-muted);font-size:0.875rem;">Subject</th>`)
 	b.WriteString(`<th style="text-align:left;padding:0.75rem 1rem;border-bottom:2px solid var(--border);color:var(--text-muted);font-size:0.875rem;">Status</th>`)
 	b.WriteString(`<th style="text-align:left;padding:0.75rem 1rem;border-bottom:2px solid var(--border);color:var(--text-muted);font-size:0.875rem;">Created</th>`)
+	b.WriteString(`<th style="text-align:left;padding:0.75rem 1rem;border-bottom:2px solid var(--border);color:var(--text-muted);font-size:0.875rem;"></th>`)
 	b.WriteString(`</tr></thead><tbody>`)
 
 	for _, job := range jobs {
@@ -231,7 +579,7 @@ func renderQueueItems(jobs []*queue.EmailJob) string {
 			statusColor = "var(--success)"
 		case "failed":
 			statusColor = "var(--danger)"
-		case "pending", "scheduled":
+		case "pending", "scheduled", "partial":
 			statusColor = "var(--warning)"
 		case "retry", "processing":
 			statusColor = "var(--primary)"
@@ -246,6 +594,10 @@ func renderQueueItems(jobs []*queue.EmailJob) string {
 		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;">%s</td>`, html.EscapeString(job.Subject)))
 		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;"><span style="color:%s;font-weight:600;font-size:0.875rem;">%s</span></td>`, statusColor, html.EscapeString(job.Status)))
 		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;color:var(--text-muted);">%s</td>`, created))
+		b.WriteString(`<td style="padding:0.75rem 1rem;"><div class="queue-actions">`)
+		b.WriteString(fmt.Sprintf(`<button data-on:click="@get('/api/queue/%s/events')">Timeline</button>`, url.PathEscape(job.ID)))
+		b.WriteString(queueActionButtons(job))
+		b.WriteString(`</div></td>`)
 		b.WriteString(`</tr>`)
 	}
 
@@ -253,6 +605,92 @@ func renderQueueItems(jobs []*queue.EmailJob) string {
 	return b.String()
 }
 
+// renderTimeline renders the lifecycle events of an email, oldest first.
+func renderTimeline(id string, events []*queue.Event) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(`<h3>Timeline <code>%s</code></h3>`, html.EscapeString(id)))
+	if len(events) == 0 {
+		b.WriteString(`<p class="hint">No events recorded</p>`)
+		return b.String()
+	}
+
+	b.WriteString(`<ol>`)
+	for _, ev := range events {
+		b.WriteString(`<li>`)
+		b.WriteString(fmt.Sprintf(`<span class="timeline-time">%s</span> <strong>%s</strong>`,
+			ev.Timestamp.Local().Format("Jan 2 15:04:05"), html.EscapeString(ev.Type)))
+		if len(ev.Details) > 0 {
+			details, _ := json.Marshal(ev.Details)
+			b.WriteString(fmt.Sprintf(` <code>%s</code>`, html.EscapeString(string(details))))
+		}
+		b.WriteString(`</li>`)
+	}
+	b.WriteString(`</ol>`)
+	return b.String()
+}
+
+// renderSuppressionItems renders the suppression list with a Remove button
+// for each entry.
+func renderSuppressionItems(list []*queue.Suppression) string {
+	if len(list) == 0 {
+		return `<p class="hint" style="padding:2rem;text-align:center;">No suppressed addresses</p>`
+	}
+
+	var b strings.Builder
+	b.WriteString(`<table style="width:100%;border-collapse:collapse;">`)
+	b.WriteString(`<thead><tr>`)
+	for _, col := range []string{"Address", "Category", "Reason", "Source", "Expires", "Added", ""} {
+		b.WriteString(fmt.Sprintf(`<th style="text-align:left;padding:0.75rem 1rem;border-bottom:2px solid var(--border);color:var(--text-muted);font-size:0.875rem;">%s</th>`, col))
+	}
+	b.WriteString(`</tr></thead><tbody>`)
+
+	for _, s := range list {
+		category := s.Category
+		if category == "" {
+			category = "all"
+		}
+		expires := "never"
+		if s.ExpiresAt != nil {
+			expires = s.ExpiresAt.Local().Format("Jan 2 2006 15:04")
+		}
+		body, _ := json.Marshal(map[string]string{"address": s.Address, "category": s.Category})
+
+		b.WriteString(`<tr style="border-bottom:1px solid var(--border);">`)
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-weight:500;">%s</td>`, html.EscapeString(s.Address)))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;">%s</td>`, html.EscapeString(category)))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;">%s</td>`, html.EscapeString(s.Reason)))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;">%s</td>`, html.EscapeString(s.Source)))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;color:var(--text-muted);">%s</td>`, expires))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;font-size:0.875rem;color:var(--text-muted);">%s</td>`, s.CreatedAt.Local().Format("Jan 2 15:04")))
+		b.WriteString(fmt.Sprintf(`<td style="padding:0.75rem 1rem;"><div class="queue-actions"><button data-on:click="@post('/api/suppressions/remove', {body: JSON.stringify({...%s, search: $search})})">Remove</button></div></td>`,
+			html.EscapeString(string(body))))
+		b.WriteString(`</tr>`)
+	}
+
+	b.WriteString(`</tbody></table>`)
+	return b.String()
+}
+
+// queueActionButtons returns the buttons for the operations job's status
+// allows.
+func queueActionButtons(job *queue.EmailJob) string {
+	base := "/api/queue/" + url.PathEscape(job.ID)
+	button := func(label, action, body string) string {
+		return fmt.Sprintf(`<button data-on:click="@post('%s/%s', {body: JSON.stringify(%s)})">%s</button>`,
+			base, action, html.EscapeString(body), label)
+	}
+
+	var b strings.Builder
+	switch job.Status {
+	case "pending", "retry":
+		b.WriteString(button("Reschedule", "reschedule", "{filter: $filter, scheduled_at: $rescheduleAt ? new Date($rescheduleAt).toISOString() : ''}"))
+		b.WriteString(button("Cancel", "cancel", "{filter: $filter}"))
+	case "failed", "partial", "cancelled":
+		b.WriteString(button("Retry", "retry", "{filter: $filter}"))
+	}
+	return b.String()
+}
+
 func getTemplateDescription(slug string) string {
 	descriptions := map[string]string{
 		"simple":                "Basic email template",
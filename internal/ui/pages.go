@@ -2,8 +2,11 @@
 package ui
 
 import (
+	"net/url"
 	"time"
 
+	"github.com/joeblew999/plat-mjml/pkg/unsubscribe"
+
 	g "maragu.dev/gomponents"
 	h "maragu.dev/gomponents/html"
 
@@ -29,6 +32,7 @@ func Layout(title string, content ...g.Node) g.Node {
 					h.A(h.Href("/templates"), g.Text("Templates")),
 					h.A(h.Href("/queue"), g.Text("Queue")),
 					h.A(h.Href("/send"), g.Text("Send")),
+					h.A(h.Href("/suppressions"), g.Text("Suppressions")),
 				),
 			),
 			h.Main(h.Class("container"), g.Group(content)),
@@ -39,6 +43,23 @@ func Layout(title string, content ...g.Node) g.Node {
 	)
 }
 
+// PublicLayout wraps pages shown to email recipients, without the
+// navigation of the admin UI.
+func PublicLayout(title string, content ...g.Node) g.Node {
+	return h.HTML(
+		h.Lang("en"),
+		h.Head(
+			h.Meta(h.Charset("utf-8")),
+			h.Meta(h.Name("viewport"), h.Content("width=device-width, initial-scale=1")),
+			h.TitleEl(g.Text(title)),
+			h.StyleEl(h.Type("text/css"), g.Raw(styles)),
+		),
+		h.Body(
+			h.Main(h.Class("container public"), g.Group(content)),
+		),
+	)
+}
+
 // Dashboard renders the main dashboard page.
 func Dashboard() g.Node {
 	return Layout("Dashboard - plat-mjml",
@@ -153,9 +174,11 @@ func TemplatesPage(templates []TemplateInfo) g.Node {
 func QueuePage() g.Node {
 	return Layout("Queue - plat-mjml",
 		data.Signals(map[string]any{
-			"jobs":    []any{},
-			"filter":  "all",
-			"loading": true,
+			"jobs":         []any{},
+			"filter":       "all",
+			"loading":      true,
+			"rescheduleAt": "",
+			"result":       "",
 		}),
 		data.Init("@get('/api/queue')"),
 
@@ -188,6 +211,22 @@ func QueuePage() g.Node {
 				data.Class("active", "$filter === 'failed'"),
 				g.Text("Failed"),
 			),
+			h.Button(
+				data.On("click", "$filter = 'cancelled'; @get('/api/queue?status=cancelled')"),
+				data.Class("active", "$filter === 'cancelled'"),
+				g.Text("Cancelled"),
+			),
+		),
+
+		// New send time used by the Reschedule buttons
+		h.Div(h.Class("reschedule-bar"),
+			h.Label(h.For("reschedule-at"), g.Text("Reschedule to")),
+			h.Input(h.ID("reschedule-at"), h.Type("datetime-local"), data.Bind("rescheduleAt")),
+		),
+
+		h.Div(h.Class("result"),
+			data.Show("$result"),
+			data.Text("$result"),
 		),
 
 		// Auto-refresh toggle
@@ -196,6 +235,9 @@ func QueuePage() g.Node {
 			g.Text("Auto-refresh: 5s"),
 		),
 
+		// Timeline of the email last picked with a Timeline button
+		h.Div(h.ID("timeline")),
+
 		// Queue list
 		h.Div(h.Class("queue-list"),
 			data.Show("$loading"),
@@ -210,6 +252,207 @@ func QueuePage() g.Node {
 	)
 }
 
+// SuppressionsPage renders the suppression list management page.
+func SuppressionsPage() g.Node {
+	return Layout("Suppressions - plat-mjml",
+		data.Signals(map[string]any{
+			"search":    "",
+			"address":   "",
+			"category":  "",
+			"reason":    "manual",
+			"expiresAt": "",
+			"csv":       "",
+			"loading":   true,
+			"result":    "",
+		}),
+		data.Init("@get('/api/suppressions')"),
+
+		h.H1(g.Text("Suppressed Addresses")),
+		h.P(h.Class("hint"),
+			g.Text("Suppressed addresses are skipped at enqueue and send time. Bounces and complaints are added automatically."),
+		),
+
+		// Search and export
+		h.Form(h.Class("filter-bar"),
+			data.On("submit", "event.preventDefault(); @get('/api/suppressions?q=' + encodeURIComponent($search))"),
+			h.Input(h.Type("search"), data.Bind("search"), h.Placeholder("Search addresses")),
+			h.Button(h.Type("submit"), g.Text("Search")),
+			h.A(h.Href("/suppressions/export"), h.Button(h.Type("button"), g.Text("Export CSV"))),
+		),
+
+		h.Div(h.Class("result"),
+			data.Show("$result"),
+			data.Text("$result"),
+		),
+
+		// Suppression list
+		h.Div(h.Class("queue-list"),
+			data.Show("$loading"),
+			h.Div(h.Class("loading"),
+				h.Span(h.Class("loading-spinner")),
+				g.Text(" Loading suppressions..."),
+			),
+		),
+		h.Div(h.ID("suppression-items"), h.Class("queue-list"),
+			data.Show("!$loading"),
+		),
+
+		h.Div(h.Class("section"),
+			h.H2(g.Text("Suppress an Address")),
+			h.Form(h.Class("send-form"),
+				data.On("submit", `
+					event.preventDefault();
+					@post('/api/suppressions', {
+						body: JSON.stringify({
+							search: $search,
+							address: $address,
+							category: $category,
+							reason: $reason,
+							expires_at: $expiresAt ? new Date($expiresAt).toISOString() : ''
+						})
+					})
+				`),
+
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("address"), g.Text("Address")),
+					h.Input(h.ID("address"), h.Type("email"), data.Bind("address"),
+						h.Placeholder("email@example.com"),
+					),
+				),
+
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("category"), g.Text("Template category (empty for all)")),
+					h.Input(h.ID("category"), h.Type("text"), data.Bind("category"),
+						h.Placeholder("marketing"),
+					),
+				),
+
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("reason"), g.Text("Reason")),
+					h.Select(h.ID("reason"), data.Bind("reason"),
+						h.Option(h.Value("manual"), g.Text("Manual")),
+						h.Option(h.Value("unsubscribe"), g.Text("Unsubscribe")),
+						h.Option(h.Value("bounce"), g.Text("Bounce")),
+						h.Option(h.Value("complaint"), g.Text("Complaint")),
+					),
+				),
+
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("expires-at"), g.Text("Expires (empty for never)")),
+					h.Input(h.ID("expires-at"), h.Type("datetime-local"), data.Bind("expiresAt")),
+				),
+
+				h.Button(h.Type("submit"), g.Text("Suppress")),
+			),
+		),
+
+		h.Div(h.Class("section"),
+			h.H2(g.Text("Import CSV")),
+			h.Form(h.Class("send-form"),
+				data.On("submit", "event.preventDefault(); @post('/api/suppressions/import', {body: JSON.stringify({search: $search, csv: $csv})})"),
+
+				h.Div(h.Class("form-group"),
+					h.Label(h.For("csv"), g.Text("address,category,reason,source,expires_at")),
+					h.Textarea(h.ID("csv"), data.Bind("csv"),
+						h.Placeholder("gone@example.com,,bounce"),
+						h.Rows("6"),
+					),
+				),
+
+				h.Button(h.Type("submit"), g.Text("Import")),
+			),
+		),
+	)
+}
+
+// UnsubscribePage renders the page unsubscribe links lead to: a
+// confirmation button, or once done the outcome. claims is nil for an
+// invalid link.
+func UnsubscribePage(claims *unsubscribe.Claims, token string, done bool) g.Node {
+	if claims == nil {
+		return PublicLayout("Unsubscribe",
+			h.H1(g.Text("Invalid link")),
+			h.P(g.Text("This unsubscribe link is not valid. Use the link in the most recent email you received.")),
+		)
+	}
+
+	what := "these emails"
+	if claims.Category != "" {
+		what = claims.Category + " emails"
+	}
+	if done {
+		return PublicLayout("Unsubscribed",
+			h.H1(g.Text("You are unsubscribed")),
+			h.P(g.Text(claims.Address+" will no longer receive "+what+".")),
+		)
+	}
+	return PublicLayout("Unsubscribe",
+		h.H1(g.Text("Unsubscribe")),
+		h.P(g.Text("Stop sending "+what+" to "+claims.Address+"?")),
+		h.Form(h.Method("post"), h.Action("/unsubscribe?token="+url.QueryEscape(token)),
+			h.Button(h.Type("submit"), g.Text("Unsubscribe")),
+		),
+		h.P(h.Class("hint"),
+			h.A(h.Href("/preferences?token="+url.QueryEscape(token)), g.Text("Choose which emails you get instead")),
+		),
+	)
+}
+
+// PreferencesInfo holds what the preference page shows.
+type PreferencesInfo struct {
+	Address      string
+	Token        string
+	Categories   []string
+	Preferences  map[string]bool // categories not in it are subscribed
+	Unsubscribed bool            // from every category
+	Saved        bool
+}
+
+// PreferencesPage renders the page preference links lead to, where
+// recipients choose the template categories they get. info is nil for an
+// invalid link.
+func PreferencesPage(info *PreferencesInfo) g.Node {
+	if info == nil {
+		return PublicLayout("Email preferences",
+			h.H1(g.Text("Invalid link")),
+			h.P(g.Text("This link is not valid. Use the link in the most recent email you received.")),
+		)
+	}
+
+	var options []g.Node
+	for _, category := range info.Categories {
+		subscribed, chosen := info.Preferences[category]
+		options = append(options, h.Div(h.Class("preference"),
+			h.Label(
+				h.Input(h.Type("checkbox"), h.Name("subscribed"), h.Value(category),
+					g.If(subscribed || !chosen, h.Checked()),
+				),
+				g.Text(" "+category),
+			),
+		))
+	}
+
+	return PublicLayout("Email preferences",
+		h.H1(g.Text("Email preferences")),
+		h.P(g.Text("Choose which emails "+info.Address+" gets.")),
+		g.If(info.Unsubscribed,
+			h.P(h.Class("result"), g.Text("You are unsubscribed from all emails, so none are sent whatever you choose here.")),
+		),
+		g.If(len(info.Categories) == 0,
+			h.P(h.Class("hint"), g.Text("There are no kinds of email to choose from.")),
+		),
+		g.If(len(info.Categories) > 0,
+			h.Form(h.Method("post"), h.Action("/preferences?token="+url.QueryEscape(info.Token)),
+				g.Group(options),
+				h.Button(h.Type("submit"), g.Text("Save preferences")),
+			),
+		),
+		g.If(info.Saved,
+			h.P(h.Class("result"), g.Text("Your preferences are saved.")),
+		),
+	)
+}
+
 // SendEmailPage renders the send email form.
 func SendEmailPage(templates []TemplateInfo) g.Node {
 	var templateOptions []g.Node
@@ -511,6 +754,19 @@ button.active {
 	font-style: italic;
 }
 
+.public {
+	max-width: 560px;
+	padding-top: 4rem;
+}
+
+.public p {
+	margin-bottom: 1.5rem;
+}
+
+.public .preference {
+	margin-bottom: 1rem;
+}
+
 .filter-bar {
 	display: flex;
 	gap: 0.5rem;
@@ -523,6 +779,59 @@ button.active {
 	margin-bottom: 1rem;
 }
 
+.reschedule-bar {
+	display: flex;
+	align-items: center;
+	gap: 0.5rem;
+	font-size: 0.875rem;
+	margin-bottom: 1rem;
+}
+
+.reschedule-bar input {
+	padding: 0.375rem 0.5rem;
+	border: 1px solid var(--border);
+	border-radius: 8px;
+}
+
+.queue-actions {
+	display: flex;
+	gap: 0.25rem;
+}
+
+.queue-actions button {
+	padding: 0.25rem 0.625rem;
+	font-size: 0.75rem;
+}
+
+.timeline {
+	background: var(--card-bg);
+	border-radius: 12px;
+	border: 1px solid var(--border);
+	padding: 1rem 1.5rem;
+	margin-bottom: 1rem;
+}
+
+.timeline ol {
+	list-style: none;
+	font-size: 0.875rem;
+}
+
+.timeline li {
+	padding: 0.25rem 0;
+	border-left: 2px solid var(--border);
+	padding-left: 0.75rem;
+}
+
+.timeline-time {
+	color: var(--text-muted);
+}
+
+.timeline code {
+	color: var(--text-muted);
+	font-size: 0.75rem;
+	word-break: break-all;
+}
+
 .queue-list {
 	background: var(--card-bg);
 	border-radius: 12px;
@@ -181,7 +181,13 @@ func sendCmd(args []string) {
 		os.Exit(1)
 	}
 
-	if err := mail.Send(smtpCfg, *to, *subject, string(content)); err != nil {
+	msg := mail.Message{
+		To:      []string{*to},
+		Subject: *subject,
+		HTML:    string(content),
+		Text:    mjml.HTMLToText(string(content)),
+	}
+	if err := mail.SendMessage(smtpCfg, msg); err != nil {
 		fmt.Printf("Error sending email: %v\n", err)
 		os.Exit(1)
 	}
@@ -0,0 +1,235 @@
+// Package bounce ingests bounces and spam complaints that arrive after a
+// message was accepted: RFC 3464 delivery status notifications, RFC 5965
+// (ARF) feedback reports and provider webhooks. They are linked to emails
+// by Message-ID, recorded as bounced events and suppress the address.
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Bounce types.
+const (
+	TypeHard      = "hard"      // the address does not accept mail
+	TypeSoft      = "soft"      // delivery failed or is delayed for now
+	TypeComplaint = "complaint" // the recipient reported the email as spam
+)
+
+// Sources of bounces.
+const (
+	SourceDSN      = "dsn"
+	SourceARF      = "arf"
+	SourceSES      = "ses"
+	SourceSendGrid = "sendgrid"
+	SourcePostmark = "postmark"
+)
+
+// ErrNotReport is returned by Parse for messages that are neither delivery
+// status notifications nor feedback reports.
+var ErrNotReport = errors.New("not a delivery status notification or feedback report")
+
+// Bounce is a bounce or complaint for one recipient of a message.
+type Bounce struct {
+	Type       string `json:"type"`
+	Recipient  string `json:"recipient"`
+	MessageID  string `json:"message_id,omitempty"` // of the original message, without angle brackets
+	Status     string `json:"status,omitempty"`     // RFC 3463 status code, e.g. 5.1.1
+	Diagnostic string `json:"diagnostic,omitempty"` // the remote server's reply, or the provider's reason
+	Source     string `json:"source"`
+}
+
+// Parse reads a multipart/report message, a delivery status notification
+// or a feedback report, and returns a bounce for each failed or complaining
+// recipient. Successful and relayed deliveries in a DSN are skipped.
+func Parse(r io.Reader) ([]*Bounce, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || params["boundary"] == "" {
+		return nil, ErrNotReport
+	}
+
+	var report textproto.MIMEHeader
+	var recipients []textproto.MIMEHeader
+	var original textproto.MIMEHeader
+	reportType := strings.ToLower(params["report-type"])
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read report part: %w", err)
+		}
+		body, err := partBody(part)
+		if err != nil {
+			return nil, err
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			fields, err := fieldGroups(body)
+			if err != nil {
+				return nil, fmt.Errorf("parse delivery status: %w", err)
+			}
+			if len(fields) > 1 {
+				recipients = fields[1:]
+			}
+		case "message/feedback-report":
+			fields, err := fieldGroups(body)
+			if err != nil {
+				return nil, fmt.Errorf("parse feedback report: %w", err)
+			}
+			if len(fields) > 0 {
+				report = fields[0]
+			}
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(body))).ReadMIMEHeader()
+			if err != nil && len(header) == 0 {
+				return nil, fmt.Errorf("parse original headers: %w", err)
+			}
+			original = header
+		}
+	}
+
+	messageID := strings.Trim(strings.TrimSpace(original.Get("Message-Id")), "<>")
+	switch {
+	case reportType == "delivery-status" && recipients != nil:
+		return dsnBounces(recipients, messageID), nil
+	case reportType == "feedback-report" && report != nil:
+		return arfBounces(report, original, messageID), nil
+	}
+	return nil, ErrNotReport
+}
+
+// dsnBounces returns a bounce for each failed or delayed recipient of a
+// delivery status notification.
+func dsnBounces(recipients []textproto.MIMEHeader, messageID string) []*Bounce {
+	var bounces []*Bounce
+	for _, fields := range recipients {
+		status := strings.TrimSpace(fields.Get("Status"))
+		var typ string
+		switch strings.ToLower(strings.TrimSpace(fields.Get("Action"))) {
+		case "failed":
+			typ = TypeSoft
+			if strings.HasPrefix(status, "5") {
+				typ = TypeHard
+			}
+		case "delayed":
+			typ = TypeSoft
+		default:
+			continue
+		}
+
+		recipient := addressField(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = addressField(fields.Get("Original-Recipient"))
+		}
+		if recipient == "" {
+			continue
+		}
+		bounces = append(bounces, &Bounce{
+			Type:       typ,
+			Recipient:  recipient,
+			MessageID:  messageID,
+			Status:     status,
+			Diagnostic: typedField(fields.Get("Diagnostic-Code")),
+			Source:     SourceDSN,
+		})
+	}
+	return bounces
+}
+
+// arfBounces returns a complaint for the recipient of a feedback report,
+// taken from Original-Rcpt-To or else the To header of the original message.
+func arfBounces(report, original textproto.MIMEHeader, messageID string) []*Bounce {
+	var recipients []string
+	for _, rcpt := range report.Values("Original-Rcpt-To") {
+		if addr := strings.Trim(strings.TrimSpace(rcpt), "<>"); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+	if len(recipients) == 0 {
+		if to, err := mail.ParseAddressList(original.Get("To")); err == nil {
+			for _, addr := range to {
+				recipients = append(recipients, addr.Address)
+			}
+		}
+	}
+
+	bounces := make([]*Bounce, 0, len(recipients))
+	for _, rcpt := range recipients {
+		bounces = append(bounces, &Bounce{
+			Type:       TypeComplaint,
+			Recipient:  rcpt,
+			MessageID:  messageID,
+			Diagnostic: strings.TrimSpace(report.Get("Feedback-Type")),
+			Source:     SourceARF,
+		})
+	}
+	return bounces
+}
+
+// partBody reads a report part, decoding base64. Quoted-printable is
+// decoded by the multipart reader.
+func partBody(part *multipart.Part) ([]byte, error) {
+	var r io.Reader = part
+	if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+		r = base64.NewDecoder(base64.StdEncoding, part)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read report part: %w", err)
+	}
+	return body, nil
+}
+
+// fieldGroups parses the blank-line separated groups of header fields in a
+// delivery status or feedback report body.
+func fieldGroups(body []byte) ([]textproto.MIMEHeader, error) {
+	body = bytes.ReplaceAll(body, []byte("\r\n"), []byte("\n"))
+	var groups []textproto.MIMEHeader
+	for _, block := range bytes.Split(body, []byte("\n\n")) {
+		block = bytes.TrimSpace(block)
+		if len(block) == 0 {
+			continue
+		}
+		r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(block, "\n\n"...))))
+		fields, err := r.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		groups = append(groups, fields)
+	}
+	return groups, nil
+}
+
+// typedField returns the value of a typed DSN field such as
+// "smtp; 550 5.1.1 User unknown", without its type.
+func typedField(value string) string {
+	if _, v, ok := strings.Cut(value, ";"); ok {
+		value = v
+	}
+	return strings.TrimSpace(value)
+}
+
+// addressField returns the address in a typed DSN field such as
+// "rfc822; user@example.com".
+func addressField(value string) string {
+	return strings.Trim(typedField(value), "<>")
+}
@@ -0,0 +1,331 @@
+package bounce
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+const dsn = `From: MAILER-DAEMON@mx.example.net
+To: bounces@example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="B"
+
+--B
+Content-Type: text/plain
+
+Your message could not be delivered.
+
+--B
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+Arrival-Date: Mon, 2 Mar 2026 10:00:00 +0000
+
+Final-Recipient: rfc822; gone@example.net
+Original-Recipient: rfc822;gone@example.net
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <gone@example.net>: User unknown
+
+Final-Recipient: rfc822; full@example.net
+Action: delayed
+Status: 4.2.2
+Diagnostic-Code: smtp; 452 4.2.2 Mailbox full
+
+Final-Recipient: rfc822; ok@example.net
+Action: delivered
+Status: 2.0.0
+
+--B
+Content-Type: text/rfc822-headers
+
+From: news@example.com
+To: gone@example.net
+Subject: Hello
+Message-ID: <20260302.abc@example.com>
+
+--B--
+`
+
+const arf = `From: feedback@isp.example
+To: abuse@example.com
+Subject: Complaint
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="F"
+
+--F
+Content-Type: text/plain
+
+This is an email abuse report.
+
+--F
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: ISP-FBL/1.0
+Version: 1
+
+--F
+Content-Type: message/rfc822
+
+From: news@example.com
+To: Angry Reader <angry@isp.example>
+Subject: Hello
+Message-ID: <20260302.abc@example.com>
+
+Hello!
+--F--
+`
+
+func TestParse(t *testing.T) {
+	bounces, err := Parse(strings.NewReader(strings.ReplaceAll(dsn, "\n", "\r\n")))
+	if err != nil {
+		t.Fatalf("Parse DSN failed: %v", err)
+	}
+	if len(bounces) != 2 {
+		t.Fatalf("Expected 2 bounces, got %d", len(bounces))
+	}
+	hard, soft := bounces[0], bounces[1]
+	if hard.Type != TypeHard || hard.Recipient != "gone@example.net" || hard.Status != "5.1.1" ||
+		hard.MessageID != "20260302.abc@example.com" || hard.Diagnostic != "550 5.1.1 <gone@example.net>: User unknown" {
+		t.Errorf("Unexpected hard bounce %+v", hard)
+	}
+	if soft.Type != TypeSoft || soft.Recipient != "full@example.net" {
+		t.Errorf("Unexpected soft bounce %+v", soft)
+	}
+
+	bounces, err = Parse(strings.NewReader(arf))
+	if err != nil {
+		t.Fatalf("Parse ARF failed: %v", err)
+	}
+	if len(bounces) != 1 || bounces[0].Type != TypeComplaint || bounces[0].Recipient != "angry@isp.example" ||
+		bounces[0].Diagnostic != "abuse" || bounces[0].Source != SourceARF {
+		t.Errorf("Unexpected complaint %+v", bounces)
+	}
+
+	if _, err := Parse(strings.NewReader("From: a@example.com\nSubject: hi\n\nhello\n")); err != ErrNotReport {
+		t.Errorf("Expected ErrNotReport for plain mail, got %v", err)
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	tests := []struct {
+		source string
+		body   string
+		want   []Bounce
+	}{
+		{
+			SourceSES,
+			`{"Type":"Notification","Message":"{\"notificationType\":\"Bounce\",\"mail\":{\"messageId\":\"ses-1\"},\"bounce\":{\"bounceType\":\"Permanent\",\"bouncedRecipients\":[{\"emailAddress\":\"gone@example.net\",\"status\":\"5.1.1\"}]}}"}`,
+			[]Bounce{{Type: TypeHard, Recipient: "gone@example.net", MessageID: "ses-1", Status: "5.1.1", Source: SourceSES}},
+		},
+		{
+			SourceSES,
+			`{"eventType":"Complaint","mail":{"messageId":"ses-2"},"complaint":{"complaintFeedbackType":"abuse","complainedRecipients":[{"emailAddress":"angry@example.net"}]}}`,
+			[]Bounce{{Type: TypeComplaint, Recipient: "angry@example.net", MessageID: "ses-2", Diagnostic: "abuse", Source: SourceSES}},
+		},
+		{
+			SourceSendGrid,
+			`[{"email":"gone@example.net","event":"bounce","type":"bounce","status":"5.1.1","reason":"unknown user","sg_message_id":"sg-1.filter0001.1"},
+			  {"email":"ok@example.net","event":"delivered","sg_message_id":"sg-2.filter0001.1"},
+			  {"email":"angry@example.net","event":"spamreport","sg_message_id":"sg-3.filter0001.1"}]`,
+			[]Bounce{
+				{Type: TypeHard, Recipient: "gone@example.net", MessageID: "sg-1", Status: "5.1.1", Diagnostic: "unknown user", Source: SourceSendGrid},
+				{Type: TypeComplaint, Recipient: "angry@example.net", MessageID: "sg-3", Source: SourceSendGrid},
+			},
+		},
+		{
+			SourcePostmark,
+			`{"RecordType":"Bounce","Type":"HardBounce","MessageID":"pm-1","Email":"gone@example.net","Description":"Unknown user"}`,
+			[]Bounce{{Type: TypeHard, Recipient: "gone@example.net", MessageID: "pm-1", Diagnostic: "Unknown user", Source: SourcePostmark}},
+		},
+		{
+			SourcePostmark,
+			`{"RecordType":"Delivery","MessageID":"pm-2","Recipient":"ok@example.net"}`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		bounces, err := ParseWebhook(tt.source, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: ParseWebhook failed: %v", tt.source, err)
+			continue
+		}
+		if len(bounces) != len(tt.want) {
+			t.Errorf("%s: expected %d bounces, got %d", tt.source, len(tt.want), len(bounces))
+			continue
+		}
+		for i, b := range bounces {
+			if *b != tt.want[i] {
+				t.Errorf("%s: expected %+v, got %+v", tt.source, tt.want[i], *b)
+			}
+		}
+	}
+
+	if _, err := ParseWebhook("mailgun", []byte(`{}`)); err == nil {
+		t.Error("Expected an unknown source to fail")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"Gone@example.net", "full@example.net"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	for _, addr := range []string{"Gone@example.net", "full@example.net"} {
+		q.RecordDelivery(ctx, id, addr, "smtp", "20260302.abc@example.com", nil, false)
+	}
+
+	// The DSN arrives in the bounce mailbox
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		os.Mkdir(filepath.Join(dir, sub), 0o755)
+	}
+	os.WriteFile(filepath.Join(dir, "new", "1.dsn"), []byte(dsn), 0o644)
+	os.WriteFile(filepath.Join(dir, "new", "2.txt"), []byte("Subject: hi\n\nhello\n"), 0o644)
+
+	p := NewProcessor(q)
+	found, err := NewMailbox(dir, 0, p).Poll(ctx)
+	if err != nil || found != 2 {
+		t.Fatalf("Expected 2 bounces from the mailbox, got %d, %v", found, err)
+	}
+	if left, _ := os.ReadDir(filepath.Join(dir, "new")); len(left) != 0 {
+		t.Errorf("Expected the mailbox to be emptied, %d left", len(left))
+	}
+
+	recipients, _ := q.Recipients(ctx, id)
+	if recipients[0].Status != "bounced" || recipients[1].Status != "sent" {
+		t.Errorf("Expected only the hard bounce to mark its recipient, got %s and %s", recipients[0].Status, recipients[1].Status)
+	}
+	events, _ := q.Events(ctx, id)
+	bounced := 0
+	for _, e := range events {
+		if e.Type == queue.EventBounced {
+			bounced++
+		}
+	}
+	if bounced != 2 {
+		t.Errorf("Expected 2 bounced events, got %d", bounced)
+	}
+
+	suppressed, _ := q.Suppressed(ctx, []string{"gone@example.net", "full@example.net"}, "")
+	s := suppressed["gone@example.net"]
+	if s == nil || s.Reason != queue.SuppressBounce || s.Source != SourceDSN || s.EmailID != id {
+		t.Errorf("Expected the hard bounce to be suppressed, got %+v", s)
+	}
+	if s := suppressed["full@example.net"]; s != nil {
+		t.Errorf("Expected the soft bounce not to be suppressed, got %+v", s)
+	}
+
+	// A complaint through a webhook, for an email we do not know
+	w := httptest.NewRecorder()
+	Handler(p, SourcePostmark, "")(w, httptest.NewRequest(http.MethodPost, "/api/v1/bounces/postmark?token=", strings.NewReader("{}")))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected every request to be rejected without a token, got %d", w.Code)
+	}
+	h := Handler(p, SourcePostmark, "t0ken")
+	body := `{"RecordType":"SpamComplaint","Type":"SpamComplaint","MessageID":"unknown","Email":"angry@example.net"}`
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/api/v1/bounces/postmark", strings.NewReader(body)))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a missing token to be rejected, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/api/v1/bounces/postmark?token=t0ken", strings.NewReader(body)))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"linked":0`) {
+		t.Errorf("Unexpected response %d %s", w.Code, w.Body)
+	}
+	suppressed, _ = q.Suppressed(ctx, []string{"angry@example.net"}, "")
+	if s := suppressed["angry@example.net"]; s != nil {
+		t.Errorf("Expected a posted complaint for an unknown email not to be suppressed, got %+v", s)
+	}
+}
+
+func TestVerifySNS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	certURL := "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+	snsCertificate = func(ctx context.Context, u string) (*x509.Certificate, error) {
+		if u != certURL {
+			return nil, errors.New("unexpected certificate URL " + u)
+		}
+		return cert, nil
+	}
+	t.Cleanup(func() { snsCertificate = fetchSNSCertificate })
+
+	msg := snsMessage{
+		Type:             "Notification",
+		MessageId:        "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		Message:          `{"notificationType":"Complaint"}`,
+		Timestamp:        "2026-03-02T10:00:00.000Z",
+		TopicArn:         "arn:aws:sns:eu-west-1:123456789012:ses",
+		SignatureVersion: "2",
+		SigningCertURL:   certURL,
+	}
+	digest := sha256.Sum256(msg.stringToSign())
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	msg.Signature = base64.StdEncoding.EncodeToString(sig)
+
+	body, _ := json.Marshal(msg)
+	if err := verifySNS(context.Background(), body); err != nil {
+		t.Errorf("Expected a signed message to verify, got %v", err)
+	}
+
+	forged := msg
+	forged.Message = `{"notificationType":"Bounce"}`
+	body, _ = json.Marshal(forged)
+	if err := verifySNS(context.Background(), body); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected a changed message to fail, got %v", err)
+	}
+
+	forged = msg
+	forged.SigningCertURL = "https://sns.evil.example.com/cert.pem"
+	body, _ = json.Marshal(forged)
+	if err := verifySNS(context.Background(), body); !errors.Is(err, ErrSignature) {
+		t.Errorf("Expected a certificate outside SNS to fail, got %v", err)
+	}
+}
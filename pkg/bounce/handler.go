@@ -0,0 +1,64 @@
+package bounce
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// maxBody is the largest bounce request accepted.
+const maxBody = 10 << 20
+
+// Handler returns an HTTP handler that receives bounces from source: raw
+// DSN or ARF messages for SourceDSN, or the webhooks of a provider.
+// Requests must carry token in the token query parameter, so with no token
+// every request is refused, and SNS messages must be signed by AWS. Only
+// bounces for emails that are found are applied.
+func Handler(p *Processor, source, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var bounces []*Bounce
+		if source == SourceDSN {
+			bounces, err = Parse(bytes.NewReader(body))
+		} else {
+			if source == SourceSES {
+				if err := verifySNS(r.Context(), body); err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+				if err := confirmSNS(r, body); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			bounces, err = ParseWebhook(source, body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		linked, err := p.Process(r.Context(), bounces, false)
+		if err != nil {
+			logx.WithContext(r.Context()).Errorf("process %s bounces: %v", source, err)
+			http.Error(w, "failed to process bounces", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"bounces": len(bounces), "linked": linked})
+	}
+}
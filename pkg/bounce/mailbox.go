@@ -0,0 +1,110 @@
+package bounce
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Mailbox polls a Maildir, such as the mailbox of the bounce address, for
+// delivery status notifications and feedback reports. Messages are moved
+// to cur once processed; other mail is moved there untouched.
+type Mailbox struct {
+	dir       string
+	interval  time.Duration
+	processor *Processor
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMailbox creates a poller of the Maildir at dir.
+func NewMailbox(dir string, interval time.Duration, p *Processor) *Mailbox {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Mailbox{
+		dir:       dir,
+		interval:  interval,
+		processor: p,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start starts polling.
+func (m *Mailbox) Start() {
+	logx.Infow("Bounce mailbox polling started", logx.Field("dir", m.dir), logx.Field("interval", m.interval.String()))
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Poll(m.ctx); err != nil {
+				logx.Errorf("poll bounce mailbox: %v", err)
+			}
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops polling.
+func (m *Mailbox) Stop() {
+	m.cancel()
+	m.wg.Wait()
+	logx.Info("Bounce mailbox polling stopped")
+}
+
+// Poll processes the new messages in the Maildir and returns the number
+// of bounces found. Messages that fail to process are left for the next
+// poll.
+func (m *Mailbox) Poll(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(m.dir, "new"))
+	if err != nil {
+		return 0, err
+	}
+
+	found := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(m.dir, "new", entry.Name())
+		bounces, err := parseFile(path)
+		switch {
+		case errors.Is(err, ErrNotReport):
+			logx.Infow("Skipping mail that is not a bounce", logx.Field("file", entry.Name()))
+		case err != nil:
+			logx.Errorf("parse bounce %s: %v", entry.Name(), err)
+		default:
+			if _, err := m.processor.Process(ctx, bounces, true); err != nil {
+				return found, err
+			}
+			found += len(bounces)
+		}
+
+		// Maildir "seen" flag
+		if err := os.Rename(path, filepath.Join(m.dir, "cur", entry.Name()+":2,S")); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+func parseFile(path string) ([]*Bounce, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
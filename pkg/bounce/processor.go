@@ -0,0 +1,100 @@
+package bounce
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Processor applies bounces to an email queue.
+type Processor struct {
+	queue *queue.Queue
+}
+
+// NewProcessor creates a processor for the emails in q.
+func NewProcessor(q *queue.Queue) *Processor {
+	return &Processor{queue: q}
+}
+
+// Process records each bounce: the email it is about, found by Message-ID,
+// gets a bounced event, its recipient is marked bounced for hard bounces,
+// and the address is suppressed for hard bounces and complaints. Bounces
+// for messages that are not found only suppress the address when
+// suppressUnlinked is set, as for reports read from the bounce mailbox;
+// otherwise they are logged and skipped. It returns the number of bounces
+// linked to an email.
+func (p *Processor) Process(ctx context.Context, bounces []*Bounce, suppressUnlinked bool) (int, error) {
+	linked := 0
+	for _, b := range bounces {
+		var emailID string
+		if b.MessageID != "" {
+			id, err := p.queue.EmailForMessage(ctx, b.MessageID)
+			if err != nil {
+				return linked, fmt.Errorf("find email for message %s: %w", b.MessageID, err)
+			}
+			emailID = id
+		}
+
+		if emailID != "" {
+			linked++
+			if err := p.queue.RecordEvent(ctx, emailID, queue.EventBounced, map[string]any{
+				"recipient":  b.Recipient,
+				"type":       b.Type,
+				"status":     b.Status,
+				"diagnostic": b.Diagnostic,
+				"source":     b.Source,
+			}); err != nil {
+				return linked, fmt.Errorf("record bounce of %s: %w", emailID, err)
+			}
+			if b.Type == TypeHard {
+				if err := p.queue.BounceRecipient(ctx, emailID, b.Recipient, b.reason()); err != nil {
+					return linked, fmt.Errorf("mark %s bounced: %w", b.Recipient, err)
+				}
+			}
+		}
+
+		if b.Type == TypeSoft {
+			continue
+		}
+		if emailID == "" && !suppressUnlinked {
+			logx.Infow("Skipping bounce for an unknown email",
+				logx.Field("address", b.Recipient),
+				logx.Field("message_id", b.MessageID),
+				logx.Field("source", b.Source),
+			)
+			continue
+		}
+		reason := queue.SuppressBounce
+		if b.Type == TypeComplaint {
+			reason = queue.SuppressComplaint
+		}
+		if err := p.queue.Suppress(ctx, queue.Suppression{
+			Address: b.Recipient,
+			Reason:  reason,
+			Source:  b.Source,
+			EmailID: emailID,
+		}); err != nil {
+			return linked, fmt.Errorf("suppress %s: %w", b.Recipient, err)
+		}
+		logx.Infow("Address suppressed",
+			logx.Field("address", b.Recipient),
+			logx.Field("reason", reason),
+			logx.Field("source", b.Source),
+			logx.Field("email", emailID),
+		)
+	}
+	return linked, nil
+}
+
+// reason describes a bounce for the recipient's error.
+func (b *Bounce) reason() string {
+	switch {
+	case b.Diagnostic != "":
+		return b.Diagnostic
+	case b.Status != "":
+		return "bounced with status " + b.Status
+	}
+	return "bounced"
+}
@@ -0,0 +1,201 @@
+package bounce
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ParseWebhook parses the bounce and complaint notifications a provider
+// posts to a webhook: SES notifications (through SNS or delivered raw),
+// SendGrid event batches and Postmark bounce or spam complaint webhooks.
+// Other notifications, such as deliveries and opens, are skipped.
+func ParseWebhook(source string, body []byte) ([]*Bounce, error) {
+	switch source {
+	case SourceSES:
+		return parseSES(body)
+	case SourceSendGrid:
+		return parseSendGrid(body)
+	case SourcePostmark:
+		return parsePostmark(body)
+	}
+	return nil, fmt.Errorf("unknown bounce webhook source %q", source)
+}
+
+// snsMessage is the SNS envelope SES notifications arrive in. Before
+// notifications are sent, the subscription is confirmed by fetching
+// SubscribeURL. Every message is signed with the certificate at
+// SigningCertURL.
+type snsMessage struct {
+	Type             string
+	MessageId        string
+	Message          string
+	Subject          string
+	Timestamp        string
+	TopicArn         string
+	SubscribeURL     string
+	Token            string
+	SignatureVersion string
+	Signature        string
+	SigningCertURL   string
+}
+
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"` // configuration set event publishing
+	Mail             struct {
+		MessageID string `json:"messageId"`
+	} `json:"mail"`
+	Bounce struct {
+		BounceType        string `json:"bounceType"` // Permanent, Transient or Undetermined
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			Status         string `json:"status"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+func parseSES(body []byte) ([]*Bounce, error) {
+	var sns snsMessage
+	if err := json.Unmarshal(body, &sns); err != nil {
+		return nil, fmt.Errorf("decode ses notification: %w", err)
+	}
+	if sns.Type == "Notification" {
+		body = []byte(sns.Message)
+	}
+
+	var n sesNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("decode ses notification: %w", err)
+	}
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+
+	var bounces []*Bounce
+	switch kind {
+	case "Bounce":
+		typ := TypeSoft
+		if n.Bounce.BounceType == "Permanent" {
+			typ = TypeHard
+		}
+		for _, r := range n.Bounce.BouncedRecipients {
+			bounces = append(bounces, &Bounce{
+				Type:       typ,
+				Recipient:  r.EmailAddress,
+				MessageID:  n.Mail.MessageID,
+				Status:     r.Status,
+				Diagnostic: r.DiagnosticCode,
+				Source:     SourceSES,
+			})
+		}
+	case "Complaint":
+		for _, r := range n.Complaint.ComplainedRecipients {
+			bounces = append(bounces, &Bounce{
+				Type:       TypeComplaint,
+				Recipient:  r.EmailAddress,
+				MessageID:  n.Mail.MessageID,
+				Diagnostic: n.Complaint.ComplaintFeedbackType,
+				Source:     SourceSES,
+			})
+		}
+	}
+	return bounces, nil
+}
+
+type sendGridEvent struct {
+	Email       string `json:"email"`
+	Event       string `json:"event"` // bounce, spamreport, delivered, open...
+	Type        string `json:"type"`  // bounce or blocked, for bounce events
+	Status      string `json:"status"`
+	Reason      string `json:"reason"`
+	SGMessageID string `json:"sg_message_id"`
+	SMTPID      string `json:"smtp-id"`
+}
+
+func parseSendGrid(body []byte) ([]*Bounce, error) {
+	var events []sendGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("decode sendgrid events: %w", err)
+	}
+
+	var bounces []*Bounce
+	for _, e := range events {
+		// sg_message_id is the X-Message-Id returned on send, followed by
+		// ".filter..." routing details
+		messageID, _, _ := strings.Cut(e.SGMessageID, ".filter")
+		if messageID == "" {
+			messageID = strings.Trim(e.SMTPID, "<>")
+		}
+
+		b := &Bounce{
+			Recipient:  e.Email,
+			MessageID:  messageID,
+			Status:     e.Status,
+			Diagnostic: e.Reason,
+			Source:     SourceSendGrid,
+		}
+		switch e.Event {
+		case "bounce":
+			b.Type = TypeHard
+			if e.Type == "blocked" || strings.HasPrefix(e.Status, "4") {
+				b.Type = TypeSoft
+			}
+		case "spamreport":
+			b.Type = TypeComplaint
+		default:
+			continue
+		}
+		bounces = append(bounces, b)
+	}
+	return bounces, nil
+}
+
+type postmarkWebhook struct {
+	RecordType  string // Bounce or SpamComplaint
+	Type        string // HardBounce, SoftBounce, SpamComplaint...
+	MessageID   string
+	Email       string
+	Description string
+	Details     string
+}
+
+// postmarkHard lists the Postmark bounce types for addresses that do not
+// accept mail.
+var postmarkHard = map[string]bool{
+	"HardBounce":      true,
+	"BadEmailAddress": true,
+}
+
+func parsePostmark(body []byte) ([]*Bounce, error) {
+	var w postmarkWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return nil, fmt.Errorf("decode postmark webhook: %w", err)
+	}
+
+	b := &Bounce{
+		Recipient:  w.Email,
+		MessageID:  w.MessageID,
+		Diagnostic: strings.TrimSpace(w.Description + " " + w.Details),
+		Source:     SourcePostmark,
+	}
+	switch {
+	case w.RecordType == "SpamComplaint" || w.Type == "SpamComplaint":
+		b.Type = TypeComplaint
+	case w.RecordType == "Bounce" && postmarkHard[w.Type]:
+		b.Type = TypeHard
+	case w.RecordType == "Bounce":
+		b.Type = TypeSoft
+	default:
+		return nil, nil
+	}
+	return []*Bounce{b}, nil
+}
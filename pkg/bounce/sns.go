@@ -0,0 +1,184 @@
+package bounce
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrSignature is returned for SNS messages whose signature does not
+// verify.
+var ErrSignature = errors.New("invalid sns signature")
+
+// snsCertificate returns the certificate at an SNS SigningCertURL. It is a
+// variable so tests can sign messages with their own certificate.
+var snsCertificate = fetchSNSCertificate
+
+// snsCerts caches the certificates fetched, by URL. SNS signs with few
+// certificates and rotates them rarely.
+var snsCerts sync.Map
+
+// verifySNS checks the signature of an SNS message against the AWS
+// certificate it names. Bodies that are not SNS messages, such as SES
+// notifications delivered raw, are left to the endpoint's token.
+func verifySNS(ctx context.Context, body []byte) error {
+	var msg snsMessage
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Type == "" {
+		return nil
+	}
+
+	var hash crypto.Hash
+	switch msg.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return fmt.Errorf("%w: unsupported version %q", ErrSignature, msg.SignatureVersion)
+	}
+	sig, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	u, err := snsURL(msg.SigningCertURL)
+	if err != nil || !strings.HasSuffix(u.Path, ".pem") {
+		return fmt.Errorf("%w: untrusted certificate URL %q", ErrSignature, msg.SigningCertURL)
+	}
+	cert, err := snsCertificate(ctx, u.String())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: certificate key is not RSA", ErrSignature)
+	}
+
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum(msg.stringToSign())
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(msg.stringToSign())
+		digest = sum[:]
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, sig); err != nil {
+		return ErrSignature
+	}
+	return nil
+}
+
+// stringToSign returns the fields SNS signs for the message type, each
+// name and value followed by a newline, in alphabetical order.
+func (m *snsMessage) stringToSign() []byte {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	} else {
+		fields = append(fields,
+			[2]string{"SubscribeURL", m.SubscribeURL},
+			[2]string{"Timestamp", m.Timestamp},
+			[2]string{"Token", m.Token},
+		)
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+
+	var b strings.Builder
+	for _, f := range fields {
+		b.WriteString(f[0] + "\n" + f[1] + "\n")
+	}
+	return []byte(b.String())
+}
+
+// confirmSNS confirms an SNS subscription by fetching its SubscribeURL,
+// which must be an SNS endpoint. Other messages are left alone.
+func confirmSNS(r *http.Request, body []byte) error {
+	if r.Header.Get("X-Amz-Sns-Message-Type") != "SubscriptionConfirmation" {
+		return nil
+	}
+	var msg snsMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return fmt.Errorf("decode sns subscription: %w", err)
+	}
+	u, err := snsURL(msg.SubscribeURL)
+	if err != nil {
+		return fmt.Errorf("invalid sns SubscribeURL %q", msg.SubscribeURL)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("confirm sns subscription: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("confirm sns subscription: %s", resp.Status)
+	}
+	logx.Infow("SNS subscription confirmed", logx.Field("url", u.Host+u.Path))
+	return nil
+}
+
+// snsURL parses a URL SNS sends, which must be an https SNS endpoint.
+func snsURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" || !strings.HasPrefix(u.Host, "sns.") || !strings.HasSuffix(u.Host, ".amazonaws.com") {
+		return nil, fmt.Errorf("not an sns endpoint: %s", u.Host)
+	}
+	return u, nil
+}
+
+func fetchSNSCertificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if cert, ok := snsCerts.Load(certURL); ok {
+		return cert.(*x509.Certificate), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch sns certificate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch sns certificate: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("fetch sns certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("sns certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse sns certificate: %w", err)
+	}
+	snsCerts.Store(certURL, cert)
+	return cert, nil
+}
@@ -83,6 +83,7 @@ func (d *DB) Migrate() error {
 		slug TEXT UNIQUE NOT NULL,
 		name TEXT NOT NULL,
 		content TEXT NOT NULL,
+		text TEXT,
 		version INTEGER DEFAULT 1,
 		status TEXT DEFAULT 'draft',
 		category TEXT,
@@ -101,17 +102,27 @@ func (d *DB) Migrate() error {
 		template_id TEXT NOT NULL,
 		version INTEGER NOT NULL,
 		content TEXT NOT NULL,
+		text TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_versions_template ON template_versions(template_id);
 
+	-- Deleted template slugs, so seeding does not bring them back
+	CREATE TABLE IF NOT EXISTS deleted_templates (
+		slug TEXT PRIMARY KEY,
+		deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
 	-- Email queue
 	CREATE TABLE IF NOT EXISTS emails (
 		id TEXT PRIMARY KEY,
 		template_slug TEXT NOT NULL,
 		recipients TEXT NOT NULL,
+		cc TEXT,
+		bcc TEXT,
+		reply_to TEXT,
 		subject TEXT NOT NULL,
 		data TEXT,
 		status TEXT DEFAULT 'pending',
@@ -122,6 +133,11 @@ func (d *DB) Migrate() error {
 		sent_at DATETIME,
 		message_id TEXT,
 		error TEXT,
+		template_version INTEGER,
+		tenant TEXT,
+		provider TEXT,
+		idempotency_key TEXT,
+		payload TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -130,6 +146,48 @@ func (d *DB) Migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_emails_scheduled ON emails(scheduled_at);
 	CREATE INDEX IF NOT EXISTS idx_emails_template ON emails(template_slug);
 
+	-- Delivery state of each recipient of an email, so retries only go to
+	-- the recipients that have not been sent to
+	CREATE TABLE IF NOT EXISTS email_recipients (
+		email_id TEXT NOT NULL,
+		address TEXT NOT NULL,
+		status TEXT DEFAULT 'pending',
+		attempts INTEGER DEFAULT 0,
+		provider TEXT,
+		message_id TEXT,
+		error TEXT,
+		sent_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (email_id, address),
+		FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+	);
+
+	-- Failed delivery attempts of each email
+	CREATE TABLE IF NOT EXISTS email_failures (
+		email_id TEXT NOT NULL,
+		attempt INTEGER NOT NULL,
+		class TEXT NOT NULL,
+		error TEXT,
+		failed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_failures_email ON email_failures(email_id);
+
+	-- Emails delivery gave up on, with a copy of their queued payload, kept
+	-- until they are requeued or purged
+	CREATE TABLE IF NOT EXISTS dead_letters (
+		email_id TEXT PRIMARY KEY,
+		payload TEXT NOT NULL,
+		class TEXT NOT NULL,
+		error TEXT,
+		attempts INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_dead_letters_class ON dead_letters(class);
+
 	-- Email events (for tracking)
 	CREATE TABLE IF NOT EXISTS email_events (
 		id TEXT PRIMARY KEY,
@@ -143,7 +201,33 @@ func (d *DB) Migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_events_email ON email_events(email_id);
 	CREATE INDEX IF NOT EXISTS idx_events_type ON email_events(event_type);
 
-	-- SMTP providers
+	-- Addresses that are no longer sent to, such as hard bounces, spam
+	-- complaints and opt-outs, for every template or only those of one
+	-- category, until they expire. Addresses are stored in lower case.
+	CREATE TABLE IF NOT EXISTS suppressions (
+		address TEXT NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL,
+		source TEXT,
+		email_id TEXT,
+		expires_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (address, category)
+	);
+
+	-- Template categories recipients chose on their preference page.
+	-- Addresses are stored in lower case.
+	CREATE TABLE IF NOT EXISTS preferences (
+		address TEXT NOT NULL,
+		category TEXT NOT NULL,
+		subscribed INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (address, category)
+	);
+
+	-- SMTP providers. Jobs are routed by priority (low, normal, high),
+	-- template category and tenant, each a comma-separated list where empty
+	-- matches everything, and fail over in sort_order.
 	CREATE TABLE IF NOT EXISTS smtp_providers (
 		id TEXT PRIMARY KEY,
 		name TEXT UNIQUE NOT NULL,
@@ -155,12 +239,141 @@ func (d *DB) Migrate() error {
 		from_name TEXT,
 		is_default INTEGER DEFAULT 0,
 		rate_limit INTEGER,
+		enabled INTEGER DEFAULT 1,
+		sort_order INTEGER DEFAULT 0,
+		priorities TEXT,
+		categories TEXT,
+		tenants TEXT,
+		tls_mode TEXT,
+		auth TEXT,
+		ca_file TEXT,
+		insecure_skip_verify INTEGER DEFAULT 0,
+		oauth_client_id TEXT,
+		oauth_client_secret TEXT,
+		oauth_refresh_token TEXT,
+		oauth_token_url TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Webhook endpoints receiving email events. events is a comma-separated
+	-- list of event types, where empty subscribes to all of them.
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id TEXT PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT,
+		enabled INTEGER DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	-- Callbacks of email events to webhook endpoints, with the outcome of
+	-- their last attempt
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook TEXT NOT NULL,
+		url TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		email_id TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT DEFAULT 'pending',
+		attempts INTEGER DEFAULT 0,
+		response_code INTEGER,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_email ON webhook_deliveries(email_id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(status);
+
+	-- DKIM signing keys, one per sending domain (PEM-encoded private key)
+	CREATE TABLE IF NOT EXISTS dkim_keys (
+		domain TEXT PRIMARY KEY,
+		selector TEXT NOT NULL,
+		private_key TEXT NOT NULL,
+		enabled INTEGER DEFAULT 1,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	`
 
-	_, err := d.Exec(schema)
-	return err
+	if _, err := d.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema, for databases created earlier
+	columns := []struct{ table, column, definition string }{
+		{"emails", "template_version", "INTEGER"},
+		{"emails", "cc", "TEXT"},
+		{"emails", "bcc", "TEXT"},
+		{"emails", "reply_to", "TEXT"},
+		{"emails", "tenant", "TEXT"},
+		{"emails", "provider", "TEXT"},
+		{"emails", "idempotency_key", "TEXT"},
+		{"emails", "payload", "TEXT"},
+		{"smtp_providers", "enabled", "INTEGER DEFAULT 1"},
+		{"smtp_providers", "sort_order", "INTEGER DEFAULT 0"},
+		{"smtp_providers", "priorities", "TEXT"},
+		{"smtp_providers", "categories", "TEXT"},
+		{"smtp_providers", "tenants", "TEXT"},
+		{"smtp_providers", "tls_mode", "TEXT"},
+		{"smtp_providers", "auth", "TEXT"},
+		{"smtp_providers", "ca_file", "TEXT"},
+		{"smtp_providers", "insecure_skip_verify", "INTEGER DEFAULT 0"},
+		{"smtp_providers", "oauth_client_id", "TEXT"},
+		{"smtp_providers", "oauth_client_secret", "TEXT"},
+		{"smtp_providers", "oauth_refresh_token", "TEXT"},
+		{"smtp_providers", "oauth_token_url", "TEXT"},
+		{"templates", "text", "TEXT"},
+		{"template_versions", "text", "TEXT"},
+	}
+	for _, c := range columns {
+		if err := d.ensureColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	// Indexes on added columns. Idempotency keys are unique per tenant.
+	if _, err := d.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_emails_idempotency
+			ON emails(COALESCE(tenant, ''), idempotency_key) WHERE idempotency_key IS NOT NULL
+	`); err != nil {
+		return fmt.Errorf("create idempotency index: %w", err)
+	}
+
+	return nil
+}
+
+// ensureColumn adds a column to an existing table if it is missing.
+// SQLite has no ADD COLUMN IF NOT EXISTS, so the table info is checked first.
+func (d *DB) ensureColumn(table, column, definition string) error {
+	rows, err := d.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("table info %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if _, err := d.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // Tx executes a function within a transaction.
@@ -3,8 +3,10 @@ package delivery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +14,8 @@ import (
 	"github.com/joeblew999/plat-mjml/pkg/mail"
 	"github.com/joeblew999/plat-mjml/pkg/mjml"
 	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/joeblew999/plat-mjml/pkg/unsubscribe"
 	"github.com/zeromicro/go-zero/core/logx"
 	"golang.org/x/time/rate"
 	"maragu.dev/goqite"
@@ -23,6 +27,10 @@ type Config struct {
 	RetryBackoff time.Duration
 	MaxBackoff   time.Duration
 	RateLimit    int // emails per minute
+
+	// MaxDeferral bounds how long a job throttled by its providers keeps
+	// being retried, as throttling does not count as an attempt.
+	MaxDeferral time.Duration
 }
 
 // DefaultConfig returns sensible defaults.
@@ -32,6 +40,7 @@ func DefaultConfig() Config {
 		RetryBackoff: 5 * time.Minute,
 		MaxBackoff:   4 * time.Hour,
 		RateLimit:    60,
+		MaxDeferral:  24 * time.Hour,
 	}
 }
 
@@ -40,7 +49,10 @@ type Engine struct {
 	config      Config
 	queue       *queue.Queue
 	renderer    *mjml.Renderer
-	smtpConfig  mail.Config
+	templates   *template.Store
+	transport   mail.Transport
+	router      *Router
+	links       *unsubscribe.Links
 	rateLimiter *rate.Limiter
 
 	ctx    context.Context
@@ -48,8 +60,10 @@ type Engine struct {
 	wg     sync.WaitGroup
 }
 
-// NewEngine creates a new delivery engine.
-func NewEngine(q *queue.Queue, r *mjml.Renderer, smtp mail.Config, cfg Config) *Engine {
+// NewEngine creates a new delivery engine that sends through transport. The
+// template store is optional; without it templates are rendered from the
+// renderer and not versioned.
+func NewEngine(q *queue.Queue, r *mjml.Renderer, ts *template.Store, transport mail.Transport, cfg Config) *Engine {
 	// Rate limiter: N emails per minute
 	limiter := rate.NewLimiter(rate.Every(time.Minute/time.Duration(cfg.RateLimit)), 1)
 
@@ -59,13 +73,37 @@ func NewEngine(q *queue.Queue, r *mjml.Renderer, smtp mail.Config, cfg Config) *
 		config:      cfg,
 		queue:       q,
 		renderer:    r,
-		smtpConfig:  smtp,
+		templates:   ts,
+		transport:   transport,
+		router:      NewRouter([]*Provider{configuredProvider(transport)}),
 		rateLimiter: limiter,
 		ctx:         ctx,
 		cancel:      cancel,
 	}
 }
 
+// configuredProvider wraps the engine's own transport as the provider of
+// last resort, used when no other provider matches or all have failed.
+func configuredProvider(transport mail.Transport) *Provider {
+	return &Provider{Name: "configured", Transport: transport, Order: math.MaxInt}
+}
+
+// SetProviders routes email across providers, such as those returned by
+// LoadProviders, falling back to the engine's transport. It must be called
+// before Start.
+func (e *Engine) SetProviders(providers []*Provider) {
+	e.router = NewRouter(append(providers, configuredProvider(e.transport)))
+}
+
+// SetUnsubscribeLinks adds signed unsubscribe and preference links for
+// each recipient to emails in the categories links applies to. They are
+// rendered into the template as unsubscribe_url and preferences_url, and
+// the unsubscribe link is also sent as the List-Unsubscribe header. It
+// must be called before Start.
+func (e *Engine) SetUnsubscribeLinks(links *unsubscribe.Links) {
+	e.links = links
+}
+
 // Start starts the delivery engine with the specified number of workers.
 func (e *Engine) Start(workers int) {
 	logx.Infow("Delivery engine started", logx.Field("workers", workers))
@@ -80,6 +118,7 @@ func (e *Engine) Stop() {
 	logx.Info("Delivery engine stopping, waiting for workers")
 	e.cancel()
 	e.wg.Wait()
+	e.router.Close()
 	logx.Info("Delivery engine stopped")
 }
 
@@ -115,72 +154,472 @@ func (e *Engine) processJob(job *queue.EmailJob, msg *goqite.Message) {
 		logx.Field("recipients", job.Recipients),
 	)
 
+	// The queued copy of the job is never updated, so attempts, the
+	// template version rendered, and any cancellation or new send time,
+	// are read from the emails table
+	if stored, err := e.queue.GetStatus(ctx, job.ID); err == nil && stored != nil {
+		if stored.Status == "cancelled" {
+			e.queue.Delete(ctx, msg)
+			return
+		}
+		if stored.ScheduledAt != nil && time.Until(*stored.ScheduledAt) > time.Second {
+			e.queue.Requeue(ctx, job, msg, time.Until(*stored.ScheduledAt))
+			return
+		}
+		job.Attempts = stored.Attempts
+		job.ScheduledAt = stored.ScheduledAt
+		// A retry renders the version the first attempt recorded, even if
+		// a newer one was published since
+		if job.TemplateVersion == 0 {
+			job.TemplateVersion = stored.TemplateVersion
+		}
+	}
+
 	// Update status to processing
 	e.queue.UpdateStatus(ctx, job.ID, "processing", nil)
+	e.queue.RecordEvent(ctx, job.ID, queue.EventProcessing, map[string]any{"attempt": job.Attempts + 1})
 
 	// Apply rate limiting
 	if err := e.rateLimiter.Wait(ctx); err != nil {
-		e.handleError(ctx, job, msg, err)
+		e.handleError(ctx, job, msg, err, mail.Transient)
 		return
 	}
 
-	// Render template
-	html, err := e.renderer.RenderTemplate(job.TemplateSlug, job.Data)
+	// Render the template once for every recipient, unless each is sent
+	// their own message rendered with their unsubscribe links
+	route := Route{Priority: job.Priority, Category: e.category(ctx, job.TemplateSlug), Tenant: job.Tenant}
+	personal := e.links != nil && e.links.Applies(route.Category)
+	var result *mjml.Result
+	var err error
+	version := job.TemplateVersion
+	if personal && len(job.Cc) == 0 && len(job.Bcc) == 0 {
+		version, err = e.templateVersion(ctx, job.TemplateSlug, version)
+	} else {
+		result, version, err = e.render(ctx, job.TemplateSlug, version, job.Data, false)
+		if err == nil {
+			e.rendered(ctx, job, version, result)
+		}
+	}
 	if err != nil {
-		e.handleError(ctx, job, msg, fmt.Errorf("render template: %w", err))
+		e.handleError(ctx, job, msg, fmt.Errorf("render template: %w", err), mail.Transient)
 		return
 	}
 
-	// Send email to each recipient, collecting failures
-	var sendErrors []string
-	for _, recipient := range job.Recipients {
-		if err := mail.Send(e.smtpConfig, recipient, job.Subject, html); err != nil {
-			sendErrors = append(sendErrors, fmt.Sprintf("send to %s: %v", recipient, err))
+	// Recipients already sent to, or rejected, on an earlier attempt are
+	// skipped so a retry never sends anyone a second copy
+	recipients, err := e.queue.Recipients(ctx, job.ID)
+	if err != nil {
+		e.handleError(ctx, job, msg, fmt.Errorf("load recipients: %w", err), mail.Transient)
+		return
+	}
+	var pending []string
+	for _, r := range recipients {
+		if !r.Done() {
+			pending = append(pending, r.Address)
+		}
+	}
+
+	// Addresses suppressed since the job was queued are skipped too, and
+	// messages to several recipients go only to those still pending
+	suppressed, err := e.queue.SuppressRecipients(ctx, job.ID, pending, route.Category)
+	if err != nil {
+		e.handleError(ctx, job, msg, fmt.Errorf("check suppressions: %w", err), mail.Transient)
+		return
+	}
+	skip := make(map[string]bool, len(suppressed))
+	for addr := range suppressed {
+		skip[addr] = true
+	}
+	for _, r := range recipients {
+		if r.Done() {
+			skip[r.Address] = true
+		}
+	}
+
+	// Send the remaining messages, recording the outcome for each recipient
+	var sendErrors []error
+	var provider string
+	var contended bool
+	for _, m := range messages(job) {
+		m = withoutAddresses(m, skip)
+		addrs := addresses(m)
+		if len(addrs) == 0 {
+			continue
+		}
+		if personal && len(addrs) == 1 {
+			personalized, err := e.personalize(ctx, job, version, route.Category, &m)
+			if err != nil {
+				e.handleError(ctx, job, msg, fmt.Errorf("render template for %s: %w", addrs[0], err), mail.Transient)
+				return
+			}
+			if result == nil {
+				result = personalized
+				e.rendered(ctx, job, version, result)
+			}
+		} else {
+			m.HTML, m.Text = result.HTML, result.Text
+		}
+
+		// Another worker may have received the job again if its visibility
+		// timeout lapsed, so recipients it has sent to, or is sending to,
+		// are skipped, and the timeout is extended for this send
+		claimed, err := e.queue.ClaimRecipients(ctx, job.ID, addrs)
+		if err != nil {
+			e.handleError(ctx, job, msg, fmt.Errorf("claim recipients: %w", err), mail.Transient)
+			return
+		}
+		if len(claimed) < len(addrs) {
+			contended = true
+			for _, addr := range addrs {
+				if !slices.Contains(claimed, addr) {
+					skip[addr] = true
+				}
+			}
+			m = withoutAddresses(m, skip)
+			addrs = addresses(m)
+			if len(addrs) == 0 {
+				continue
+			}
+		}
+		e.queue.Extend(ctx, msg, queue.VisibilityTimeout)
+
+		// A message that reached some of its recipients fails for the
+		// others only, each with the reply to its own address
+		name, messageID, err := e.router.Send(ctx, route, m)
+		var partial *mail.RecipientError
+		if errors.As(err, &partial) {
+			err = nil
+		} else if err != nil {
+			sendErrors = append(sendErrors, fmt.Errorf("send to %s: %w", strings.Join(m.To, ", "), err))
+		}
+		var sent []string
+		for _, addr := range addrs {
+			addrErr := err
+			if partial != nil {
+				if addrErr = partial.For(addr); addrErr != nil {
+					sendErrors = append(sendErrors, fmt.Errorf("send to %s: %w", addr, addrErr))
+				}
+			}
+			if addrErr == nil {
+				sent = append(sent, addr)
+			}
+			e.queue.RecordDelivery(ctx, job.ID, addr, name, messageID, addrErr, mail.Classify(addrErr) == mail.Permanent)
 		}
+		if len(sent) > 0 {
+			provider = name
+			e.queue.RecordEvent(ctx, job.ID, queue.EventSent, map[string]any{
+				"recipients": sent,
+				"provider":   name,
+				"message_id": messageID,
+			})
+		}
+	}
+	if provider != "" {
+		e.queue.SetProvider(ctx, job.ID, provider)
 	}
 
 	if len(sendErrors) > 0 {
-		e.handleError(ctx, job, msg, fmt.Errorf("%s", strings.Join(sendErrors, "; ")))
+		e.handleError(ctx, job, msg, errors.Join(sendErrors...), jobClass(sendErrors))
 		return
 	}
 
-	// Success - mark as sent and delete from queue
-	e.queue.MarkSent(ctx, job.ID, "")
-	e.queue.Delete(ctx, msg)
+	// The worker still sending to some recipients finishes the job
+	if contended && e.sendingElsewhere(ctx, job.ID) {
+		logx.Infow("Email is being sent by another worker", logx.Field("id", job.ID))
+		return
+	}
+
+	e.queue.RecordAttempt(ctx, job.ID)
+	status := e.finish(ctx, job, msg, nil)
 
 	logx.Infow("Email sent",
 		logx.Field("id", job.ID),
 		logx.Field("template", job.TemplateSlug),
 		logx.Field("recipients", job.Recipients),
+		logx.Field("status", status),
+		logx.Field("provider", provider),
 	)
 }
 
-func (e *Engine) handleError(ctx context.Context, job *queue.EmailJob, msg *goqite.Message, err error) {
-	job.Attempts++
+// sendingElsewhere reports whether a recipient of an email is claimed by a
+// worker that has not recorded its delivery yet.
+func (e *Engine) sendingElsewhere(ctx context.Context, id string) bool {
+	recipients, err := e.queue.Recipients(ctx, id)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(recipients, func(r *queue.Recipient) bool { return r.Status == "sending" })
+}
+
+// finish records the outcome of a job with no recipients left to retry,
+// removes it from the queue and returns its final status: sent when every
+// recipient that is not suppressed was sent to, suppressed when they all
+// are, failed when none were sent to and partial otherwise.
+// err is the job's error when no recipient has one of its own.
+func (e *Engine) finish(ctx context.Context, job *queue.EmailJob, msg *goqite.Message, err error) string {
+	recipients, _ := e.queue.Recipients(ctx, job.ID)
+	var sent, suppressed int
+	var failed []error
+	for _, r := range recipients {
+		switch r.Status {
+		case "sent":
+			sent++
+		case "failed":
+			failed = append(failed, fmt.Errorf("%s: %s", r.Address, r.Error))
+		case "suppressed":
+			suppressed++
+		}
+	}
+	if len(failed) > 0 {
+		err = errors.Join(failed...)
+	}
+
+	status := "sent"
+	switch {
+	case sent == 0 && err == nil && suppressed > 0:
+		status = "suppressed"
+		e.queue.UpdateStatus(ctx, job.ID, status, queue.ErrSuppressed)
+	case sent == 0 && err != nil:
+		status = "failed"
+		e.queue.UpdateStatus(ctx, job.ID, status, err)
+	case len(failed) > 0:
+		status = "partial"
+		e.queue.UpdateStatus(ctx, job.ID, status, err)
+	default:
+		e.queue.MarkSent(ctx, job.ID, "")
+	}
+	if status == "failed" || status == "partial" {
+		e.queue.RecordEvent(ctx, job.ID, queue.EventFailed, map[string]any{
+			"status": status,
+			"error":  err.Error(),
+		})
+	}
+	e.queue.Delete(ctx, msg)
+	return status
+}
+
+// category returns the template category used to route a job, or "" when
+// the template is not managed by the template store.
+func (e *Engine) category(ctx context.Context, slug string) string {
+	if e.templates == nil {
+		return ""
+	}
+	t, err := e.templates.Get(ctx, slug)
+	if err != nil || t == nil {
+		return ""
+	}
+	return t.Category
+}
+
+// messages builds the messages for a job, without their HTML and text.
+// Each recipient gets a separate message, unless the job has Cc or Bcc
+// recipients, in which case a single message is addressed to everyone.
+func messages(job *queue.EmailJob) []mail.Message {
+	if len(job.Cc) > 0 || len(job.Bcc) > 0 {
+		return []mail.Message{{
+			To:          job.Recipients,
+			Cc:          job.Cc,
+			Bcc:         job.Bcc,
+			ReplyTo:     job.ReplyTo,
+			Subject:     job.Subject,
+			Headers:     job.Headers,
+			Attachments: job.Attachments,
+		}}
+	}
+
+	msgs := make([]mail.Message, 0, len(job.Recipients))
+	for _, recipient := range job.Recipients {
+		msgs = append(msgs, mail.Message{
+			To:          []string{recipient},
+			ReplyTo:     job.ReplyTo,
+			Subject:     job.Subject,
+			Headers:     job.Headers,
+			Attachments: job.Attachments,
+		})
+	}
+	return msgs
+}
+
+// personalize renders m for its only recipient with their unsubscribe and
+// preference links, and sets the unsubscribe link as its one-click
+// unsubscribe URL. The links are given to the template as unsubscribe_url
+// and preferences_url, and the unsubscribe link as UnsubscribeURL for
+// templates written against mjml.NewsletterData. The render is not cached,
+// since no other message has the same links.
+func (e *Engine) personalize(ctx context.Context, job *queue.EmailJob, version int, category string, m *mail.Message) (*mjml.Result, error) {
+	claims := unsubscribe.Claims{
+		Address:  mail.Recipients(*m)[0],
+		Category: category,
+		EmailID:  job.ID,
+	}
+	link := e.links.URL(claims)
+
+	data := make(map[string]any, len(job.Data)+3)
+	for k, v := range job.Data {
+		data[k] = v
+	}
+	data["unsubscribe_url"] = link
+	data["UnsubscribeURL"] = link
+	data["preferences_url"] = e.links.PreferencesURL(claims)
+
+	result, _, err := e.render(ctx, job.TemplateSlug, version, data, true)
+	if err != nil {
+		return nil, err
+	}
+	m.HTML, m.Text, m.Unsubscribe = result.HTML, result.Text, link
+	return result, nil
+}
+
+// addresses returns the To, Cc and Bcc addresses of a message as given.
+func addresses(m mail.Message) []string {
+	return slices.Concat(m.To, m.Cc, m.Bcc)
+}
+
+// withoutAddresses returns m without the recipients in skip.
+func withoutAddresses(m mail.Message, skip map[string]bool) mail.Message {
+	keep := func(list []string) []string {
+		return slices.DeleteFunc(slices.Clone(list), func(addr string) bool { return skip[addr] })
+	}
+	m.To, m.Cc, m.Bcc = keep(m.To), keep(m.Cc), keep(m.Bcc)
+	return m
+}
+
+// rendered records the template version rendered for a job and a
+// rendered event.
+func (e *Engine) rendered(ctx context.Context, job *queue.EmailJob, version int, result *mjml.Result) {
+	if version > 0 {
+		e.queue.SetTemplateVersion(ctx, job.ID, version)
+	}
+	e.queue.RecordEvent(ctx, job.ID, queue.EventRendered, map[string]any{
+		"template":         job.TemplateSlug,
+		"template_version": version,
+		"size":             len(result.HTML),
+	})
+}
+
+// templateVersion returns the version render would use for a template:
+// the pinned version when one is given, otherwise the published version,
+// or 0 when the template is not managed by the template store.
+func (e *Engine) templateVersion(ctx context.Context, slug string, version int) (int, error) {
+	if e.templates == nil || version > 0 {
+		return version, nil
+	}
+	t, err := e.templates.Get(ctx, slug)
+	if err != nil || t == nil || t.Status != template.StatusPublished {
+		return 0, err
+	}
+	return t.Version, nil
+}
+
+// render renders a template, honouring a pinned version when one is given.
+// It returns the HTML and plain-text parts and the template version used,
+// or 0 when the template is not managed by the template store. Personal
+// data, unique to one recipient, is rendered without the render cache.
+func (e *Engine) render(ctx context.Context, slug string, version int, data map[string]any, personal bool) (*mjml.Result, int, error) {
+	renderFn := e.renderer.Render
+	if personal {
+		renderFn = e.renderer.RenderUncached
+	}
+	if e.templates == nil {
+		result, err := renderFn(slug, data)
+		return result, 0, err
+	}
+
+	if version > 0 {
+		result, err := e.templates.RenderVersion(ctx, slug, version, data)
+		return result, version, err
+	}
+
+	t, err := e.templates.Get(ctx, slug)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result, err := renderFn(slug, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if t == nil || t.Status != template.StatusPublished {
+		return result, 0, nil
+	}
+	return result, t.Version, nil
+}
+
+// jobClass classifies the failures of a job's messages. The job fails
+// permanently only when every message did, since a retry can still reach
+// the other recipients.
+func jobClass(errs []error) mail.Class {
+	class := mail.Permanent
+	for _, err := range errs {
+		switch mail.Classify(err) {
+		case mail.Throttled:
+			class = mail.Throttled
+		case mail.Transient:
+			if class == mail.Permanent {
+				class = mail.Transient
+			}
+		}
+	}
+	return class
+}
+
+func (e *Engine) handleError(ctx context.Context, job *queue.EmailJob, msg *goqite.Message, err error, class mail.Class) {
+	// Throttling is not the message's fault, so it does not use up an
+	// attempt, but is only retried for MaxDeferral
+	if class != mail.Throttled {
+		attempts, dbErr := e.queue.RecordAttempt(ctx, job.ID)
+		if dbErr != nil {
+			attempts = job.Attempts + 1
+		}
+		job.Attempts = attempts
+	}
 	job.Error = err.Error()
 
-	// Check if permanent failure
-	if isPermanentFailure(err) || job.Attempts >= job.MaxAttempts {
-		e.queue.UpdateStatus(ctx, job.ID, "failed", err)
-		e.queue.Delete(ctx, msg)
+	start := job.CreatedAt
+	if job.ScheduledAt != nil && job.ScheduledAt.After(start) {
+		start = *job.ScheduledAt
+	}
+	deferredTooLong := class == mail.Throttled && e.config.MaxDeferral > 0 && time.Since(start) > e.config.MaxDeferral
+
+	e.queue.RecordFailure(ctx, job.ID, job.Attempts, class.String(), err)
+
+	if class == mail.Permanent || job.Attempts >= job.MaxAttempts || deferredTooLong {
+		e.queue.FailRecipients(ctx, job.ID, err)
+		status := e.finish(ctx, job, msg, err)
+		if status != "sent" {
+			e.queue.DeadLetter(ctx, job.ID, class.String(), err)
+		}
 		logx.Errorw("Email delivery failed permanently",
 			logx.Field("id", job.ID),
+			logx.Field("status", status),
 			logx.Field("attempts", job.Attempts),
+			logx.Field("class", class.String()),
 			logx.Field("error", err.Error()),
 		)
 		return
 	}
 
 	// Schedule retry with backoff
-	backoff := e.calculateBackoff(job.Attempts)
+	backoff := e.calculateBackoff(max(job.Attempts, 1))
 	e.queue.UpdateStatus(ctx, job.ID, "retry", err)
 
-	// Extend the message timeout to retry later
-	e.queue.Extend(ctx, msg, backoff)
+	// Requeue rather than extend, as every receive of a message counts
+	// towards goqite's limit and throttled retries do not end in attempts
+	if err := e.queue.Requeue(ctx, job, msg, backoff); err != nil {
+		logx.Errorw("Failed to requeue email", logx.Field("id", job.ID), logx.Field("error", err.Error()))
+		e.queue.Extend(ctx, msg, backoff)
+	}
+	e.queue.RecordEvent(ctx, job.ID, queue.EventDeferred, map[string]any{
+		"attempt":  job.Attempts,
+		"class":    class.String(),
+		"retry_in": backoff.String(),
+		"error":    err.Error(),
+	})
 
 	logx.Infow("Email delivery retrying",
 		logx.Field("id", job.ID),
 		logx.Field("attempt", job.Attempts),
+		logx.Field("class", class.String()),
 		logx.Field("backoff", backoff.String()),
 		logx.Field("error", err.Error()),
 	)
@@ -194,35 +633,46 @@ func (e *Engine) calculateBackoff(attempts int) time.Duration {
 	return backoff
 }
 
-// isPermanentFailure checks if the error indicates a permanent failure.
-func isPermanentFailure(err error) bool {
-	msg := err.Error()
-	// SMTP 5xx codes are permanent failures
-	permanentCodes := []string{"550", "551", "552", "553", "554"}
-	for _, code := range permanentCodes {
-		if strings.Contains(msg, code) {
-			return true
-		}
+// SendNow sends an email immediately without queueing. Suppressed
+// recipients are skipped like those of queued jobs; when every recipient
+// is suppressed the error is a *queue.SuppressedError.
+func (e *Engine) SendNow(ctx context.Context, templateSlug string, recipients []string, subject string, data map[string]any) error {
+	route := Route{Priority: queue.PriorityNormal, Category: e.category(ctx, templateSlug)}
+	suppressed, err := e.queue.Suppressed(ctx, recipients, route.Category)
+	if err != nil {
+		return fmt.Errorf("check suppressions: %w", err)
+	}
+	if queue.AllSuppressed(recipients, suppressed) {
+		return &queue.SuppressedError{Addresses: recipients}
 	}
-	return false
-}
 
-// SendNow sends an email immediately without queueing.
-func (e *Engine) SendNow(ctx context.Context, templateSlug string, recipients []string, subject string, data map[string]any) error {
 	// Apply rate limiting
 	if err := e.rateLimiter.Wait(ctx); err != nil {
 		return err
 	}
 
 	// Render template
-	html, err := e.renderer.RenderTemplate(templateSlug, data)
+	result, _, err := e.render(ctx, templateSlug, 0, data, false)
 	if err != nil {
 		return fmt.Errorf("render template: %w", err)
 	}
 
-	// Send to each recipient
+	// Send to each recipient that is not suppressed
 	for _, recipient := range recipients {
-		if err := mail.Send(e.smtpConfig, recipient, subject, html); err != nil {
+		if s := suppressed[recipient]; s != nil {
+			logx.Infow("Skipped suppressed recipient",
+				logx.Field("recipient", recipient),
+				logx.Field("reason", s.Reason),
+			)
+			continue
+		}
+		msg := mail.Message{
+			To:      []string{recipient},
+			Subject: subject,
+			HTML:    result.HTML,
+			Text:    result.Text,
+		}
+		if _, _, err := e.router.Send(ctx, route, msg); err != nil {
 			return fmt.Errorf("send to %s: %w", recipient, err)
 		}
 	}
@@ -0,0 +1,512 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/mail"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/joeblew999/plat-mjml/pkg/template"
+	"github.com/joeblew999/plat-mjml/pkg/unsubscribe"
+)
+
+// recipientTransport fails sends to the addresses in errs and records the
+// others.
+type recipientTransport struct {
+	errs map[string]error
+	sent []string
+}
+
+func (f *recipientTransport) Send(ctx context.Context, msg mail.Message) error {
+	if err := f.errs[msg.To[0]]; err != nil {
+		return err
+	}
+	f.sent = append(f.sent, msg.To...)
+	return nil
+}
+
+// messageTransport fails with err, if set, and records the messages sent.
+type messageTransport struct {
+	err  error
+	msgs []mail.Message
+}
+
+func (f *messageTransport) Send(ctx context.Context, msg mail.Message) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.msgs = append(f.msgs, msg)
+	return nil
+}
+
+// partialTransport refuses full@example.com with a full mailbox the first
+// time, delivering to the other recipients, as an SMTP server would.
+type partialTransport struct {
+	*messageTransport
+}
+
+func (f partialTransport) Send(ctx context.Context, msg mail.Message) error {
+	addrs := addresses(msg)
+	if len(f.msgs) > 0 || !slices.Contains(addrs, "full@example.com") {
+		return f.messageTransport.Send(ctx, msg)
+	}
+	f.msgs = append(f.msgs, msg)
+	return &mail.RecipientError{
+		Sent:   slices.DeleteFunc(addrs, func(addr string) bool { return addr == "full@example.com" }),
+		Failed: map[string]error{"full@example.com": &mail.SMTPError{Code: 452, Enhanced: "4.2.2", Message: "Mailbox full"}},
+	}
+}
+
+// hookTransport calls onSend, if set, before recording each message sent.
+type hookTransport struct {
+	onSend func(msg mail.Message)
+	sent   []string
+}
+
+func (f *hookTransport) Send(ctx context.Context, msg mail.Message) error {
+	if f.onSend != nil {
+		f.onSend(msg)
+	}
+	f.sent = append(f.sent, msg.To...)
+	return nil
+}
+
+func newTestEngine(t *testing.T, transport mail.Transport) (*Engine, *queue.Queue) {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	renderer := mjml.NewRenderer(mjml.WithFonts(false))
+	if err := renderer.LoadTemplate("hello", `<mjml><mj-body><mj-section><mj-column><mj-text>Hi</mj-text></mj-column></mj-section></mj-body></mjml>`); err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.RateLimit = 60000
+	return NewEngine(q, renderer, nil, transport, cfg), q
+}
+
+// receive enqueues job and returns it as a worker would receive it.
+func receive(t *testing.T, q *queue.Queue, job queue.EmailJob) (*queue.EmailJob, func(*Engine)) {
+	t.Helper()
+
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	received, msg, err := q.Receive(ctx)
+	if err != nil || received == nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	return received, func(e *Engine) { e.processJob(received, msg) }
+}
+
+func recipientStatus(t *testing.T, q *queue.Queue, id string) map[string]string {
+	t.Helper()
+
+	recipients, err := q.Recipients(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Recipients failed: %v", err)
+	}
+	out := make(map[string]string)
+	for _, r := range recipients {
+		out[r.Address] = r.Status
+	}
+	return out
+}
+
+func TestRetryOnlyFailedRecipients(t *testing.T) {
+	transport := &recipientTransport{errs: map[string]error{
+		"b@example.com": errors.New("dial tcp: connection refused"),
+		"c@example.com": &mail.SMTPError{Code: 550, Enhanced: "5.1.1", Message: "No such user"},
+	}}
+	e, q := newTestEngine(t, transport)
+	ctx := context.Background()
+
+	job, process := receive(t, q, queue.EmailJob{
+		ID:           "job-1",
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com", "b@example.com", "c@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+
+	process(e)
+	status := recipientStatus(t, q, job.ID)
+	if status["a@example.com"] != "sent" || status["b@example.com"] != "retry" || status["c@example.com"] != "failed" {
+		t.Fatalf("Unexpected recipient status after first attempt: %v", status)
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.Status != "retry" || stored.Attempts != 1 {
+		t.Errorf("Expected retry after 1 attempt, got %s after %d", stored.Status, stored.Attempts)
+	}
+
+	delete(transport.errs, "b@example.com")
+	process(e)
+	if len(transport.sent) != 2 || transport.sent[1] != "b@example.com" {
+		t.Errorf("Retry should only send to b@example.com, sent %v", transport.sent)
+	}
+	stored, _ := q.GetStatus(ctx, job.ID)
+	if stored.Status != "partial" || stored.Attempts != 2 {
+		t.Errorf("Expected partial after 2 attempts, got %s after %d", stored.Status, stored.Attempts)
+	}
+
+	recipients, _ := q.Recipients(ctx, job.ID)
+	if recipients[1].Attempts != 2 || recipients[1].Provider != "configured" || recipients[1].SentAt == nil {
+		t.Errorf("Unexpected delivery record %+v", recipients[1])
+	}
+
+	events, err := q.Events(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	want := []string{"queued", "processing", "rendered", "sent", "deferred", "processing", "rendered", "sent", "failed"}
+	if !slices.Equal(types, want) {
+		t.Errorf("Expected events %v, got %v", want, types)
+	}
+	if last := events[len(events)-1]; last.Details["status"] != "partial" {
+		t.Errorf("Expected failed event to record partial status, got %v", last.Details)
+	}
+}
+
+func TestRetryOnlyRefusedRecipients(t *testing.T) {
+	transport := &messageTransport{}
+	router := NewRouter([]*Provider{{Name: "smtp", Transport: partialTransport{transport}}})
+	e, q := newTestEngine(t, nil)
+	e.router = router
+	ctx := context.Background()
+
+	job, process := receive(t, q, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com"},
+		Cc:           []string{"full@example.com"},
+		Bcc:          []string{"c@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+
+	process(e)
+	status := recipientStatus(t, q, job.ID)
+	if status["a@example.com"] != "sent" || status["full@example.com"] != "retry" || status["c@example.com"] != "sent" {
+		t.Fatalf("Unexpected recipient status after first attempt: %v", status)
+	}
+
+	process(e)
+	if len(transport.msgs) != 2 || strings.Join(addresses(transport.msgs[1]), ",") != "full@example.com" {
+		t.Errorf("Retry should only send to full@example.com, sent %v", transport.msgs)
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.Status != "sent" {
+		t.Errorf("Expected sent, got %s", stored.Status)
+	}
+}
+
+func TestMaxAttemptsFromDatabase(t *testing.T) {
+	transport := &recipientTransport{errs: map[string]error{
+		"a@example.com": errors.New("dial tcp: connection refused"),
+	}}
+	e, q := newTestEngine(t, transport)
+
+	job, process := receive(t, q, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+		MaxAttempts:  2,
+	})
+
+	// The queued job body always says 0 attempts
+	for i := 0; i < 2; i++ {
+		job.Attempts = 0
+		process(e)
+	}
+
+	stored, _ := q.GetStatus(context.Background(), job.ID)
+	if stored.Status != "failed" || stored.Attempts != 2 {
+		t.Errorf("Expected failed after 2 attempts, got %s after %d", stored.Status, stored.Attempts)
+	}
+	if status := recipientStatus(t, q, job.ID); status["a@example.com"] != "failed" {
+		t.Errorf("Expected recipient to be failed, got %v", status)
+	}
+	// Giving up moves the job to the dead-letter store with its history
+	dead, err := q.GetDeadLetter(context.Background(), job.ID)
+	if err != nil || dead == nil {
+		t.Fatalf("Expected a dead letter, got %v", err)
+	}
+	if dead.Class != "transient" || dead.Attempts != 2 || len(dead.History) != 2 || len(dead.Payload) == 0 {
+		t.Errorf("Unexpected dead letter %+v", dead)
+	}
+}
+
+func TestRetryRendersPinnedVersion(t *testing.T) {
+	transport := &messageTransport{err: errors.New("dial tcp: connection refused")}
+	e, q := newTestEngine(t, transport)
+	ctx := context.Background()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "templates.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	e.templates = template.NewStore(database.DB, e.renderer)
+
+	content := `<mjml><mj-body><mj-section><mj-column><mj-text>%s</mj-text></mj-column></mj-section></mj-body></mjml>`
+	if err := e.templates.Create(ctx, &template.Template{Slug: "notice", Content: fmt.Sprintf(content, "Version one")}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	job, process := receive(t, q, queue.EmailJob{
+		TemplateSlug: "notice",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Notice",
+		Priority:     queue.PriorityNormal,
+	})
+	process(e)
+
+	// Version 2 goes live before the retry
+	if err := e.templates.Update(ctx, &template.Template{Slug: "notice", Content: fmt.Sprintf(content, "Version two")}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	transport.err = nil
+	job.TemplateVersion = 0
+	process(e)
+
+	if len(transport.msgs) != 1 || !strings.Contains(transport.msgs[0].HTML, "Version one") {
+		t.Fatalf("Expected the retry to send version 1, got %d messages", len(transport.msgs))
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.TemplateVersion != 1 {
+		t.Errorf("Expected template version 1 to be recorded, got %d", stored.TemplateVersion)
+	}
+}
+
+func TestThrottledRetriesKeepReceiving(t *testing.T) {
+	transport := &fakeTransport{err: &mail.SMTPError{Code: 421, Enhanced: "4.7.0", Message: "Try again later"}}
+	e, q := newTestEngine(t, transport)
+	e.config.RetryBackoff = 0
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	// Throttling does not use up attempts, so the job must stay receivable
+	// for more deferrals than goqite's default of 3 receives
+	for i := 0; i < 5; i++ {
+		job, msg, err := q.Receive(ctx)
+		if err != nil || job == nil {
+			t.Fatalf("Receive %d failed: %v", i+1, err)
+		}
+		e.processJob(job, msg)
+	}
+	if stored, _ := q.GetStatus(ctx, id); stored.Status != "retry" || stored.Attempts != 0 {
+		t.Fatalf("Expected retry with no attempts used, got %s after %d", stored.Status, stored.Attempts)
+	}
+
+	transport.err = nil
+	job, msg, err := q.Receive(ctx)
+	if err != nil || job == nil {
+		t.Fatalf("Receive after throttling failed: %v", err)
+	}
+	e.processJob(job, msg)
+	if stored, _ := q.GetStatus(ctx, id); stored.Status != "sent" || transport.sends != 6 {
+		t.Errorf("Expected sent after 6 sends, got %s after %d", stored.Status, transport.sends)
+	}
+}
+
+func TestSecondReceiveDuringProcessing(t *testing.T) {
+	transport := &hookTransport{}
+	e, q := newTestEngine(t, transport)
+	ctx := context.Background()
+
+	if _, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com", "b@example.com", "c@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	job, msg, err := q.Receive(ctx)
+	if err != nil || job == nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+
+	// While the first worker sends to a@example.com, its visibility timeout
+	// lapses and a second worker receives the job
+	transport.onSend = func(mail.Message) {
+		transport.onSend = nil
+		if err := q.Extend(ctx, msg, 0); err != nil {
+			t.Fatalf("Failed to expire message: %v", err)
+		}
+		again, msg2, err := q.Receive(ctx)
+		if err != nil || again == nil {
+			t.Fatalf("Expected the job to be received again, got %v", err)
+		}
+		e.processJob(again, msg2)
+		if stored, _ := q.GetStatus(ctx, job.ID); stored.Status == "sent" {
+			t.Error("The second worker should leave the job to the worker still sending")
+		}
+	}
+	e.processJob(job, msg)
+
+	slices.Sort(transport.sent)
+	if !slices.Equal(transport.sent, []string{"a@example.com", "b@example.com", "c@example.com"}) {
+		t.Errorf("Expected each recipient to be sent to once, sent %v", transport.sent)
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.Status != "sent" {
+		t.Errorf("Expected sent, got %s", stored.Status)
+	}
+	if received, _, _ := q.Receive(ctx); received != nil {
+		t.Error("Expected the job to be removed from the queue")
+	}
+}
+
+func TestSkipSuppressedRecipients(t *testing.T) {
+	transport := &recipientTransport{}
+	e, q := newTestEngine(t, transport)
+	ctx := context.Background()
+
+	job, process := receive(t, q, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"a@example.com", "b@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+
+	// b@example.com bounces on another email after this one was queued
+	q.Suppress(ctx, queue.Suppression{Address: "B@example.com", Reason: queue.SuppressBounce})
+
+	process(e)
+	if !slices.Equal(transport.sent, []string{"a@example.com"}) {
+		t.Errorf("Expected only a@example.com to be sent to, sent %v", transport.sent)
+	}
+	status := recipientStatus(t, q, job.ID)
+	if status["b@example.com"] != "suppressed" {
+		t.Errorf("Expected b@example.com to be suppressed, got %v", status)
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.Status != "sent" {
+		t.Errorf("Expected sent, got %s", stored.Status)
+	}
+
+	// Every recipient suppressed by the time it is sent
+	job, process = receive(t, q, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"c@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	q.Suppress(ctx, queue.Suppression{Address: "c@example.com", Reason: queue.SuppressComplaint})
+	process(e)
+	if len(transport.sent) != 1 {
+		t.Errorf("Expected nothing more to be sent, sent %v", transport.sent)
+	}
+	if stored, _ := q.GetStatus(ctx, job.ID); stored.Status != "suppressed" {
+		t.Errorf("Expected suppressed, got %s", stored.Status)
+	}
+}
+
+func TestSendNowSkipsSuppressed(t *testing.T) {
+	transport := &recipientTransport{}
+	e, q := newTestEngine(t, transport)
+	ctx := context.Background()
+
+	q.Suppress(ctx, queue.Suppression{Address: "b@example.com", Reason: queue.SuppressBounce})
+
+	if err := e.SendNow(ctx, "hello", []string{"a@example.com", "B@example.com"}, "Hello", nil); err != nil {
+		t.Fatalf("SendNow failed: %v", err)
+	}
+	if !slices.Equal(transport.sent, []string{"a@example.com"}) {
+		t.Errorf("Expected only a@example.com to be sent to, sent %v", transport.sent)
+	}
+
+	var suppressedErr *queue.SuppressedError
+	if err := e.SendNow(ctx, "hello", []string{"b@example.com", "B@example.com"}, "Hello", nil); !errors.As(err, &suppressedErr) {
+		t.Errorf("Expected a SuppressedError, got %v", err)
+	}
+	if len(transport.sent) != 1 {
+		t.Errorf("Expected nothing more to be sent, sent %v", transport.sent)
+	}
+}
+
+func TestUnsubscribeLinks(t *testing.T) {
+	transport := &messageTransport{}
+	e, q := newTestEngine(t, transport)
+	e.renderer = mjml.NewRenderer(mjml.WithFonts(false), mjml.WithCache(true))
+	for name, content := range map[string]string{
+		"news":  `<mjml><mj-body><mj-section><mj-column><mj-text><a href="{{.unsubscribe_url}}">Unsubscribe</a></mj-text></mj-column></mj-section></mj-body></mjml>`,
+		"hello": `<mjml><mj-body><mj-section><mj-column><mj-text>Hi</mj-text></mj-column></mj-section></mj-body></mjml>`,
+	} {
+		if err := e.renderer.LoadTemplate(name, content); err != nil {
+			t.Fatalf("Failed to load template: %v", err)
+		}
+	}
+	links := unsubscribe.NewLinks("s3cret", "https://mail.example.com/", nil)
+	e.SetUnsubscribeLinks(links)
+
+	job, process := receive(t, q, queue.EmailJob{
+		TemplateSlug: "news",
+		Recipients:   []string{"Ada <ada@example.com>", "bob@example.com"},
+		Subject:      "News",
+		Priority:     queue.PriorityNormal,
+	})
+	process(e)
+
+	if len(transport.msgs) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(transport.msgs))
+	}
+	for i, want := range []string{"ada@example.com", "bob@example.com"} {
+		m := transport.msgs[i]
+		token, ok := strings.CutPrefix(m.Unsubscribe, "https://mail.example.com/unsubscribe?token=")
+		if !ok {
+			t.Fatalf("Unexpected unsubscribe URL %q", m.Unsubscribe)
+		}
+		claims, err := links.Verify(token)
+		if err != nil || claims.Address != want || claims.EmailID != job.ID {
+			t.Errorf("Unexpected claims %+v, %v", claims, err)
+		}
+		if !strings.Contains(m.HTML, m.Unsubscribe) {
+			t.Errorf("Expected the link for %s in the HTML", want)
+		}
+	}
+	if n := e.renderer.GetCacheSize(); n != 0 {
+		t.Errorf("Expected personalised renders not to be cached, got %d entries", n)
+	}
+
+	// Messages to several recipients at once cannot carry one link
+	transport.msgs = nil
+	_, process = receive(t, q, queue.EmailJob{
+		TemplateSlug: "hello",
+		Recipients:   []string{"ada@example.com"},
+		Cc:           []string{"bob@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	process(e)
+	if len(transport.msgs) != 1 || transport.msgs[0].Unsubscribe != "" {
+		t.Errorf("Expected no unsubscribe link with Cc, got %+v", transport.msgs)
+	}
+}
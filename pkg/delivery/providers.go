@@ -0,0 +1,267 @@
+package delivery
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/pkg/mail"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"golang.org/x/time/rate"
+)
+
+// Provider is a mail provider jobs can be routed to. Empty Priorities,
+// Categories and Tenants match every job.
+type Provider struct {
+	Name       string
+	Transport  mail.Transport
+	Default    bool
+	Order      int      // Failover order, lowest first
+	RateLimit  int      // Emails per minute, 0 for unlimited
+	Priorities []int    // queue.PriorityLow, PriorityNormal, PriorityHigh
+	Categories []string // Template categories
+	Tenants    []string
+	limiter    *rate.Limiter // Built from RateLimit by NewRouter
+}
+
+// Route describes the job attributes providers are matched on.
+type Route struct {
+	Priority int
+	Category string
+	Tenant   string
+}
+
+// matches reports whether the provider accepts the route, and whether it
+// did so through an explicit rule rather than as a catch-all.
+func (p *Provider) matches(r Route) (ok, specific bool) {
+	if len(p.Priorities) > 0 {
+		if !slices.Contains(p.Priorities, r.Priority) {
+			return false, false
+		}
+		specific = true
+	}
+	if len(p.Categories) > 0 {
+		if !containsFold(p.Categories, r.Category) {
+			return false, false
+		}
+		specific = true
+	}
+	if len(p.Tenants) > 0 {
+		if !containsFold(p.Tenants, r.Tenant) {
+			return false, false
+		}
+		specific = true
+	}
+	return true, specific
+}
+
+// Router picks the providers for a job, in the order they should be tried.
+type Router struct {
+	providers []*Provider
+}
+
+// NewRouter creates a router over providers and their rate limiters.
+func NewRouter(providers []*Provider) *Router {
+	for _, p := range providers {
+		if p.RateLimit > 0 {
+			p.limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(p.RateLimit)), 1)
+		}
+	}
+	return &Router{providers: providers}
+}
+
+// Candidates returns the providers matching r: providers whose rules match
+// come first, then catch-all providers with the default first, each group
+// in failover order.
+func (r *Router) Candidates(route Route) []*Provider {
+	var specific, general []*Provider
+	for _, p := range r.providers {
+		ok, explicit := p.matches(route)
+		switch {
+		case !ok:
+		case explicit:
+			specific = append(specific, p)
+		default:
+			general = append(general, p)
+		}
+	}
+
+	byOrder := func(list []*Provider) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Default != list[j].Default {
+				return list[i].Default
+			}
+			return list[i].Order < list[j].Order
+		})
+	}
+	byOrder(specific)
+	byOrder(general)
+
+	return append(specific, general...)
+}
+
+// Send delivers msg through the first candidate provider that accepts it.
+// Transient and throttling failures fail over to the next provider;
+// permanent failures are returned immediately, as is a *mail.RecipientError
+// for a message that reached some of its recipients, so none of them is
+// sent a second copy. It returns the name of the provider used and the
+// message ID it reported, if any.
+func (r *Router) Send(ctx context.Context, route Route, msg mail.Message) (provider, messageID string, err error) {
+	candidates := r.Candidates(route)
+	if len(candidates) == 0 {
+		return "", "", fmt.Errorf("no mail provider for priority %s, category %q, tenant %q",
+			queue.PriorityName(route.Priority), route.Category, route.Tenant)
+	}
+
+	var errs []error
+	for _, p := range candidates {
+		if p.limiter != nil {
+			if err := p.limiter.Wait(ctx); err != nil {
+				return "", "", err
+			}
+		}
+
+		id, err := mail.SendID(ctx, p.Transport, msg)
+		if err == nil {
+			return p.Name, id, nil
+		}
+		var partial *mail.RecipientError
+		if errors.As(err, &partial) && len(partial.Sent) > 0 {
+			return p.Name, id, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		if mail.Classify(err) == mail.Permanent || ctx.Err() != nil {
+			break
+		}
+	}
+	return "", "", errors.Join(errs...)
+}
+
+// Close closes the transports that hold connections open.
+func (r *Router) Close() {
+	for _, p := range r.providers {
+		if c, ok := p.Transport.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// LoadProviders reads the smtp_providers table. Providers send through SMTP
+// with the host, TLS settings, credentials and sender stored in their row,
+// signing with dkim when it is not nil.
+func LoadProviders(ctx context.Context, db *sql.DB, dkim *mail.DKIMSigner) ([]*Provider, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, host, port, username, password, from_email, from_name,
+		       is_default, rate_limit, sort_order, priorities, categories, tenants,
+		       tls_mode, auth, ca_file, insecure_skip_verify,
+		       oauth_client_id, oauth_client_secret, oauth_refresh_token, oauth_token_url
+		FROM smtp_providers
+		WHERE enabled = 1
+		ORDER BY sort_order, name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query providers: %w", err)
+	}
+	defer rows.Close()
+
+	var providers []*Provider
+	for rows.Next() {
+		var p Provider
+		var host, fromEmail string
+		var port int
+		var username, password, fromName, priorities, categories, tenants, tlsMode, auth sql.NullString
+		var caFile, oauthClientID, oauthClientSecret, oauthRefreshToken, oauthTokenURL sql.NullString
+		var rateLimit sql.NullInt64
+		var insecureSkipVerify sql.NullBool
+		if err := rows.Scan(&p.Name, &host, &port, &username, &password, &fromEmail, &fromName,
+			&p.Default, &rateLimit, &p.Order, &priorities, &categories, &tenants, &tlsMode, &auth,
+			&caFile, &insecureSkipVerify, &oauthClientID, &oauthClientSecret, &oauthRefreshToken, &oauthTokenURL); err != nil {
+			return nil, fmt.Errorf("scan provider: %w", err)
+		}
+
+		p.RateLimit = int(rateLimit.Int64)
+		p.Categories = splitList(categories.String)
+		p.Tenants = splitList(tenants.String)
+		for _, name := range splitList(priorities.String) {
+			priority, err := queue.ParsePriority(name)
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: %w", p.Name, err)
+			}
+			p.Priorities = append(p.Priorities, priority)
+		}
+
+		p.Transport = mail.NewSMTPTransport(mail.Config{
+			SMTPHost:           host,
+			SMTPPort:           strconv.Itoa(port),
+			Username:           username.String,
+			Password:           password.String,
+			FromEmail:          fromEmail,
+			FromName:           fromName.String,
+			TLSMode:            tlsMode.String,
+			CAFile:             caFile.String,
+			InsecureSkipVerify: insecureSkipVerify.Bool,
+			Auth:               auth.String,
+			OAuthClientID:      oauthClientID.String,
+			OAuthClientSecret:  oauthClientSecret.String,
+			OAuthRefreshToken:  oauthRefreshToken.String,
+			OAuthTokenURL:      oauthTokenURL.String,
+			DKIM:               dkim,
+		})
+		providers = append(providers, &p)
+	}
+
+	return providers, rows.Err()
+}
+
+// LoadDKIMKeys reads the enabled keys in the dkim_keys table.
+func LoadDKIMKeys(ctx context.Context, db *sql.DB) ([]*mail.DKIMKey, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT domain, selector, private_key FROM dkim_keys WHERE enabled = 1
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query dkim keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*mail.DKIMKey
+	for rows.Next() {
+		var domain, selector, privateKey string
+		if err := rows.Scan(&domain, &selector, &privateKey); err != nil {
+			return nil, fmt.Errorf("scan dkim key: %w", err)
+		}
+		key, err := mail.ParseDKIMKey(domain, selector, []byte(privateKey))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// splitList splits a comma-separated column value.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, v) {
+			return true
+		}
+	}
+	return false
+}
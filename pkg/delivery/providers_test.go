@@ -0,0 +1,220 @@
+package delivery
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/mail"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+// fakeTransport fails with err, if set, and counts its sends.
+type fakeTransport struct {
+	err   error
+	sends int
+}
+
+func (f *fakeTransport) Send(ctx context.Context, msg mail.Message) error {
+	f.sends++
+	return f.err
+}
+
+func names(providers []*Provider) []string {
+	out := make([]string, len(providers))
+	for i, p := range providers {
+		out[i] = p.Name
+	}
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	router := NewRouter([]*Provider{
+		{Name: "backup", Order: 2},
+		{Name: "primary", Order: 5, Default: true},
+		{Name: "bulk", Order: 1, Priorities: []int{queue.PriorityLow}, Categories: []string{"marketing"}},
+		{Name: "acme", Tenants: []string{"acme"}},
+	})
+
+	tests := []struct {
+		route Route
+		want  []string
+	}{
+		{Route{Priority: queue.PriorityNormal}, []string{"primary", "backup"}},
+		{Route{Priority: queue.PriorityLow, Category: "Marketing"}, []string{"bulk", "primary", "backup"}},
+		{Route{Priority: queue.PriorityHigh, Category: "marketing"}, []string{"primary", "backup"}},
+		{Route{Priority: queue.PriorityNormal, Tenant: "acme"}, []string{"acme", "primary", "backup"}},
+	}
+	for _, tt := range tests {
+		got := names(router.Candidates(tt.route))
+		if len(got) != len(tt.want) {
+			t.Errorf("Candidates(%+v) = %v, want %v", tt.route, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Candidates(%+v) = %v, want %v", tt.route, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRouterFailover(t *testing.T) {
+	down := &fakeTransport{err: errors.New("dial tcp: connection refused")}
+	up := &fakeTransport{}
+	router := NewRouter([]*Provider{
+		{Name: "gmail", Transport: down, Default: true},
+		{Name: "ses", Transport: up, Order: 1},
+	})
+
+	name, _, err := router.Send(context.Background(), Route{}, mail.Message{To: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if name != "ses" || down.sends != 1 || up.sends != 1 {
+		t.Errorf("Expected failover to ses, got %q (sends %d, %d)", name, down.sends, up.sends)
+	}
+}
+
+func TestRouterPermanentFailure(t *testing.T) {
+	rejected := &fakeTransport{err: &mail.SMTPError{Code: 550, Enhanced: "5.1.1", Message: "No such user"}}
+	next := &fakeTransport{}
+	router := NewRouter([]*Provider{
+		{Name: "gmail", Transport: rejected},
+		{Name: "ses", Transport: next, Order: 1},
+	})
+
+	if _, _, err := router.Send(context.Background(), Route{}, mail.Message{}); err == nil {
+		t.Fatal("Expected permanent failure to be returned")
+	}
+	if next.sends != 0 {
+		t.Error("Permanent failures should not fail over")
+	}
+}
+
+func TestRouterFailsOverWhenThrottled(t *testing.T) {
+	throttled := &fakeTransport{err: &mail.SMTPError{Code: 421, Enhanced: "4.7.0", Message: "Try again later, closing connection (550 limit)"}}
+	next := &fakeTransport{}
+	router := NewRouter([]*Provider{
+		{Name: "gmail", Transport: throttled},
+		{Name: "ses", Transport: next, Order: 1},
+	})
+
+	if name, _, err := router.Send(context.Background(), Route{}, mail.Message{}); err != nil || name != "ses" {
+		t.Errorf("Expected throttled provider to fail over to ses, got %q, %v", name, err)
+	}
+}
+
+func TestRouterDoesNotFailOverPartialDelivery(t *testing.T) {
+	partial := &fakeTransport{err: &mail.RecipientError{
+		Sent:   []string{"a@example.com"},
+		Failed: map[string]error{"b@example.com": errors.New("dial tcp: i/o timeout")},
+	}}
+	next := &fakeTransport{}
+	router := NewRouter([]*Provider{
+		{Name: "gmail", Transport: partial},
+		{Name: "ses", Transport: next, Order: 1},
+	})
+
+	name, _, err := router.Send(context.Background(), Route{}, mail.Message{})
+	var recipientErr *mail.RecipientError
+	if name != "gmail" || !errors.As(err, &recipientErr) || next.sends != 0 {
+		t.Errorf("Expected the partial delivery through gmail to be returned, got %q, %v after %d failovers", name, err, next.sends)
+	}
+}
+
+func TestJobClass(t *testing.T) {
+	permanent := &mail.SMTPError{Code: 550, Enhanced: "5.1.1"}
+	throttled := &mail.SMTPError{Code: 450, Enhanced: "4.2.1"}
+	transient := errors.New("dial tcp: i/o timeout")
+
+	tests := []struct {
+		errs []error
+		want mail.Class
+	}{
+		{[]error{permanent, permanent}, mail.Permanent},
+		{[]error{permanent, transient}, mail.Transient},
+		{[]error{permanent, throttled, transient}, mail.Throttled},
+		{[]error{errors.New("send to a@example.com: 554 in message id")}, mail.Transient},
+	}
+	for _, tt := range tests {
+		if got := jobClass(tt.errs); got != tt.want {
+			t.Errorf("jobClass(%v) = %s, want %s", tt.errs, got, tt.want)
+		}
+	}
+}
+
+func TestLoadProviders(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	_, err = database.Exec(`
+		INSERT INTO smtp_providers (id, name, host, port, from_email, is_default, rate_limit, sort_order, priorities, categories, enabled,
+		                            ca_file, insecure_skip_verify, auth, oauth_client_id, oauth_client_secret, oauth_refresh_token)
+		VALUES ('1', 'bulk', 'smtp.bulk.example', 587, 'news@example.com', 0, 600, 2, 'low', 'marketing, digest', 1,
+		        '/etc/ssl/internal-ca.pem', 1, NULL, NULL, NULL, NULL),
+		       ('2', 'gmail', 'smtp.gmail.com', 587, 'noreply@example.com', 1, NULL, 1, NULL, NULL, 1,
+		        NULL, NULL, 'xoauth2', 'client', 'secret', 'refresh'),
+		       ('3', 'old', 'smtp.old.example', 25, 'noreply@example.com', 0, NULL, 0, NULL, NULL, 0,
+		        NULL, NULL, NULL, NULL, NULL, NULL)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert providers: %v", err)
+	}
+
+	providers, err := LoadProviders(context.Background(), database.DB, nil)
+	if err != nil {
+		t.Fatalf("LoadProviders failed: %v", err)
+	}
+	if got := names(providers); len(got) != 2 || got[0] != "gmail" || got[1] != "bulk" {
+		t.Fatalf("Expected enabled providers in sort order, got %v", got)
+	}
+
+	bulk := providers[1]
+	if bulk.RateLimit != 600 || len(bulk.Categories) != 2 || bulk.Categories[1] != "digest" {
+		t.Errorf("Unexpected bulk provider %+v", bulk)
+	}
+	if len(bulk.Priorities) != 1 || bulk.Priorities[0] != queue.PriorityLow {
+		t.Errorf("Expected low priority, got %v", bulk.Priorities)
+	}
+	if !providers[0].Default {
+		t.Error("Expected gmail to be the default")
+	}
+}
+
+func TestLoadDKIMKeys(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	_, err = database.Exec(`
+		INSERT INTO dkim_keys (domain, selector, private_key, enabled)
+		VALUES ('example.com', 'mail', ?, 1), ('old.example', 'mail', ?, 0)
+	`, string(pemKey), string(pemKey))
+	if err != nil {
+		t.Fatalf("Failed to insert keys: %v", err)
+	}
+
+	keys, err := LoadDKIMKeys(context.Background(), database.DB)
+	if err != nil {
+		t.Fatalf("LoadDKIMKeys failed: %v", err)
+	}
+	if len(keys) != 1 || keys[0].Domain != "example.com" || keys[0].Selector != "mail" {
+		t.Errorf("Expected the enabled example.com key, got %+v", keys)
+	}
+}
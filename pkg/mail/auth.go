@@ -0,0 +1,236 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLS modes for Config.TLSMode.
+const (
+	TLSOpportunistic = "opportunistic" // STARTTLS when the server offers it
+	TLSStartTLS      = "starttls"      // STARTTLS, failing if not offered
+	TLSImplicit      = "tls"           // TLS from the start, usually port 465
+	TLSNone          = "none"          // Plain text, for local relays
+)
+
+// Authentication mechanisms for Config.Auth.
+const (
+	AuthPlain   = "plain"
+	AuthLogin   = "login"
+	AuthCRAMMD5 = "cram-md5"
+	AuthXOAUTH2 = "xoauth2"
+	AuthNone    = "none"
+)
+
+// googleTokenURL is the default OAuth2 token endpoint for XOAUTH2.
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// tlsMode returns the configured TLS mode, defaulting to implicit TLS on
+// port 465 and opportunistic STARTTLS elsewhere.
+func tlsMode(config Config) (string, error) {
+	switch mode := strings.ToLower(config.TLSMode); mode {
+	case "":
+		if config.SMTPPort == "465" {
+			return TLSImplicit, nil
+		}
+		return TLSOpportunistic, nil
+	case TLSOpportunistic, TLSStartTLS, TLSImplicit, TLSNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown TLS mode %q", config.TLSMode)
+	}
+}
+
+// tlsConfig returns the TLS settings for connecting to the server in config.
+func tlsConfig(config Config) (*tls.Config, error) {
+	tc := &tls.Config{
+		ServerName:         config.SMTPHost,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", config.CAFile)
+		}
+		tc.RootCAs = roots
+	}
+	return tc, nil
+}
+
+// authenticator returns the smtp.Auth for config, or nil when no
+// authentication is configured. XOAUTH2 fetches an access token first.
+func (p *Pool) authenticator(ctx context.Context) (smtp.Auth, error) {
+	config := p.config
+	mechanism := strings.ToLower(config.Auth)
+	if mechanism == AuthNone || (mechanism == "" && config.Username == "") {
+		return nil, nil
+	}
+
+	switch mechanism {
+	case "", AuthPlain:
+		return smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost), nil
+	case AuthLogin:
+		return &loginAuth{username: config.Username, password: config.Password, host: config.SMTPHost}, nil
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(config.Username, config.Password), nil
+	case AuthXOAUTH2:
+		token, err := p.tokens.token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 token: %w", err)
+		}
+		return &xoauth2Auth{username: config.Username, token: token, host: config.SMTPHost}, nil
+	default:
+		return nil, fmt.Errorf("unknown auth mechanism %q", config.Auth)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism, which some relays and
+// Microsoft servers offer instead of PLAIN.
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkServer(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+// xoauth2Auth implements Google and Microsoft's XOAUTH2 mechanism.
+type xoauth2Auth struct {
+	username, token, host string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkServer(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next answers the error challenge sent on failure with an empty response,
+// after which the server reports the failure.
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// checkServer refuses to send credentials in plain text, except to
+// localhost, or to a server other than the one configured, as
+// smtp.PlainAuth does.
+func checkServer(server *smtp.ServerInfo, host string) error {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return errors.New("unencrypted connection")
+	}
+	if server.Name != host {
+		return errors.New("wrong host name")
+	}
+	return nil
+}
+
+// tokenSource provides XOAUTH2 access tokens. With a refresh token it
+// exchanges it for access tokens, cached until shortly before they expire;
+// otherwise the configured password is the access token.
+type tokenSource struct {
+	config Config
+	client *http.Client
+
+	mu     sync.Mutex
+	access string
+	expiry time.Time
+}
+
+func newTokenSource(config Config) *tokenSource {
+	return &tokenSource{config: config, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func (s *tokenSource) token(ctx context.Context) (string, error) {
+	if s.config.OAuthRefreshToken == "" {
+		if s.config.Password == "" {
+			return "", errors.New("xoauth2 requires a refresh token or an access token as the password")
+		}
+		return s.config.Password, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.access != "" && time.Now().Before(s.expiry) {
+		return s.access, nil
+	}
+
+	endpoint := s.config.OAuthTokenURL
+	if endpoint == "" {
+		endpoint = googleTokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {s.config.OAuthRefreshToken},
+		"client_id":     {s.config.OAuthClientID},
+		"client_secret": {s.config.OAuthClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("decode token response: %w", err)
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("token response has no access_token")
+	}
+
+	// Refresh a minute early so tokens do not expire mid-handshake
+	s.access = result.AccessToken
+	s.expiry = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return s.access, nil
+}
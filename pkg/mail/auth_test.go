@@ -0,0 +1,184 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testTLS returns a server TLS config for 127.0.0.1 and a CA file that
+// trusts it.
+func testTLS(t *testing.T) (*tls.Config, string) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemData, 0o600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	return &tls.Config{Certificates: srv.TLS.Certificates}, caFile
+}
+
+func TestPoolTLSModes(t *testing.T) {
+	serverTLS, caFile := testTLS(t)
+
+	tests := []struct {
+		name     string
+		implicit bool   // Server requires TLS from the start
+		offer    bool   // Server offers STARTTLS
+		mode     string // Client TLS mode
+		insecure bool
+		secure   bool // Expect the delivery over TLS
+		wantErr  string
+	}{
+		{name: "implicit", implicit: true, mode: TLSImplicit, secure: true},
+		{name: "opportunistic", offer: true, secure: true},
+		{name: "opportunistic without offer"},
+		{name: "required", offer: true, mode: TLSStartTLS, secure: true},
+		{name: "required without offer", mode: TLSStartTLS, wantErr: "does not offer STARTTLS"},
+		{name: "none", offer: true, mode: TLSNone},
+		{name: "untrusted", offer: true, mode: TLSStartTLS, wantErr: "certificate"},
+		{name: "insecure", offer: true, mode: TLSStartTLS, insecure: true, secure: true},
+		{name: "unknown mode", mode: "ssl", wantErr: "unknown TLS mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSMTPServer(t, func(s *smtpServer) {
+				if tt.implicit || tt.offer {
+					s.tls = serverTLS
+				}
+				s.implicit = tt.implicit
+			})
+
+			config := s.config()
+			config.TLSMode = tt.mode
+			config.InsecureSkipVerify = tt.insecure
+			if tt.name != "untrusted" && !tt.insecure {
+				config.CAFile = caFile
+			}
+			pool := NewPool(config)
+			defer pool.Close()
+
+			err := pool.Send(context.Background(), poolMessage("a@example.com"))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if len(s.secure) != 1 || s.secure[0] != tt.secure {
+				t.Errorf("Expected secure=%v, got %v", tt.secure, s.secure)
+			}
+		})
+	}
+}
+
+func TestTLSModeDefault(t *testing.T) {
+	if mode, _ := tlsMode(Config{SMTPPort: "465"}); mode != TLSImplicit {
+		t.Errorf("Expected implicit TLS on port 465, got %q", mode)
+	}
+	if mode, _ := tlsMode(Config{SMTPPort: "587"}); mode != TLSOpportunistic {
+		t.Errorf("Expected opportunistic STARTTLS on port 587, got %q", mode)
+	}
+}
+
+func TestPoolAuth(t *testing.T) {
+	var refreshes int
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != "refresh" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.token","expires_in":3600}`))
+	}))
+	defer tokens.Close()
+
+	tests := []struct {
+		auth   string
+		offer  string
+		oauth  bool
+		want   string
+		errMsg string
+	}{
+		{auth: "", offer: "PLAIN", want: "PLAIN \x00ada\x00secret"},
+		{auth: AuthLogin, offer: "LOGIN", want: "LOGIN ada secret"},
+		{auth: AuthCRAMMD5, offer: "CRAM-MD5", want: "CRAM-MD5 ada "},
+		{auth: AuthXOAUTH2, offer: "XOAUTH2", want: "XOAUTH2 user=ada\x01auth=Bearer secret\x01\x01"},
+		{auth: AuthXOAUTH2, offer: "XOAUTH2", oauth: true, want: "XOAUTH2 user=ada\x01auth=Bearer ya29.token\x01\x01"},
+		{auth: AuthPlain, errMsg: "doesn't support AUTH"},
+		{auth: "ntlm", offer: "NTLM", errMsg: "unknown auth mechanism"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.auth+tt.offer, func(t *testing.T) {
+			s := newSMTPServer(t, func(s *smtpServer) { s.auth = tt.offer })
+
+			config := s.config()
+			config.Username = "ada"
+			config.Password = "secret"
+			config.Auth = tt.auth
+			if tt.oauth {
+				config.OAuthRefreshToken = "refresh"
+				config.OAuthTokenURL = tokens.URL
+			}
+			pool := NewPool(config)
+			defer pool.Close()
+
+			err := pool.Send(context.Background(), poolMessage("a@example.com"))
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if len(s.logins) != 1 || !strings.HasPrefix(s.logins[0], tt.want) {
+				t.Errorf("Expected AUTH %q, got %q", tt.want, s.logins)
+			}
+		})
+	}
+
+	// Access tokens are cached until they expire
+	ts := newTokenSource(Config{OAuthRefreshToken: "refresh", OAuthTokenURL: tokens.URL})
+	before := refreshes
+	for i := 0; i < 3; i++ {
+		if _, err := ts.token(context.Background()); err != nil {
+			t.Fatalf("token failed: %v", err)
+		}
+	}
+	if refreshes-before != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", refreshes-before)
+	}
+}
+
+func TestAuthRefusesPlainText(t *testing.T) {
+	a := &loginAuth{username: "ada", password: "secret", host: "smtp.example.com"}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com"}); err == nil {
+		t.Error("LOGIN should not send credentials over an unencrypted connection")
+	}
+	x := &xoauth2Auth{username: "ada", token: "t", host: "smtp.example.com"}
+	if _, _, err := x.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true}); err != nil {
+		t.Errorf("XOAUTH2 over TLS failed: %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package mail
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	netmail "net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+// dkimHeaders are the headers signed when present, in signing order.
+var dkimHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"MIME-Version", "Content-Type", "Content-Transfer-Encoding",
+	"List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMKey is the private key and selector used to sign mail from one domain.
+type DKIMKey struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer // *rsa.PrivateKey or ed25519.PrivateKey
+}
+
+// ParseDKIMKey parses a PEM-encoded RSA (PKCS #1 or #8) or Ed25519
+// (PKCS #8) private key.
+func ParseDKIMKey(domain, selector string, pemData []byte) (*DKIMKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("dkim key for %s: no PEM data", domain)
+	}
+
+	var key any
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dkim key for %s: %w", domain, err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &DKIMKey{Domain: strings.ToLower(domain), Selector: selector, Signer: k}, nil
+	case ed25519.PrivateKey:
+		return &DKIMKey{Domain: strings.ToLower(domain), Selector: selector, Signer: k}, nil
+	default:
+		return nil, fmt.Errorf("dkim key for %s: unsupported key type %T", domain, key)
+	}
+}
+
+// LoadDKIMKey reads a private key file for ParseDKIMKey.
+func LoadDKIMKey(domain, selector, path string) (*DKIMKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read dkim key: %w", err)
+	}
+	return ParseDKIMKey(domain, selector, data)
+}
+
+// algorithm returns the DKIM a= tag for the key.
+func (k *DKIMKey) algorithm() string {
+	if _, ok := k.Signer.(ed25519.PrivateKey); ok {
+		return "ed25519-sha256"
+	}
+	return "rsa-sha256"
+}
+
+// DKIMRecord returns the DNS TXT record value publishing the key's public
+// half, to be set at <selector>._domainkey.<domain>.
+func (k *DKIMKey) DKIMRecord() (string, error) {
+	switch pub := k.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der), nil
+	case ed25519.PublicKey:
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub), nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// buildSigned builds msg and signs it when config has a DKIM signer.
+func buildSigned(config Config, msg Message) ([]byte, error) {
+	raw, err := Build(config, msg)
+	if err != nil {
+		return nil, err
+	}
+	return config.DKIM.Sign(raw)
+}
+
+// DKIMSigner signs messages with the key for their From domain.
+type DKIMSigner struct {
+	keys map[string]*DKIMKey
+}
+
+// NewDKIMSigner creates a signer for keys. A later key for the same domain
+// replaces an earlier one.
+func NewDKIMSigner(keys ...*DKIMKey) *DKIMSigner {
+	s := &DKIMSigner{keys: make(map[string]*DKIMKey, len(keys))}
+	for _, k := range keys {
+		s.keys[strings.ToLower(k.Domain)] = k
+	}
+	return s
+}
+
+// Sign prepends a DKIM-Signature header to an RFC 5322 message, using
+// relaxed/relaxed canonicalization. Messages from domains without a key
+// are returned unchanged.
+func (s *DKIMSigner) Sign(raw []byte) ([]byte, error) {
+	if s == nil || len(s.keys) == 0 {
+		return raw, nil
+	}
+
+	header, body, ok := bytes.Cut(raw, []byte("\r\n\r\n"))
+	if !ok {
+		return nil, fmt.Errorf("dkim: message has no body separator")
+	}
+	fields := headerFields(header)
+
+	key := s.keys[senderDomain(fields)]
+	if key == nil {
+		return raw, nil
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	var signed []string
+	var data bytes.Buffer
+	for _, name := range dkimHeaders {
+		if field, ok := lastField(fields, name); ok {
+			signed = append(signed, strings.ToLower(name))
+			data.WriteString(relaxedHeader(field))
+			data.WriteString("\r\n")
+		}
+	}
+
+	sig := fmt.Sprintf("DKIM-Signature: v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s;\r\n\tt=%d; h=%s;\r\n\tbh=%s;\r\n\tb=",
+		key.algorithm(), strings.ToLower(key.Domain), key.Selector, time.Now().Unix(),
+		strings.Join(signed, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+	data.WriteString(relaxedHeader(sig))
+
+	digest := sha256.Sum256(data.Bytes())
+	opts := crypto.Hash(0) // Ed25519 signs the SHA-256 digest itself (RFC 8463)
+	if key.algorithm() == "rsa-sha256" {
+		opts = crypto.SHA256
+	}
+	signature, err := key.Signer.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		return nil, fmt.Errorf("dkim sign: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.WriteString(sig)
+	out.WriteString(foldBase64(base64.StdEncoding.EncodeToString(signature)))
+	out.WriteString("\r\n")
+	out.Write(raw)
+	return out.Bytes(), nil
+}
+
+// headerFields splits a header block into fields, keeping continuation
+// lines with their field.
+func headerFields(header []byte) []string {
+	var fields []string
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += "\r\n" + line
+			continue
+		}
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// lastField returns the bottom-most field with the given name.
+func lastField(fields []string, name string) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if n, _, ok := strings.Cut(fields[i], ":"); ok && strings.EqualFold(strings.TrimSpace(n), name) {
+			return fields[i], true
+		}
+	}
+	return "", false
+}
+
+// senderDomain returns the lowercase domain of the From address.
+func senderDomain(fields []string) string {
+	from, ok := lastField(fields, "From")
+	if !ok {
+		return ""
+	}
+	_, value, _ := strings.Cut(from, ":")
+	addr, err := netmail.ParseAddress(strings.TrimSpace(strings.ReplaceAll(value, "\r\n", "")))
+	if err != nil {
+		return ""
+	}
+	_, domain, _ := strings.Cut(addr.Address, "@")
+	return strings.ToLower(domain)
+}
+
+// relaxedHeader canonicalizes a header field (RFC 6376 section 3.4.2):
+// lowercase name, unfolded, runs of whitespace reduced to one space and
+// whitespace around the colon and at the end removed.
+func relaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Trim(collapseWSP(value), " ")
+}
+
+// relaxedBody canonicalizes a body (RFC 6376 section 3.4.4): runs of
+// whitespace reduced to one space, trailing whitespace and empty lines at
+// the end removed.
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(collapseWSP(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+// collapseWSP replaces each run of spaces and tabs with a single space.
+func collapseWSP(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(s[i])
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// foldBase64 wraps a signature over several header lines.
+func foldBase64(s string) string {
+	var parts []string
+	for len(s) > 72 {
+		parts = append(parts, s[:72])
+		s = s[72:]
+	}
+	parts = append(parts, s)
+	return strings.Join(parts, "\r\n\t")
+}
@@ -0,0 +1,180 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRelaxedCanonicalization(t *testing.T) {
+	// Example from RFC 6376 section 3.4.5
+	fields := headerFields([]byte("A: X\r\nB : Y\t\r\n\tZ  "))
+	var got []string
+	for _, f := range fields {
+		got = append(got, relaxedHeader(f))
+	}
+	if strings.Join(got, "\r\n") != "a:X\r\nb:Y Z" {
+		t.Errorf("Unexpected header canonicalization %q", got)
+	}
+
+	body := relaxedBody([]byte(" C \r\nD \t E\r\n\r\n\r\n"))
+	if string(body) != " C\r\nD E\r\n" {
+		t.Errorf("Unexpected body canonicalization %q", body)
+	}
+	if relaxedBody([]byte("\r\n\r\n")) != nil {
+		t.Error("Empty body should canonicalize to nothing")
+	}
+}
+
+// verifyDKIM checks the DKIM-Signature at the top of a message against pub.
+func verifyDKIM(signed []byte, pub crypto.PublicKey) error {
+	header, body, _ := bytes.Cut(signed, []byte("\r\n\r\n"))
+	fields := headerFields(header)
+	if !strings.HasPrefix(fields[0], "DKIM-Signature:") {
+		return errors.New("no DKIM-Signature header")
+	}
+
+	tags := map[string]string{}
+	_, value, _ := strings.Cut(strings.ReplaceAll(fields[0], "\r\n", ""), ":")
+	for _, tag := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[k] = strings.Join(strings.Fields(v), "")
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		return errors.New("body hash mismatch")
+	}
+
+	var data bytes.Buffer
+	for _, name := range strings.Split(tags["h"], ":") {
+		field, _ := lastField(fields[1:], name)
+		data.WriteString(relaxedHeader(field) + "\r\n")
+	}
+	unsigned := fields[0][:strings.Index(fields[0], "\tb=")+3]
+	data.WriteString(relaxedHeader(unsigned))
+	digest := sha256.Sum256(data.Bytes())
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, digest[:], sig) {
+			return errors.New("ed25519 signature mismatch")
+		}
+	}
+	return nil
+}
+
+func TestDKIMSign(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, tt := range []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"rsa", rsaKey, "a=rsa-sha256"},
+		{"ed25519", edKey, "a=ed25519-sha256"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig
+			config.DKIM = NewDKIMSigner(&DKIMKey{Domain: "Example.com", Selector: "mail", Signer: tt.key})
+
+			signed, err := buildSigned(config, testMessage())
+			if err != nil {
+				t.Fatalf("buildSigned failed: %v", err)
+			}
+			if !bytes.Contains(signed, []byte(tt.alg)) || !bytes.Contains(signed, []byte("d=example.com; s=mail;")) {
+				t.Errorf("Unexpected signature header:\n%s", signed[:400])
+			}
+			if err := verifyDKIM(signed, tt.key.Public()); err != nil {
+				t.Errorf("Signature does not verify: %v", err)
+			}
+
+			tampered := bytes.Replace(signed, []byte("Subject: Hello"), []byte("Subject: Hullo"), 1)
+			if verifyDKIM(tampered, tt.key.Public()) == nil {
+				t.Error("Tampered message should not verify")
+			}
+		})
+	}
+}
+
+func TestDKIMSignOtherDomain(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer := NewDKIMSigner(&DKIMKey{Domain: "other.example", Selector: "mail", Signer: key})
+
+	raw, _ := Build(testConfig, testMessage())
+	signed, err := signer.Sign(raw)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !bytes.Equal(raw, signed) {
+		t.Error("Messages from domains without a key should not be signed")
+	}
+}
+
+func TestLoadDKIMKey(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey)
+
+	files := map[string]*pem.Block{
+		"rsa.pem":     {Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)},
+		"ed25519.pem": {Type: "PRIVATE KEY", Bytes: pkcs8},
+	}
+	for name, block := range files {
+		os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600)
+	}
+
+	for name, want := range map[string]string{"rsa.pem": "k=rsa", "ed25519.pem": "k=ed25519"} {
+		key, err := LoadDKIMKey("example.com", "mail", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("LoadDKIMKey(%s) failed: %v", name, err)
+		}
+		record, err := key.DKIMRecord()
+		if err != nil || !strings.Contains(record, want) {
+			t.Errorf("Unexpected DNS record %q, %v", record, err)
+		}
+	}
+
+	if _, err := ParseDKIMKey("example.com", "mail", []byte("not a key")); err == nil {
+		t.Error("Expected error for invalid PEM")
+	}
+}
+
+func TestFileTransportSigns(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	config := testConfig
+	config.DKIM = NewDKIMSigner(&DKIMKey{Domain: "example.com", Selector: "mail", Signer: key})
+
+	dir := t.TempDir()
+	transport, _ := NewFileTransport(config, dir, FormatEML)
+	if err := transport.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	raw, _ := os.ReadFile(files[0])
+	if err := verifyDKIM(raw, key.Public()); err != nil {
+		t.Errorf("Written message does not verify: %v", err)
+	}
+}
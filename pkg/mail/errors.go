@@ -0,0 +1,202 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	netmail "net/mail"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Class is how a delivery failure should be handled.
+type Class int
+
+const (
+	// Transient failures, such as network errors and most 4xx replies,
+	// are retried later, through another provider if there is one.
+	Transient Class = iota
+	// Throttled failures mean the provider is rate limiting us. They are
+	// retried like transient failures but do not count as attempts.
+	Throttled
+	// Permanent failures, such as unknown recipients, are not retried.
+	Permanent
+)
+
+func (c Class) String() string {
+	switch c {
+	case Throttled:
+		return "throttled"
+	case Permanent:
+		return "permanent"
+	default:
+		return "transient"
+	}
+}
+
+// SMTPError is an error reply from an SMTP server.
+type SMTPError struct {
+	Code      int    // Reply code, e.g. 550
+	Enhanced  string // RFC 3463 enhanced status code, e.g. "5.1.1", when given
+	Message   string // Reply text after the enhanced status code
+	Recipient string // The address refused, for RCPT TO failures
+}
+
+func (e *SMTPError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "smtp %d", e.Code)
+	if e.Enhanced != "" {
+		b.WriteString(" " + e.Enhanced)
+	}
+	if e.Recipient != "" {
+		b.WriteString(" for " + e.Recipient)
+	}
+	b.WriteString(": " + e.Message)
+	return b.String()
+}
+
+// throttleCodes are enhanced status codes servers use for rate limiting
+// and congestion, as opposed to problems with the message or recipient.
+var throttleCodes = map[string]bool{
+	"4.2.1":  true, // Mailbox receiving mail too quickly
+	"4.3.2":  true, // System not accepting messages
+	"4.4.5":  true, // System congestion
+	"4.5.3":  true, // Too many recipients
+	"4.7.0":  true, // Try again later
+	"4.7.28": true, // Rate limited (Gmail)
+}
+
+// authCodes are replies to authentication problems, which are failures
+// of the provider configuration rather than of the message.
+var authCodes = map[int]bool{530: true, 534: true, 535: true, 538: true}
+
+// Class classifies the reply. The enhanced status code is used when
+// present, as its class digit is more reliable than the reply code.
+func (e *SMTPError) Class() Class {
+	class := e.Code / 100
+	if e.Enhanced != "" {
+		class = int(e.Enhanced[0] - '0')
+	}
+
+	switch {
+	case authCodes[e.Code]:
+		return Transient
+	case class == 5:
+		return Permanent
+	case e.Code == 421 || throttleCodes[e.Enhanced]:
+		return Throttled
+	default:
+		return Transient
+	}
+}
+
+// ProviderError is an error response from an email provider's HTTP API.
+type ProviderError struct {
+	Provider string
+	Status   int
+	Body     string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: %d %s: %s", e.Provider, e.Status, http.StatusText(e.Status), e.Body)
+}
+
+// Class classifies the response: 429 is throttling, other 4xx responses
+// reject the message except authentication failures, and 5xx are transient.
+func (e *ProviderError) Class() Class {
+	switch {
+	case e.Status == 429:
+		return Throttled
+	case e.Status == 401 || e.Status == 403:
+		return Transient
+	case e.Status >= 400 && e.Status < 500:
+		return Permanent
+	default:
+		return Transient
+	}
+}
+
+// RecipientError is returned when a message could not be delivered to
+// some of its recipients. Sent lists the addresses it was delivered to,
+// and Failed holds the error for each of the others, keyed by address.
+type RecipientError struct {
+	Sent   []string
+	Failed map[string]error
+}
+
+func (e *RecipientError) Error() string {
+	msgs := make([]string, 0, len(e.Failed))
+	for _, addr := range slices.Sorted(maps.Keys(e.Failed)) {
+		msg := e.Failed[addr].Error()
+		if !strings.Contains(msg, addr) {
+			msg = addr + ": " + msg
+		}
+		msgs = append(msgs, msg)
+	}
+	return fmt.Sprintf("%d of %d recipients failed: %s",
+		len(e.Failed), len(e.Failed)+len(e.Sent), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the error of each failed recipient, so Classify sees
+// them all.
+func (e *RecipientError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, addr := range slices.Sorted(maps.Keys(e.Failed)) {
+		errs = append(errs, e.Failed[addr])
+	}
+	return errs
+}
+
+// For returns the error for addr, which may include a display name, or nil
+// when the message was delivered to it.
+func (e *RecipientError) For(addr string) error {
+	if parsed, err := netmail.ParseAddress(addr); err == nil {
+		addr = parsed.Address
+	}
+	return e.Failed[addr]
+}
+
+// Classify returns how a delivery error should be handled. For errors
+// joined with errors.Join, such as failover attempts across providers, a
+// permanent failure from any of them wins, then throttling. Errors that
+// are neither SMTPError nor ProviderError are transient.
+func Classify(err error) Class {
+	switch e := err.(type) {
+	case nil:
+		return Transient
+	case *SMTPError:
+		return e.Class()
+	case *ProviderError:
+		return e.Class()
+	case interface{ Unwrap() []error }:
+		class := Transient
+		for _, inner := range e.Unwrap() {
+			class = max(class, Classify(inner))
+		}
+		return class
+	}
+	return Classify(errors.Unwrap(err))
+}
+
+var enhancedCode = regexp.MustCompile(`^([245]\.\d{1,3}\.\d{1,3})\s*`)
+
+// replyError converts an error reply from net/smtp into an *SMTPError for
+// recipient, which may be empty. Other errors are returned unchanged.
+func replyError(err error, recipient string) error {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return err
+	}
+
+	e := &SMTPError{Code: reply.Code, Message: reply.Msg, Recipient: recipient}
+	if m := enhancedCode.FindStringSubmatch(reply.Msg); m != nil {
+		e.Enhanced = m[1]
+		e.Message = reply.Msg[len(m[0]):]
+		// Multi-line replies repeat the code on each line
+		e.Message = strings.ReplaceAll(e.Message, "\n"+e.Enhanced+" ", "\n")
+	}
+	return e
+}
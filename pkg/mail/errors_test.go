@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+)
+
+func TestReplyError(t *testing.T) {
+	err := replyError(&textproto.Error{
+		Code: 550,
+		Msg:  "5.1.1 The email account that you tried to reach does not exist.\n5.1.1 Please try double-checking the address.",
+	}, "nobody@example.com")
+
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("Expected *SMTPError, got %T", err)
+	}
+	if smtpErr.Code != 550 || smtpErr.Enhanced != "5.1.1" || smtpErr.Recipient != "nobody@example.com" {
+		t.Errorf("Unexpected error %+v", smtpErr)
+	}
+	want := "The email account that you tried to reach does not exist.\nPlease try double-checking the address."
+	if smtpErr.Message != want {
+		t.Errorf("Expected message %q, got %q", want, smtpErr.Message)
+	}
+
+	plain := errors.New("EOF")
+	if replyError(plain, "") != plain || replyError(nil, "") != nil {
+		t.Error("Non-reply errors should be returned unchanged")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"unknown user", &SMTPError{Code: 550, Enhanced: "5.1.1"}, Permanent},
+		{"no enhanced code", &SMTPError{Code: 554}, Permanent},
+		{"service closing", &SMTPError{Code: 421, Enhanced: "4.7.0"}, Throttled},
+		{"receiving too quickly", &SMTPError{Code: 450, Enhanced: "4.2.1"}, Throttled},
+		{"greylisted", &SMTPError{Code: 451, Enhanced: "4.7.1"}, Transient},
+		{"too many recipients", &SMTPError{Code: 452, Enhanced: "4.5.3"}, Throttled},
+		{"enhanced code wins", &SMTPError{Code: 552, Enhanced: "4.2.2"}, Transient},
+		{"bad credentials", &SMTPError{Code: 535, Enhanced: "5.7.8"}, Transient},
+		{"digits in text", errors.New("dial tcp 10.0.0.5:550: connection refused"), Transient},
+		{"wrapped", fmt.Errorf("send to a@example.com: %w", &SMTPError{Code: 553}), Permanent},
+		{"provider throttled", &ProviderError{Provider: "sendgrid", Status: 429}, Throttled},
+		{"provider rejected", &ProviderError{Provider: "postmark", Status: 422}, Permanent},
+		{"provider down", &ProviderError{Provider: "ses", Status: 503}, Transient},
+		{"provider auth", &ProviderError{Provider: "ses", Status: 403}, Transient},
+		{"failover", errors.Join(&SMTPError{Code: 421}, &SMTPError{Code: 550}), Permanent},
+		{"failover throttled", errors.Join(errors.New("EOF"), &SMTPError{Code: 421}), Throttled},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify(%v) = %s, want %s", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File sink formats.
+const (
+	FormatEML  = "eml"
+	FormatMbox = "mbox"
+)
+
+// FileTransport writes messages to disk instead of sending them, for
+// development and CI. In eml format each message is a separate .eml file
+// in the directory; in mbox format messages are appended to one mbox file.
+type FileTransport struct {
+	config Config
+	path   string
+	format string
+	mu     sync.Mutex // Serialises mbox appends
+}
+
+// NewFileTransport creates a file sink at path, creating the directory if needed.
+func NewFileTransport(config Config, path, format string) (*FileTransport, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file transport requires a path")
+	}
+	if format == "" {
+		format = FormatEML
+	}
+
+	dir := path
+	switch format {
+	case FormatEML:
+	case FormatMbox:
+		dir = filepath.Dir(path)
+	default:
+		return nil, fmt.Errorf("unknown file transport format %q", format)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create mail directory: %w", err)
+	}
+
+	return &FileTransport{config: config, path: path, format: format}, nil
+}
+
+// Send writes msg to the sink.
+func (t *FileTransport) Send(ctx context.Context, msg Message) error {
+	_, err := t.SendID(ctx, msg)
+	return err
+}
+
+// SendID writes msg to the sink and returns its Message-ID.
+func (t *FileTransport) SendID(ctx context.Context, msg Message) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	raw, err := buildSigned(t.config, msg)
+	if err != nil {
+		return "", fmt.Errorf("build message: %w", err)
+	}
+
+	if t.format == FormatMbox {
+		err = t.appendMbox(raw)
+	} else {
+		err = t.writeEML(raw)
+	}
+	if err != nil {
+		return "", err
+	}
+	return messageID(raw), nil
+}
+
+// writeEML writes the message to a uniquely named file. It is written to a
+// temporary name first so readers never see a partial message.
+func (t *FileTransport) writeEML(raw []byte) error {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	path := filepath.Join(t.path, name)
+	if err := os.WriteFile(path+".tmp", raw, 0644); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// appendMbox appends the message in mboxrd format.
+func (t *FileTransport) appendMbox(raw []byte) error {
+	var buf bytes.Buffer
+	sender := t.config.FromEmail
+	if sender == "" {
+		sender = "MAILER-DAEMON"
+	}
+	fmt.Fprintf(&buf, "From %s %s\n", sender, time.Now().UTC().Format(time.ANSIC))
+	for _, line := range bytes.Split(bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n")), []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From ")) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open mbox: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("append to mbox: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,350 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	netmail "net/mail"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Default provider API endpoints.
+const (
+	sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+	postmarkEndpoint = "https://api.postmarkapp.com/email"
+	sesEndpoint      = "https://email.%s.amazonaws.com/v2/email/outbound-emails"
+)
+
+// HTTPTransport sends messages through an email provider's HTTP API:
+// SendGrid, Postmark or Amazon SES (v2, raw MIME).
+type HTTPTransport struct {
+	config   Config
+	provider string
+	endpoint string
+	apiKey   string
+	secret   string
+	region   string
+	client   *http.Client
+}
+
+// NewHTTPTransport creates a transport for the provider named by tc.Type.
+func NewHTTPTransport(config Config, tc TransportConfig) (*HTTPTransport, error) {
+	t := &HTTPTransport{
+		config:   config,
+		provider: tc.Type,
+		endpoint: tc.Endpoint,
+		apiKey:   tc.APIKey,
+		secret:   tc.SecretKey,
+		region:   tc.Region,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+
+	switch tc.Type {
+	case TransportSendGrid:
+		if t.endpoint == "" {
+			t.endpoint = sendGridEndpoint
+		}
+	case TransportPostmark:
+		if t.endpoint == "" {
+			t.endpoint = postmarkEndpoint
+		}
+	case TransportSES:
+		if t.region == "" {
+			t.region = "us-east-1"
+		}
+		if t.endpoint == "" {
+			t.endpoint = fmt.Sprintf(sesEndpoint, t.region)
+		}
+		if t.secret == "" {
+			return nil, fmt.Errorf("ses transport requires a secret key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown HTTP provider %q", tc.Type)
+	}
+	if t.apiKey == "" {
+		return nil, fmt.Errorf("%s transport requires an API key", tc.Type)
+	}
+
+	return t, nil
+}
+
+// Send posts msg to the provider API.
+func (t *HTTPTransport) Send(ctx context.Context, msg Message) error {
+	_, err := t.SendID(ctx, msg)
+	return err
+}
+
+// SendID posts msg to the provider API and returns the ID the provider
+// assigned to it.
+func (t *HTTPTransport) SendID(ctx context.Context, msg Message) (string, error) {
+	var body any
+	var err error
+	switch t.provider {
+	case TransportSendGrid:
+		body, err = t.sendGridBody(msg)
+	case TransportPostmark:
+		body, err = t.postmarkBody(msg)
+	case TransportSES:
+		body, err = t.sesBody(msg)
+	}
+	if err != nil {
+		return "", fmt.Errorf("build %s request: %w", t.provider, err)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("encode %s request: %w", t.provider, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	switch t.provider {
+	case TransportSendGrid:
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	case TransportPostmark:
+		req.Header.Set("X-Postmark-Server-Token", t.apiKey)
+	case TransportSES:
+		t.signV4(req, payload, time.Now().UTC())
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", t.provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", &ProviderError{Provider: t.provider, Status: resp.StatusCode, Body: strings.TrimSpace(string(detail))}
+	}
+
+	// SendGrid returns the ID in a header, Postmark and SES in the body
+	if t.provider == TransportSendGrid {
+		return resp.Header.Get("X-Message-Id"), nil
+	}
+	var accepted struct {
+		MessageID string // Postmark "MessageID", SES "MessageId"
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&accepted)
+	return accepted.MessageID, nil
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+func (t *HTTPTransport) sendGridBody(msg Message) (any, error) {
+	addresses := func(list []string) []sendGridAddress {
+		var out []sendGridAddress
+		for _, addr := range list {
+			a := sendGridAddress{Email: addr}
+			if parsed, err := netmail.ParseAddress(addr); err == nil {
+				a = sendGridAddress{Email: parsed.Address, Name: parsed.Name}
+			}
+			out = append(out, a)
+		}
+		return out
+	}
+
+	personalization := map[string]any{"to": addresses(msg.To)}
+	if len(msg.Cc) > 0 {
+		personalization["cc"] = addresses(msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		personalization["bcc"] = addresses(msg.Bcc)
+	}
+
+	var content []map[string]string
+	if msg.Text != "" {
+		content = append(content, map[string]string{"type": "text/plain", "value": msg.Text})
+	}
+	content = append(content, map[string]string{"type": "text/html", "value": msg.HTML})
+
+	body := map[string]any{
+		"personalizations": []any{personalization},
+		"from":             sendGridAddress{Email: t.config.FromEmail, Name: t.config.FromName},
+		"subject":          msg.Subject,
+		"content":          content,
+	}
+	if msg.ReplyTo != "" {
+		body["reply_to"] = addresses([]string{msg.ReplyTo})[0]
+	}
+	if headers := msg.headers(); len(headers) > 0 {
+		body["headers"] = headers
+	}
+
+	var attachments []map[string]string
+	for _, a := range msg.Attachments {
+		att := map[string]string{
+			"content":     base64.StdEncoding.EncodeToString(a.Data),
+			"filename":    a.Filename,
+			"type":        attachmentType(a),
+			"disposition": "attachment",
+		}
+		if a.ContentID != "" {
+			att["disposition"] = "inline"
+			att["content_id"] = a.ContentID
+		}
+		attachments = append(attachments, att)
+	}
+	if len(attachments) > 0 {
+		body["attachments"] = attachments
+	}
+
+	return body, nil
+}
+
+func (t *HTTPTransport) postmarkBody(msg Message) (any, error) {
+	body := map[string]any{
+		"From":     formatAddress(t.config.FromName, t.config.FromEmail),
+		"To":       formatAddressList(msg.To),
+		"Subject":  msg.Subject,
+		"HtmlBody": msg.HTML,
+	}
+	if msg.Text != "" {
+		body["TextBody"] = msg.Text
+	}
+	if len(msg.Cc) > 0 {
+		body["Cc"] = formatAddressList(msg.Cc)
+	}
+	if len(msg.Bcc) > 0 {
+		body["Bcc"] = formatAddressList(msg.Bcc)
+	}
+	if msg.ReplyTo != "" {
+		body["ReplyTo"] = msg.ReplyTo
+	}
+
+	if extra := msg.headers(); len(extra) > 0 {
+		names := make([]string, 0, len(extra))
+		for name := range extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		headers := make([]map[string]string, 0, len(names))
+		for _, name := range names {
+			headers = append(headers, map[string]string{"Name": name, "Value": extra[name]})
+		}
+		body["Headers"] = headers
+	}
+
+	var attachments []map[string]string
+	for _, a := range msg.Attachments {
+		att := map[string]string{
+			"Name":        a.Filename,
+			"Content":     base64.StdEncoding.EncodeToString(a.Data),
+			"ContentType": attachmentType(a),
+		}
+		if a.ContentID != "" {
+			att["ContentID"] = "cid:" + a.ContentID
+		}
+		attachments = append(attachments, att)
+	}
+	if len(attachments) > 0 {
+		body["Attachments"] = attachments
+	}
+
+	return body, nil
+}
+
+// sesBody sends the message as raw MIME, so SES delivers exactly what
+// Build produces, attachments, custom headers and any DKIM signature
+// included.
+func (t *HTTPTransport) sesBody(msg Message) (any, error) {
+	raw, err := buildSigned(t.config, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	destination := map[string][]string{"ToAddresses": Recipients(Message{To: msg.To})}
+	if len(msg.Cc) > 0 {
+		destination["CcAddresses"] = Recipients(Message{To: msg.Cc})
+	}
+	if len(msg.Bcc) > 0 {
+		destination["BccAddresses"] = Recipients(Message{To: msg.Bcc})
+	}
+
+	return map[string]any{
+		"FromEmailAddress": t.config.FromEmail,
+		"Destination":      destination,
+		"Content": map[string]any{
+			"Raw": map[string]string{"Data": base64.StdEncoding.EncodeToString(raw)},
+		},
+	}, nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req.
+func (t *HTTPTransport) signV4(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + t.region + "/ses/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	payloadHash := sha256Hex(payload)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders := "content-type;host;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+t.secret), date)
+	for _, part := range []string{t.region, "ses", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.apiKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func attachmentType(a Attachment) string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(a.Filename))); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
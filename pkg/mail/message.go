@@ -0,0 +1,299 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attachment is a file sent with a message. Attachments with a ContentID
+// are embedded inline and can be referenced from the HTML as
+// <img src="cid:ContentID">.
+type Attachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"` // Detected from Filename when empty
+	Data        []byte `json:"data"`
+	ContentID   string `json:"content_id,omitempty"`
+}
+
+// reservedHeaders are set by Build and cannot be given in Message.Headers.
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Reply-To":                  true,
+	"Subject":                   true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// part is a MIME entity: its headers and encoded body.
+type part struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Build returns msg as an RFC 5322 message ready to be sent. The body is
+// text/html, or multipart/alternative when msg has a text part, wrapped in
+// multipart/related for inline images and multipart/mixed for attachments.
+// Non-ASCII subjects and display names are RFC 2047 encoded. Date and
+// Message-ID are generated unless given in msg.Headers.
+func Build(config Config, msg Message) ([]byte, error) {
+	var inline, attached []part
+	for _, a := range msg.Attachments {
+		p, err := attachmentPart(a)
+		if err != nil {
+			return nil, err
+		}
+		if a.ContentID != "" {
+			inline = append(inline, p)
+		} else {
+			attached = append(attached, p)
+		}
+	}
+
+	body := textPart("text/html", msg.HTML)
+	if msg.Text != "" {
+		alt, err := multipartOf("alternative", textPart("text/plain", msg.Text), body)
+		if err != nil {
+			return nil, err
+		}
+		body = alt
+	}
+	if len(inline) > 0 {
+		related, err := multipartOf("related", append([]part{body}, inline...)...)
+		if err != nil {
+			return nil, err
+		}
+		body = related
+	}
+	if len(attached) > 0 {
+		mixed, err := multipartOf("mixed", append([]part{body}, attached...)...)
+		if err != nil {
+			return nil, err
+		}
+		body = mixed
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	header("From", formatAddress(config.FromName, config.FromEmail))
+	header("To", formatAddressList(msg.To))
+	if len(msg.Cc) > 0 {
+		header("Cc", formatAddressList(msg.Cc))
+	}
+	if msg.ReplyTo != "" {
+		header("Reply-To", formatAddressList([]string{msg.ReplyTo}))
+	}
+	header("Subject", mime.QEncoding.Encode("UTF-8", msg.Subject))
+
+	extra := msg.headers()
+	custom := make(map[string]string, len(extra))
+	for key, value := range extra {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if reservedHeaders[key] {
+			return nil, fmt.Errorf("header %s cannot be set directly", key)
+		}
+		if strings.ContainsAny(key+value, "\r\n") || strings.ContainsAny(key, " :") {
+			return nil, fmt.Errorf("invalid header %q", key)
+		}
+		custom[key] = value
+	}
+	if _, ok := custom["Date"]; !ok {
+		custom["Date"] = time.Now().Format(time.RFC1123Z)
+	}
+	if _, ok := custom["Message-Id"]; !ok {
+		custom["Message-Id"] = MessageID(config.FromEmail)
+	}
+
+	keys := make([]string, 0, len(custom))
+	for key := range custom {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		name := key
+		if key == "Message-Id" {
+			name = "Message-ID"
+		}
+		header(name, mime.QEncoding.Encode("UTF-8", custom[key]))
+	}
+
+	header("MIME-Version", "1.0")
+	for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if value := body.header.Get(key); value != "" {
+			header(key, value)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.body)
+
+	return buf.Bytes(), nil
+}
+
+// headers returns the extra headers of msg, with List-Unsubscribe and
+// List-Unsubscribe-Post when it has an unsubscribe URL. Those replace any
+// given in msg.Headers.
+func (msg Message) headers() map[string]string {
+	if msg.Unsubscribe == "" {
+		return msg.Headers
+	}
+	headers := make(map[string]string, len(msg.Headers)+2)
+	for key, value := range msg.Headers {
+		switch textproto.CanonicalMIMEHeaderKey(key) {
+		case "List-Unsubscribe", "List-Unsubscribe-Post":
+			continue
+		}
+		headers[key] = value
+	}
+	headers["List-Unsubscribe"] = "<" + msg.Unsubscribe + ">"
+	headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	return headers
+}
+
+// messageID returns the Message-ID of a built message, without the angle
+// brackets, or "" if it has none.
+func messageID(raw []byte) string {
+	header, _, _ := bytes.Cut(raw, []byte("\r\n\r\n"))
+	field, ok := lastField(headerFields(header), "Message-ID")
+	if !ok {
+		return ""
+	}
+	_, value, _ := strings.Cut(field, ":")
+	return strings.Trim(strings.TrimSpace(value), "<>")
+}
+
+// MessageID returns a new globally unique Message-ID for the sender's domain.
+func MessageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("<%s.%s@%s>", time.Now().UTC().Format("20060102150405"), hex.EncodeToString(b), domain)
+}
+
+// Recipients returns the envelope addresses of msg: To, Cc and Bcc without
+// display names.
+func Recipients(msg Message) []string {
+	all := make([]string, 0, len(msg.To)+len(msg.Cc)+len(msg.Bcc))
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		for _, addr := range list {
+			if parsed, err := netmail.ParseAddress(addr); err == nil {
+				addr = parsed.Address
+			}
+			all = append(all, addr)
+		}
+	}
+	return all
+}
+
+func formatAddress(name, address string) string {
+	return (&netmail.Address{Name: name, Address: address}).String()
+}
+
+// formatAddressList encodes display names in addresses such as
+// "Zoë <zoe@example.com>". Addresses that do not parse are used as given.
+func formatAddressList(addrs []string) string {
+	formatted := make([]string, len(addrs))
+	for i, addr := range addrs {
+		if parsed, err := netmail.ParseAddress(addr); err == nil {
+			addr = parsed.String()
+		}
+		formatted[i] = addr
+	}
+	return strings.Join(formatted, ", ")
+}
+
+func textPart(mediaType, content string) part {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(content))
+	qp.Close()
+
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mediaType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+func attachmentPart(a Attachment) (part, error) {
+	if a.Filename == "" {
+		return part{}, fmt.Errorf("attachment has no filename")
+	}
+
+	disposition := "attachment"
+	if a.ContentID != "" {
+		disposition = "inline"
+	}
+
+	header := textproto.MIMEHeader{
+		"Content-Type":              {attachmentType(a)},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if a.ContentID != "" {
+		header.Set("Content-ID", "<"+strings.Trim(a.ContentID, "<>")+">")
+	}
+
+	return part{header: header, body: base64Lines(a.Data)}, nil
+}
+
+// base64Lines encodes data as base64 wrapped at 76 characters per line.
+func base64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	return buf.Bytes()
+}
+
+// multipartOf wraps parts in a multipart entity of the given subtype.
+func multipartOf(subtype string, parts ...part) (part, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		w, err := mw.CreatePart(p.header)
+		if err != nil {
+			return part{}, err
+		}
+		if _, err := w.Write(p.body); err != nil {
+			return part{}, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return part{}, err
+	}
+
+	return part{
+		header: textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": mw.Boundary()})},
+		},
+		body: buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestBuildMultipart(t *testing.T) {
+	config := Config{FromEmail: "billing@example.com", FromName: "Zoë's Shop"}
+	msg := Message{
+		To:      []string{"Ada Lovelace <ada@example.com>"},
+		Cc:      []string{"ops@example.com"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "Your invoice — März",
+		HTML:    `<p>See attached</p><img src="cid:logo">`,
+		Text:    "See attached",
+		Headers: map[string]string{"X-Campaign": "invoices"},
+		Attachments: []Attachment{
+			{Filename: "logo.png", Data: []byte("png"), ContentID: "logo"},
+			{Filename: "invoice.pdf", Data: bytes.Repeat([]byte("%PDF"), 40)},
+		},
+	}
+
+	raw, err := Build(config, msg)
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, _ := dec.DecodeHeader(parsed.Header.Get("Subject"))
+	if subject != msg.Subject {
+		t.Errorf("Subject not round-tripped: %q", subject)
+	}
+	from, err := parsed.Header.AddressList("From")
+	if err != nil || from[0].Name != "Zoë's Shop" {
+		t.Errorf("From display name not encoded correctly: %v %v", from, err)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Error("Bcc must not appear in headers")
+	}
+	if parsed.Header.Get("Message-Id") == "" || parsed.Header.Get("Date") == "" {
+		t.Error("Expected generated Message-ID and Date")
+	}
+	if parsed.Header.Get("X-Campaign") != "invoices" {
+		t.Error("Custom header missing")
+	}
+
+	// mixed -> [related -> [alternative, logo], invoice]
+	mixed := readParts(t, parsed.Header.Get("Content-Type"), parsed.Body, "multipart/mixed")
+	if len(mixed) != 2 {
+		t.Fatalf("Expected 2 parts in multipart/mixed, got %d", len(mixed))
+	}
+	if !strings.HasPrefix(mixed[1].Header.Get("Content-Disposition"), "attachment") ||
+		mixed[1].Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("Unexpected attachment headers: %v", mixed[1].Header)
+	}
+
+	related := readParts(t, mixed[0].Header.Get("Content-Type"), bytes.NewReader(mixed[0].body), "multipart/related")
+	if len(related) != 2 || related[1].Header.Get("Content-Id") != "<logo>" {
+		t.Fatalf("Expected inline logo with Content-ID, got %d parts", len(related))
+	}
+
+	alternative := readParts(t, related[0].Header.Get("Content-Type"), bytes.NewReader(related[0].body), "multipart/alternative")
+	if len(alternative) != 2 || string(alternative[0].body) != "See attached" {
+		t.Errorf("Expected text part first in multipart/alternative")
+	}
+
+	recipients := Recipients(msg)
+	if strings.Join(recipients, ",") != "ada@example.com,ops@example.com,audit@example.com" {
+		t.Errorf("Unexpected envelope recipients: %v", recipients)
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	cases := []map[string]string{
+		{"X-Note": "a\r\nBcc: victim@example.com"},
+		{"Subject": "override"},
+	}
+	for _, headers := range cases {
+		if _, err := Build(Config{}, Message{To: []string{"a@example.com"}, Headers: headers}); err == nil {
+			t.Errorf("Expected error for headers %v", headers)
+		}
+	}
+}
+
+func TestBuildUnsubscribe(t *testing.T) {
+	raw, err := Build(Config{FromEmail: "news@example.com"}, Message{
+		To:          []string{"reader@example.com"},
+		Headers:     map[string]string{"list-unsubscribe": "<mailto:old@example.com>"},
+		Unsubscribe: "https://mail.example.com/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	parsed, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if got := parsed.Header["List-Unsubscribe"]; len(got) != 1 || got[0] != "<https://mail.example.com/unsubscribe?token=abc>" {
+		t.Errorf("Unexpected List-Unsubscribe %q", got)
+	}
+	if got := parsed.Header.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("Unexpected List-Unsubscribe-Post %q", got)
+	}
+}
+
+type rawPart struct {
+	Header textproto.MIMEHeader
+	body   []byte
+}
+
+// readParts checks the media type and returns the decoded parts of a
+// multipart body.
+func readParts(t *testing.T, contentType string, r io.Reader, want string) []rawPart {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != want {
+		t.Fatalf("Expected %s, got %q (%v)", want, contentType, err)
+	}
+
+	var parts []rawPart
+	mr := multipart.NewReader(r, params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart failed: %v", err)
+		}
+
+		var body io.Reader = p
+		if p.Header.Get("Content-Transfer-Encoding") == "quoted-printable" {
+			body = quotedprintable.NewReader(p)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		parts = append(parts, rawPart{Header: p.Header, body: data})
+	}
+	return parts
+}
@@ -0,0 +1,410 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sync"
+	"time"
+)
+
+// Pool defaults, used when the Config fields are zero.
+const (
+	DefaultMaxConns      = 4
+	DefaultIdleTimeout   = 30 * time.Second
+	DefaultMaxMessages   = 100
+	DefaultMaxRecipients = 100
+
+	dialTimeout = 30 * time.Second
+	sendTimeout = 5 * time.Minute
+)
+
+// Pool keeps authenticated SMTP connections open and reuses them for later
+// messages, from any goroutine. Connections are closed after IdleTimeout
+// unused, after MaxMessages messages, and on any connection error.
+type Pool struct {
+	config        Config
+	idleTimeout   time.Duration
+	maxMessages   int
+	maxRecipients int
+	slots         chan struct{} // One per connection in use
+	tlsMode       string
+	tls           *tls.Config
+	tokens        *tokenSource
+	configErr     error // Reported by Send, as NewPool cannot fail
+
+	mu     sync.Mutex
+	idle   []*poolConn // Most recently used last
+	timer  *time.Timer // Closes idle connections as they expire
+	closed bool
+}
+
+// poolConn is an SMTP session ready for the next transaction.
+type poolConn struct {
+	conn     net.Conn
+	client   *smtp.Client
+	messages int
+	lastUsed time.Time
+}
+
+// NewPool creates a connection pool for the SMTP server in config.
+// Connections are opened on demand.
+func NewPool(config Config) *Pool {
+	p := &Pool{
+		config:        config,
+		idleTimeout:   config.IdleTimeout,
+		maxMessages:   config.MaxMessages,
+		maxRecipients: config.MaxRecipients,
+	}
+	if p.idleTimeout <= 0 {
+		p.idleTimeout = DefaultIdleTimeout
+	}
+	if p.maxMessages <= 0 {
+		p.maxMessages = DefaultMaxMessages
+	}
+	if p.maxRecipients <= 0 {
+		p.maxRecipients = DefaultMaxRecipients
+	}
+	maxConns := config.MaxConns
+	if maxConns <= 0 {
+		maxConns = DefaultMaxConns
+	}
+	p.slots = make(chan struct{}, maxConns)
+
+	p.tokens = newTokenSource(config)
+	if p.tlsMode, p.configErr = tlsMode(config); p.configErr == nil {
+		p.tls, p.configErr = tlsConfig(config)
+	}
+	return p
+}
+
+// Send delivers msg to all of its recipients in one SMTP transaction, or
+// several when it has more than MaxRecipients.
+//
+// Recipients the server refuses are skipped and the message is delivered
+// to the others. When that leaves some recipients undelivered, the error
+// is a *RecipientError telling which; when every recipient failed for the
+// same reason, it is that error.
+func (p *Pool) Send(ctx context.Context, msg Message) error {
+	_, err := p.SendID(ctx, msg)
+	return err
+}
+
+// SendID is Send, returning the Message-ID of the message sent. The ID is
+// returned with a *RecipientError when the message reached any recipient.
+func (p *Pool) SendID(ctx context.Context, msg Message) (string, error) {
+	if p.configErr != nil {
+		return "", p.configErr
+	}
+
+	raw, err := buildSigned(p.config, msg)
+	if err != nil {
+		return "", fmt.Errorf("build message: %w", err)
+	}
+
+	rcpts := Recipients(msg)
+	if len(rcpts) == 0 {
+		return "", fmt.Errorf("message has no recipients")
+	}
+
+	result := &RecipientError{Failed: make(map[string]error)}
+	var causes []error
+	for len(rcpts) > 0 {
+		n := min(len(rcpts), p.maxRecipients)
+		chunk := rcpts[:n]
+		rcpts = rcpts[n:]
+
+		refused, err := p.send(ctx, chunk, raw)
+		if err != nil {
+			causes = append(causes, err)
+			for _, rcpt := range chunk {
+				result.Failed[rcpt] = err
+			}
+			// Later transactions cannot succeed without a connection
+			if !isReply(err) {
+				for _, rcpt := range rcpts {
+					result.Failed[rcpt] = err
+				}
+				break
+			}
+			continue
+		}
+		for _, rcpt := range chunk {
+			if err := refused[rcpt]; err != nil {
+				causes = append(causes, err)
+				result.Failed[rcpt] = err
+			} else {
+				result.Sent = append(result.Sent, rcpt)
+			}
+		}
+	}
+
+	switch {
+	case len(result.Failed) == 0:
+		return messageID(raw), nil
+	case len(result.Sent) == 0 && len(causes) == 1:
+		return "", causes[0]
+	case len(result.Sent) == 0:
+		return "", result
+	}
+	return messageID(raw), result
+}
+
+// Close closes idle connections. Connections in use are closed when they
+// are returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.quit()
+	}
+	return nil
+}
+
+// send runs one transaction and returns the replies to the recipients the
+// server refused. A reused connection that fails before the server has
+// replied to MAIL FROM was most likely closed by the server while idle, so
+// the transaction is retried once on a new connection.
+func (p *Pool) send(ctx context.Context, rcpts []string, raw []byte) (map[string]error, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	for retried := false; ; retried = true {
+		c, reused := p.get()
+		if c == nil {
+			var err error
+			if c, err = p.dial(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		stop := c.watch(ctx)
+		err := replyError(c.client.Mail(p.config.FromEmail), "")
+		if err != nil && reused && !retried && !isReply(err) {
+			stop()
+			c.client.Close()
+			continue
+		}
+		var refused map[string]error
+		sent := false
+		if err == nil {
+			refused, sent, err = c.transaction(rcpts, raw)
+		}
+		stop()
+
+		if err != nil || !sent {
+			// The server rejected the message or every recipient; abort
+			// the transaction and keep the session if it is still usable
+			if (err == nil || isReply(err)) && ctx.Err() == nil && c.client.Reset() == nil {
+				p.put(c)
+			} else {
+				c.client.Close()
+			}
+			return refused, err
+		}
+
+		c.messages++
+		p.put(c)
+		return refused, nil
+	}
+}
+
+// transaction sends the recipients and data after a successful MAIL FROM.
+// Recipients the server refuses are returned with its reply, keyed by
+// address, and the data is sent to the others; sent is false when there
+// were none. Error replies are returned as *SMTPError.
+func (c *poolConn) transaction(rcpts []string, raw []byte) (refused map[string]error, sent bool, err error) {
+	refused = make(map[string]error)
+	for _, rcpt := range rcpts {
+		if err := replyError(c.client.Rcpt(rcpt), rcpt); err != nil {
+			if !isReply(err) {
+				return nil, false, err
+			}
+			refused[rcpt] = err
+		}
+	}
+	if len(refused) == len(rcpts) {
+		return refused, false, nil
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return nil, false, replyError(err, "")
+	}
+	if _, err := w.Write(raw); err != nil {
+		return nil, false, err
+	}
+	if err := replyError(w.Close(), ""); err != nil {
+		return nil, false, err
+	}
+	return refused, true, nil
+}
+
+// watch bounds the session by ctx, or sendTimeout when ctx has no
+// deadline, and aborts it if ctx is cancelled. The returned function must
+// be called when the exchange is done.
+func (c *poolConn) watch(ctx context.Context) func() {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func() {
+		stop()
+		c.conn.SetDeadline(time.Time{})
+	}
+}
+
+// get returns the most recently used idle connection, or nil.
+func (p *Pool) get() (*poolConn, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(c.lastUsed) < p.idleTimeout {
+			return c, true
+		}
+		go c.quit()
+	}
+	return nil, false
+}
+
+// put returns a connection to the pool, or closes it when it has sent
+// MaxMessages messages or the pool is closed.
+func (p *Pool) put(c *poolConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed || c.messages >= p.maxMessages {
+		go c.quit()
+		return
+	}
+	c.lastUsed = time.Now()
+	p.idle = append(p.idle, c)
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.idleTimeout, p.prune)
+	}
+}
+
+// prune closes expired idle connections and reschedules itself while any
+// remain.
+func (p *Pool) prune() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.timer = nil
+	if p.closed {
+		return
+	}
+
+	now := time.Now()
+	kept := p.idle[:0]
+	for _, c := range p.idle {
+		if now.Sub(c.lastUsed) >= p.idleTimeout {
+			go c.quit()
+		} else {
+			kept = append(kept, c)
+		}
+	}
+	p.idle = kept
+
+	if len(p.idle) > 0 {
+		// The oldest connection is first
+		p.timer = time.AfterFunc(p.idle[0].lastUsed.Add(p.idleTimeout).Sub(now), p.prune)
+	}
+}
+
+// dial opens a session: connect, secure it according to the TLS mode and
+// authenticate when configured.
+func (p *Pool) dial(ctx context.Context) (*poolConn, error) {
+	addr := net.JoinHostPort(p.config.SMTPHost, p.config.SMTPPort)
+	d := &net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if p.tlsMode == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: d, Config: p.tls}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	c := &poolConn{conn: conn}
+	stop := c.watch(ctx)
+	defer stop()
+
+	client, err := smtp.NewClient(conn, p.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp greeting from %s: %w", addr, replyError(err, ""))
+	}
+	c.client = client
+
+	if p.tlsMode == TLSOpportunistic || p.tlsMode == TLSStartTLS {
+		ok, _ := client.Extension("STARTTLS")
+		if !ok && p.tlsMode == TLSStartTLS {
+			client.Close()
+			return nil, fmt.Errorf("smtp: %s does not offer STARTTLS", addr)
+		}
+		if ok {
+			if err := client.StartTLS(p.tls); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("starttls: %w", replyError(err, ""))
+			}
+		}
+	}
+
+	auth, err := p.authenticator(ctx)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			client.Close()
+			return nil, errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("smtp auth: %w", replyError(err, ""))
+		}
+	}
+
+	return c, nil
+}
+
+// quit ends the session politely, giving up after a few seconds.
+func (c *poolConn) quit() {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.client.Close()
+	}
+}
+
+// isReply reports whether err is an SMTP error reply from the server, as
+// opposed to a network or protocol failure.
+func isReply(err error) bool {
+	var reply *SMTPError
+	return errors.As(err, &reply)
+}
@@ -0,0 +1,324 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpServer is a minimal SMTP server that records connections and the
+// recipients of each delivered transaction.
+type smtpServer struct {
+	ln net.Listener
+
+	mu           sync.Mutex
+	conns        int
+	transactions [][]string
+	secure       []bool   // Whether each delivery was over TLS
+	logins       []string // Mechanism and decoded credentials of each AUTH
+
+	reject    string // RCPT TO address refused with 550
+	dropAfter bool   // Close the connection after each delivery
+
+	tls      *tls.Config // Offer STARTTLS with this config
+	implicit bool        // Require TLS from the start instead
+	auth     string      // Mechanisms offered, e.g. "PLAIN LOGIN"
+}
+
+// newSMTPServer starts a server, configured by setup before it accepts
+// connections.
+func newSMTPServer(t *testing.T, setup ...func(s *smtpServer)) *smtpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpServer{ln: ln}
+	for _, f := range setup {
+		f(s)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) config() Config {
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return Config{SMTPHost: host, SMTPPort: port, FromEmail: "noreply@example.com"}
+}
+
+func (s *smtpServer) stats() (conns int, transactions [][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns, s.transactions
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	secure := s.implicit
+	if s.implicit {
+		conn = tls.Server(conn, s.tls)
+	}
+	defer func() { conn.Close() }()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+
+	var rcpts []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-localhost")
+			if s.tls != nil && !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			if s.auth != "" {
+				tp.PrintfLine("250-AUTH %s", s.auth)
+			}
+			tp.PrintfLine("250 8BITMIME")
+		case "STARTTLS":
+			tp.PrintfLine("220 Ready to start TLS")
+			conn = tls.Server(conn, s.tls)
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			s.authenticate(tp, arg)
+		case "MAIL", "RSET":
+			rcpts = nil
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			addr := strings.Trim(strings.TrimPrefix(strings.ToUpper(arg), "TO:"), "<>")
+			if strings.EqualFold(addr, s.reject) {
+				tp.PrintfLine("550 5.1.1 No such user")
+				continue
+			}
+			rcpts = append(rcpts, strings.ToLower(addr))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			tp.ReadDotBytes()
+			s.mu.Lock()
+			s.transactions = append(s.transactions, rcpts)
+			s.secure = append(s.secure, secure)
+			s.mu.Unlock()
+			tp.PrintfLine("250 Queued")
+			if s.dropAfter {
+				return
+			}
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// authenticate runs an AUTH exchange and records the decoded credentials,
+// accepting any.
+func (s *smtpServer) authenticate(tp *textproto.Conn, arg string) {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	var responses []string
+	challenge := func(text string) {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(text)))
+		line, _ := tp.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		responses = append(responses, string(decoded))
+	}
+
+	switch mechanism {
+	case "LOGIN":
+		challenge("Username:")
+		challenge("Password:")
+	case "CRAM-MD5":
+		challenge("<1.1@localhost>")
+	default:
+		decoded, _ := base64.StdEncoding.DecodeString(initial)
+		responses = append(responses, string(decoded))
+	}
+
+	s.mu.Lock()
+	s.logins = append(s.logins, mechanism+" "+strings.Join(responses, " "))
+	s.mu.Unlock()
+	tp.PrintfLine("235 Authenticated")
+}
+
+func poolMessage(to ...string) Message {
+	return Message{To: to, Subject: "Hello", HTML: "<p>Hello</p>"}
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	s := newSMTPServer(t)
+	pool := NewPool(s.config())
+	defer pool.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := pool.Send(context.Background(), poolMessage("a@example.com")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	conns, txns := s.stats()
+	if conns != 1 || len(txns) != 5 {
+		t.Errorf("Expected 5 transactions over 1 connection, got %d over %d", len(txns), conns)
+	}
+}
+
+func TestPoolRecipientsPerTransaction(t *testing.T) {
+	s := newSMTPServer(t)
+	config := s.config()
+	config.MaxRecipients = 2
+	pool := NewPool(config)
+	defer pool.Close()
+
+	msg := poolMessage("Ada <a@example.com>")
+	msg.Cc = []string{"b@example.com"}
+	msg.Bcc = []string{"c@example.com"}
+	if err := pool.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	_, txns := s.stats()
+	if len(txns) != 2 || len(txns[0]) != 2 || len(txns[1]) != 1 || txns[0][0] != "a@example.com" {
+		t.Errorf("Expected recipients split 2+1, got %v", txns)
+	}
+}
+
+func TestPoolRecyclesConnections(t *testing.T) {
+	t.Run("dropped by server", func(t *testing.T) {
+		s := newSMTPServer(t, func(s *smtpServer) { s.dropAfter = true })
+		pool := NewPool(s.config())
+		defer pool.Close()
+
+		for i := 0; i < 3; i++ {
+			if err := pool.Send(context.Background(), poolMessage("a@example.com")); err != nil {
+				t.Fatalf("Send %d failed: %v", i, err)
+			}
+		}
+		if conns, txns := s.stats(); conns != 3 || len(txns) != 3 {
+			t.Errorf("Expected a new connection per message, got %d for %d", conns, len(txns))
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		s := newSMTPServer(t)
+		config := s.config()
+		config.IdleTimeout = 20 * time.Millisecond
+		pool := NewPool(config)
+		defer pool.Close()
+
+		pool.Send(context.Background(), poolMessage("a@example.com"))
+		time.Sleep(50 * time.Millisecond)
+		pool.Send(context.Background(), poolMessage("a@example.com"))
+		if conns, _ := s.stats(); conns != 2 {
+			t.Errorf("Expected idle connection to be replaced, got %d connections", conns)
+		}
+	})
+
+	t.Run("max messages", func(t *testing.T) {
+		s := newSMTPServer(t)
+		config := s.config()
+		config.MaxMessages = 2
+		pool := NewPool(config)
+		defer pool.Close()
+
+		for i := 0; i < 3; i++ {
+			pool.Send(context.Background(), poolMessage("a@example.com"))
+		}
+		if conns, _ := s.stats(); conns != 2 {
+			t.Errorf("Expected 2 connections for 3 messages, got %d", conns)
+		}
+	})
+}
+
+func TestPoolKeepsSessionAfterRejection(t *testing.T) {
+	s := newSMTPServer(t, func(s *smtpServer) { s.reject = "nobody@example.com" })
+	pool := NewPool(s.config())
+	defer pool.Close()
+
+	err := pool.Send(context.Background(), poolMessage("nobody@example.com"))
+	var smtpErr *SMTPError
+	if !errors.As(err, &smtpErr) {
+		t.Fatalf("Expected *SMTPError, got %v", err)
+	}
+	if smtpErr.Code != 550 || smtpErr.Enhanced != "5.1.1" || smtpErr.Recipient != "nobody@example.com" || Classify(err) != Permanent {
+		t.Errorf("Unexpected rejection %+v", smtpErr)
+	}
+	if err := pool.Send(context.Background(), poolMessage("a@example.com")); err != nil {
+		t.Fatalf("Send after rejection failed: %v", err)
+	}
+	if conns, txns := s.stats(); conns != 1 || len(txns) != 1 {
+		t.Errorf("Expected the session to be reused, got %d connections", conns)
+	}
+}
+
+func TestPoolSkipsRefusedRecipients(t *testing.T) {
+	s := newSMTPServer(t, func(s *smtpServer) { s.reject = "nobody@example.com" })
+	config := s.config()
+	config.MaxRecipients = 2
+	pool := NewPool(config)
+	defer pool.Close()
+
+	msg := poolMessage("a@example.com", "Nobody <nobody@example.com>", "c@example.com")
+	id, err := pool.SendID(context.Background(), msg)
+	var partial *RecipientError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected *RecipientError, got %v", err)
+	}
+	if id == "" || strings.Join(partial.Sent, ",") != "a@example.com,c@example.com" {
+		t.Errorf("Expected a Message-ID and 2 recipients sent to, got %q and %v", id, partial.Sent)
+	}
+	if partial.For("a@example.com") != nil || Classify(partial.For("Nobody <nobody@example.com>")) != Permanent {
+		t.Errorf("Unexpected recipient errors %v", partial.Failed)
+	}
+
+	_, txns := s.stats()
+	if len(txns) != 2 || strings.Join(txns[0], ",") != "a@example.com" || strings.Join(txns[1], ",") != "c@example.com" {
+		t.Errorf("Expected both transactions to skip the refused recipient, got %v", txns)
+	}
+}
+
+func TestPoolLimitsConnections(t *testing.T) {
+	s := newSMTPServer(t)
+	config := s.config()
+	config.MaxConns = 2
+	pool := NewPool(config)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := pool.Send(context.Background(), poolMessage("a@example.com")); err != nil {
+				t.Errorf("Send failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if conns, txns := s.stats(); conns > 2 || len(txns) != 10 {
+		t.Errorf("Expected 10 messages over at most 2 connections, got %d over %d", len(txns), conns)
+	}
+}
@@ -2,8 +2,8 @@
 package mail
 
 import (
-	"fmt"
-	"net/smtp"
+	"context"
+	"time"
 )
 
 // Config holds configuration for sending emails via SMTP.
@@ -14,32 +14,66 @@ type Config struct {
 	Password  string
 	FromEmail string
 	FromName  string
+
+	// Connection pooling, see Pool. Zero values use the defaults.
+	MaxConns      int           // Concurrent connections
+	IdleTimeout   time.Duration // Close connections unused for this long
+	MaxMessages   int           // Messages per connection before reconnecting
+	MaxRecipients int           // RCPT TO per transaction
+
+	// Security. TLSMode defaults to implicit TLS on port 465 and
+	// opportunistic STARTTLS elsewhere; Auth defaults to PLAIN when a
+	// Username is set.
+	TLSMode            string // TLSOpportunistic, TLSStartTLS, TLSImplicit or TLSNone
+	CAFile             string // PEM certificates trusted for the server, e.g. an internal CA
+	InsecureSkipVerify bool
+	Auth               string // AuthPlain, AuthLogin, AuthCRAMMD5, AuthXOAUTH2 or AuthNone
+
+	// XOAUTH2. With a refresh token, access tokens are fetched from
+	// OAuthTokenURL (Google's by default); otherwise Password is used as
+	// the access token.
+	OAuthClientID     string
+	OAuthClientSecret string
+	OAuthRefreshToken string
+	OAuthTokenURL     string
+
+	// DKIM signs messages from domains it has a key for. SendGrid and
+	// Postmark sign with their own domain settings instead.
+	DKIM *DKIMSigner
+}
+
+// Message is an HTML email to one or more recipients. When Text is set the
+// message is sent as multipart/alternative with both parts.
+type Message struct {
+	To          []string
+	Cc          []string
+	Bcc         []string // Receive the message but are not listed in its headers
+	ReplyTo     string
+	Subject     string
+	HTML        string
+	Text        string
+	Headers     map[string]string // Extra headers, e.g. X-Campaign
+	Attachments []Attachment
+
+	// Unsubscribe is a one-click unsubscribe URL, sent as the
+	// List-Unsubscribe and List-Unsubscribe-Post (RFC 8058) headers
+	Unsubscribe string
 }
 
 // Send sends an HTML email.
 func Send(config Config, toEmail, subject, htmlBody string) error {
-	message := fmt.Sprintf(
-		"From: %s <%s>\r\n"+
-			"To: %s\r\n"+
-			"Subject: %s\r\n"+
-			"MIME-Version: 1.0\r\n"+
-			"Content-Type: text/html; charset=UTF-8\r\n"+
-			"\r\n"+
-			"%s",
-		config.FromName, config.FromEmail,
-		toEmail,
-		subject,
-		htmlBody,
-	)
-
-	auth := smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)
-
-	return smtp.SendMail(
-		config.SMTPHost+":"+config.SMTPPort,
-		auth,
-		config.FromEmail,
-		[]string{toEmail},
-		[]byte(message),
-	)
+	return SendMessage(config, Message{
+		To:      []string{toEmail},
+		Subject: subject,
+		HTML:    htmlBody,
+	})
 }
 
+// SendMessage sends a single message to all of its To, Cc and Bcc
+// recipients over a new connection. Use an SMTPTransport to reuse
+// connections across messages.
+func SendMessage(config Config, msg Message) error {
+	pool := NewPool(config)
+	defer pool.Close()
+	return pool.Send(context.Background(), msg)
+}
@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+)
+
+// Transport delivers messages. Implementations must be safe for concurrent use.
+type Transport interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// IDSender is implemented by transports that report the ID of each message
+// they accept: the provider's ID for HTTP APIs, otherwise the Message-ID.
+type IDSender interface {
+	SendID(ctx context.Context, msg Message) (string, error)
+}
+
+// SendID sends msg through t and returns its message ID, or "" when t does
+// not report one.
+func SendID(ctx context.Context, t Transport, msg Message) (string, error) {
+	if s, ok := t.(IDSender); ok {
+		return s.SendID(ctx, msg)
+	}
+	return "", t.Send(ctx, msg)
+}
+
+// Transport types accepted by NewTransport.
+const (
+	TransportSMTP     = "smtp"
+	TransportFile     = "file"
+	TransportSendGrid = "sendgrid"
+	TransportPostmark = "postmark"
+	TransportSES      = "ses"
+)
+
+// TransportConfig selects and configures a transport.
+type TransportConfig struct {
+	Type string // smtp (default), file, sendgrid, postmark or ses
+
+	// File sink
+	Path   string // Directory for .eml files, or the mbox file
+	Format string // eml (default) or mbox
+
+	// HTTP providers
+	Endpoint  string // Override the provider API URL, e.g. for a local stub
+	APIKey    string // SendGrid API key, Postmark server token or AWS access key ID
+	SecretKey string // AWS secret access key (ses)
+	Region    string // AWS region (ses)
+}
+
+// NewTransport creates the transport described by tc. The sender identity,
+// and for SMTP the server settings, come from config.
+func NewTransport(tc TransportConfig, config Config) (Transport, error) {
+	switch tc.Type {
+	case "", TransportSMTP:
+		return NewSMTPTransport(config), nil
+	case TransportFile:
+		return NewFileTransport(config, tc.Path, tc.Format)
+	case TransportSendGrid, TransportPostmark, TransportSES:
+		return NewHTTPTransport(config, tc)
+	default:
+		return nil, fmt.Errorf("unknown transport %q", tc.Type)
+	}
+}
+
+// SMTPTransport sends messages through an SMTP server, reusing connections
+// from a Pool.
+type SMTPTransport struct {
+	pool *Pool
+}
+
+// NewSMTPTransport creates a transport that sends through the SMTP server in config.
+func NewSMTPTransport(config Config) *SMTPTransport {
+	return &SMTPTransport{pool: NewPool(config)}
+}
+
+// Send sends msg over a pooled connection.
+func (t *SMTPTransport) Send(ctx context.Context, msg Message) error {
+	return t.pool.Send(ctx, msg)
+}
+
+// SendID sends msg over a pooled connection and returns its Message-ID.
+func (t *SMTPTransport) SendID(ctx context.Context, msg Message) (string, error) {
+	return t.pool.SendID(ctx, msg)
+}
+
+// Close closes the transport's idle connections.
+func (t *SMTPTransport) Close() error {
+	return t.pool.Close()
+}
@@ -0,0 +1,205 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testConfig = Config{FromEmail: "noreply@example.com", FromName: "Example"}
+
+func testMessage() Message {
+	return Message{
+		To:          []string{"Ada <ada@example.com>"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Hello",
+		HTML:        "<p>Hello</p>\nFrom the team",
+		Text:        "Hello",
+		Headers:     map[string]string{"X-Campaign": "welcome"},
+		Attachments: []Attachment{{Filename: "a.pdf", Data: []byte("pdf")}},
+	}
+}
+
+func TestFileTransportEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	transport, err := NewTransport(TransportConfig{Type: TransportFile, Path: dir}, testConfig)
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := transport.Send(context.Background(), testMessage()); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 .eml files, got %d", len(files))
+	}
+
+	raw, _ := os.ReadFile(files[0])
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Written file is not a valid message: %v", err)
+	}
+	if msg.Header.Get("Subject") != "Hello" {
+		t.Errorf("Unexpected subject %q", msg.Header.Get("Subject"))
+	}
+
+	withID := testMessage()
+	withID.Headers = map[string]string{"Message-ID": "<123@example.com>"}
+	if id, err := SendID(context.Background(), transport, withID); err != nil || id != "123@example.com" {
+		t.Errorf("Expected the message's Message-ID, got %q, %v", id, err)
+	}
+}
+
+func TestFileTransportMbox(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sent.mbox")
+	transport, err := NewFileTransport(testConfig, path, FormatMbox)
+	if err != nil {
+		t.Fatalf("NewFileTransport failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := transport.Send(context.Background(), testMessage()); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	raw, _ := os.ReadFile(path)
+	if n := strings.Count(string(raw), "\nFrom noreply@example.com "); n != 1 || !strings.HasPrefix(string(raw), "From noreply@example.com ") {
+		t.Errorf("Expected 2 mbox separators, got:\n%s", raw)
+	}
+	if !strings.Contains(string(raw), "\n>From the team") {
+		t.Error("Body lines starting with From should be escaped")
+	}
+}
+
+// stubProvider records the last request made to it.
+type stubProvider struct {
+	header http.Header
+	path   string
+	body   map[string]any
+	status int
+}
+
+func (s *stubProvider) server(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.header = r.Header
+		s.path = r.URL.Path
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &s.body)
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			w.Write([]byte(`{"message":"rejected"}`))
+			return
+		}
+		w.Header().Set("X-Message-Id", "sg-1")
+		w.Write([]byte(`{"MessageId":"msg-1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPTransports(t *testing.T) {
+	tests := []struct {
+		provider string
+		check    func(t *testing.T, s *stubProvider)
+	}{
+		{TransportSendGrid, func(t *testing.T, s *stubProvider) {
+			if s.header.Get("Authorization") != "Bearer key" {
+				t.Errorf("Missing bearer token")
+			}
+			to := s.body["personalizations"].([]any)[0].(map[string]any)["to"].([]any)[0].(map[string]any)
+			if to["email"] != "ada@example.com" || to["name"] != "Ada" {
+				t.Errorf("Unexpected recipient %v", to)
+			}
+			if len(s.body["content"].([]any)) != 2 || len(s.body["attachments"].([]any)) != 1 {
+				t.Errorf("Expected text and HTML content and one attachment")
+			}
+		}},
+		{TransportPostmark, func(t *testing.T, s *stubProvider) {
+			if s.header.Get("X-Postmark-Server-Token") != "key" {
+				t.Errorf("Missing server token")
+			}
+			if s.body["To"] != `"Ada" <ada@example.com>` || s.body["Bcc"] != "<audit@example.com>" || s.body["TextBody"] != "Hello" {
+				t.Errorf("Unexpected body %v", s.body)
+			}
+		}},
+		{TransportSES, func(t *testing.T, s *stubProvider) {
+			auth := s.header.Get("Authorization")
+			if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") || !strings.Contains(auth, "/eu-west-1/ses/aws4_request") {
+				t.Errorf("Unexpected Authorization %q", auth)
+			}
+			raw, _ := base64.StdEncoding.DecodeString(s.body["Content"].(map[string]any)["Raw"].(map[string]any)["Data"].(string))
+			if !strings.Contains(string(raw), "X-Campaign: welcome") {
+				t.Error("Raw message missing custom header")
+			}
+			bcc := s.body["Destination"].(map[string]any)["BccAddresses"].([]any)
+			if len(bcc) != 1 || bcc[0] != "audit@example.com" {
+				t.Errorf("Unexpected Bcc destinations %v", bcc)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			stub := &stubProvider{}
+			srv := stub.server(t)
+
+			transport, err := NewTransport(TransportConfig{
+				Type:      tt.provider,
+				Endpoint:  srv.URL + "/send",
+				APIKey:    "key",
+				SecretKey: "secret",
+				Region:    "eu-west-1",
+			}, testConfig)
+			if err != nil {
+				t.Fatalf("NewTransport failed: %v", err)
+			}
+
+			id, err := SendID(context.Background(), transport, testMessage())
+			if err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			tt.check(t, stub)
+			want := "msg-1"
+			if tt.provider == TransportSendGrid {
+				want = "sg-1"
+			}
+			if id != want {
+				t.Errorf("Expected provider message ID %q, got %q", want, id)
+			}
+
+			stub.status = http.StatusUnprocessableEntity
+			err = transport.Send(context.Background(), testMessage())
+			if err == nil || !strings.Contains(err.Error(), "422") || !strings.Contains(err.Error(), "rejected") {
+				t.Errorf("Expected provider error with status and detail, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	for _, tc := range []TransportConfig{
+		{Type: "pigeon"},
+		{Type: TransportSendGrid},
+		{Type: TransportSES, APIKey: "key"},
+		{Type: TransportFile},
+		{Type: TransportFile, Path: t.TempDir(), Format: "maildir"},
+	} {
+		if _, err := NewTransport(tc, testConfig); err == nil {
+			t.Errorf("Expected error for %+v", tc)
+		}
+	}
+}
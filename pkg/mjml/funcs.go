@@ -0,0 +1,284 @@
+package mjml
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultFuncs returns the built-in template helpers. Arguments are ordered
+// so the value can be piped in, e.g. {{.Total | money "EUR"}}.
+//
+//	date "long" .SentAt              format a time (layout or named layout)
+//	dateIn "Europe/Berlin" "long" .T format a time in a timezone
+//	money "USD" .Total               $1,234.50
+//	moneyCents "USD" .TotalCents     amount given in minor units
+//	default "there" .Name            fallback for empty values
+//	upper, lower, title              change case
+//	truncate 80 .Summary             shorten to n characters with an ellipsis
+//	pluralize .Count "item" "items"  pick the singular or plural form
+//	url "https://x.com/a" "k" .V     build a URL with escaped query parameters
+//	markdown .Body                   render Markdown as HTML for mj-text
+func DefaultFuncs() template.FuncMap {
+	return template.FuncMap{
+		"date":       formatDate,
+		"dateIn":     formatDateIn,
+		"money":      formatMoney,
+		"moneyCents": formatMoneyCents,
+		"default":    defaultValue,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      titleCase,
+		"truncate":   truncate,
+		"pluralize":  pluralize,
+		"url":        buildURL,
+		"markdown":   Markdown,
+	}
+}
+
+// WithFuncs registers additional template functions. They are available to
+// all templates, partials and layouts, and override built-ins of the same name.
+func WithFuncs(funcs template.FuncMap) RendererOption {
+	return func(opts *RenderOptions) {
+		if opts.Funcs == nil {
+			opts.Funcs = template.FuncMap{}
+		}
+		for name, fn := range funcs {
+			opts.Funcs[name] = fn
+		}
+	}
+}
+
+// namedLayouts are shorthands accepted by date and dateIn.
+var namedLayouts = map[string]string{
+	"date":     "Jan 2, 2006",
+	"long":     "January 2, 2006",
+	"datetime": "Jan 2, 2006 3:04 PM MST",
+	"time":     "3:04 PM",
+	"iso":      "2006-01-02",
+	"rfc3339":  time.RFC3339,
+}
+
+func formatDate(layout string, value any) (string, error) {
+	t, ok, err := toTime(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	return t.Format(resolveLayout(layout)), nil
+}
+
+func formatDateIn(zone, layout string, value any) (string, error) {
+	t, ok, err := toTime(value)
+	if err != nil || !ok {
+		return "", err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", fmt.Errorf("dateIn: %w", err)
+	}
+	return t.In(loc).Format(resolveLayout(layout)), nil
+}
+
+func resolveLayout(layout string) string {
+	if named, ok := namedLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+// toTime accepts time.Time, *time.Time, RFC 3339 strings and Unix seconds.
+// A zero or missing value reports ok=false so templates render nothing.
+func toTime(value any) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return v, !v.IsZero(), nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, false, nil
+		}
+		return *v, !v.IsZero(), nil
+	case string:
+		if v == "" {
+			return time.Time{}, false, nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true, nil
+			}
+		}
+		return time.Time{}, false, fmt.Errorf("date: cannot parse %q", v)
+	}
+
+	if n, ok := toFloat(value); ok {
+		return time.Unix(int64(n), 0).UTC(), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("date: unsupported value of type %T", value)
+}
+
+// currencies maps ISO 4217 codes to a symbol and number of minor digits.
+var currencies = map[string]struct {
+	symbol string
+	digits int
+}{
+	"USD": {"$", 2},
+	"EUR": {"€", 2},
+	"GBP": {"£", 2},
+	"JPY": {"¥", 0},
+	"CHF": {"CHF ", 2},
+	"AUD": {"A$", 2},
+	"CAD": {"CA$", 2},
+	"NZD": {"NZ$", 2},
+	"INR": {"₹", 2},
+	"CNY": {"CN¥", 2},
+	"SEK": {"SEK ", 2},
+}
+
+func formatMoney(currency string, amount any) (string, error) {
+	n, ok := toFloat(amount)
+	if !ok {
+		return "", fmt.Errorf("money: unsupported amount of type %T", amount)
+	}
+
+	code := strings.ToUpper(currency)
+	c, known := currencies[code]
+	if !known {
+		c.symbol, c.digits = code+" ", 2
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	return sign + c.symbol + groupThousands(strconv.FormatFloat(n, 'f', c.digits, 64)), nil
+}
+
+func formatMoneyCents(currency string, amount any) (string, error) {
+	n, ok := toFloat(amount)
+	if !ok {
+		return "", fmt.Errorf("moneyCents: unsupported amount of type %T", amount)
+	}
+
+	digits := 2
+	if c, known := currencies[strings.ToUpper(currency)]; known {
+		digits = c.digits
+	}
+	return formatMoney(currency, n/math.Pow10(digits))
+}
+
+// groupThousands inserts commas into the integer part of a formatted number.
+func groupThousands(s string) string {
+	intPart, frac, hasFrac := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if hasFrac {
+		b.WriteByte('.')
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+func defaultValue(def, value any) any {
+	if isEmpty(value) {
+		return def
+	}
+	return value
+}
+
+func isEmpty(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func titleCase(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		defer func() { prev = r }()
+		if unicode.IsSpace(prev) || prev == '-' {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+func truncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…"
+}
+
+func pluralize(count any, singular, plural string) string {
+	if n, ok := toFloat(count); ok && n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// buildURL appends key/value pairs to base as escaped query parameters.
+func buildURL(base string, pairs ...any) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("url: expected key/value pairs, got %d arguments", len(pairs))
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("url: %w", err)
+	}
+
+	q := u.Query()
+	for i := 0; i < len(pairs); i += 2 {
+		q.Set(fmt.Sprint(pairs[i]), fmt.Sprint(pairs[i+1]))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// toFloat converts numeric values, including JSON numbers and numeric strings.
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,107 @@
+package mjml
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultFuncs(t *testing.T) {
+	sent := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		template string
+		data     any
+		want     string
+	}{
+		{"date named layout", `{{.T | date "long"}}`, map[string]any{"T": sent}, "March 5, 2024"},
+		{"date from string", `{{.T | date "iso"}}`, map[string]any{"T": "2024-03-05T14:30:00Z"}, "2024-03-05"},
+		{"date missing", `{{.T | date "long"}}`, map[string]any{}, ""},
+		{"dateIn timezone", `{{.T | dateIn "America/New_York" "3:04 PM MST"}}`, map[string]any{"T": sent}, "9:30 AM EST"},
+		{"money", `{{.Total | money "usd"}}`, map[string]any{"Total": 1234.5}, "$1,234.50"},
+		{"money negative", `{{.Total | money "EUR"}}`, map[string]any{"Total": -12}, "-€12.00"},
+		{"money zero decimals", `{{.Total | money "JPY"}}`, map[string]any{"Total": 1500}, "¥1,500"},
+		{"money unknown currency", `{{.Total | money "NOK"}}`, map[string]any{"Total": 99.9}, "NOK 99.90"},
+		{"moneyCents", `{{.Cents | moneyCents "GBP"}}`, map[string]any{"Cents": 123456}, "£1,234.56"},
+		{"default empty", `Hi {{.Name | default "there"}}`, map[string]any{"Name": ""}, "Hi there"},
+		{"default missing", `Hi {{.Name | default "there"}}`, map[string]any{}, "Hi there"},
+		{"default set", `Hi {{.Name | default "there"}}`, map[string]any{"Name": "Ada"}, "Hi Ada"},
+		{"upper", `{{upper "code"}}`, nil, "CODE"},
+		{"title", `{{title "ada lovelace-byron"}}`, nil, "Ada Lovelace-Byron"},
+		{"truncate", `{{.S | truncate 10}}`, map[string]any{"S": "Your order has shipped"}, "Your order…"},
+		{"truncate short", `{{.S | truncate 10}}`, map[string]any{"S": "Shipped"}, "Shipped"},
+		{"pluralize one", `{{.N}} {{pluralize .N "item" "items"}}`, map[string]any{"N": 1}, "1 item"},
+		{"pluralize many", `{{.N}} {{pluralize .N "item" "items"}}`, map[string]any{"N": 3.0}, "3 items"},
+		{"url", `<a href="{{url "https://example.com/track" "id" .ID "to" .Email}}">`,
+			map[string]any{"ID": "a b", "Email": "ada@example.com"},
+			`<a href="https://example.com/track?id=a&#43;b&amp;to=ada%40example.com">`},
+		{"markdown", `{{markdown .Body}}`, map[string]any{"Body": "Hi **Ada**"}, "<p>Hi <strong>Ada</strong></p>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := template.New(tt.name).Funcs(DefaultFuncs()).Parse(tt.template)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			var b strings.Builder
+			if err := tmpl.Execute(&b, tt.data); err != nil {
+				t.Fatalf("Execute failed: %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("Got %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	src := "# Receipt\n\nThanks *Ada*, see [your order](https://example.com/o?id=1&x=2).\nCode: `<b>`\n\n- one\n- two\n\n1. first\n\n[bad](javascript:alert) <script>"
+
+	want := `<h1>Receipt</h1>` +
+		`<p>Thanks <em>Ada</em>, see <a href="https://example.com/o?id=1&amp;x=2">your order</a>.<br />Code: <code>&lt;b&gt;</code></p>` +
+		`<ul><li>one</li><li>two</li></ul>` +
+		`<ol><li>first</li></ol>` +
+		`<p>bad &lt;script&gt;</p>`
+
+	if got := string(Markdown(src)); got != want {
+		t.Errorf("Markdown mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestWithFuncs(t *testing.T) {
+	renderer := NewRenderer(WithFonts(false), WithFuncs(template.FuncMap{
+		"greet": func(name string) string { return "Howdy " + name },
+		"upper": func(s string) string { return "custom:" + s },
+	}))
+
+	content := `<mjml><mj-body><mj-section><mj-column>
+		<mj-text>{{greet .Name}} {{upper "x"}} {{.Total | money "USD"}}</mj-text>
+		<mj-text>{{markdown .Body}}</mj-text>
+	</mj-column></mj-section></mj-body></mjml>`
+
+	if err := renderer.LoadTemplate("receipt", content); err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+
+	html, err := renderer.RenderTemplate("receipt", map[string]any{
+		"Name":  "Ada",
+		"Total": 42,
+		"Body":  "Paid **in full**",
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	for _, want := range []string{"Howdy Ada", "custom:x", "$42.00", "<strong>in full</strong>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Rendered HTML missing %q", want)
+		}
+	}
+
+	if err := renderer.Validate("check", `<mjml><mj-body>{{.When | date "long"}}</mj-body></mjml>`, nil); err != nil {
+		t.Errorf("Validate should know built-in funcs: %v", err)
+	}
+}
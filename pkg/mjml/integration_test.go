@@ -247,6 +247,17 @@ func TestTemplateDirectory(t *testing.T) {
 		}
 
 		contentStr := string(content)
+
+		// Templates built on the base layout get their structure from it
+		if strings.Contains(contentStr, `{{template "base" .}}`) {
+			layout, err := os.ReadFile(filepath.Join(templatesDir, "layouts", "base.mjml"))
+			if err != nil {
+				t.Errorf("Template %s uses the base layout but it is missing: %v", templateFile, err)
+				continue
+			}
+			contentStr += string(layout)
+		}
+
 		if !strings.Contains(contentStr, "<mjml>") {
 			t.Errorf("Template %s missing <mjml> tag", templateFile)
 		}
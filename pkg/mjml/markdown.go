@@ -0,0 +1,120 @@
+package mjml
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Markdown converts a small, email-friendly subset of Markdown to HTML that
+// can be placed inside mj-text: paragraphs, # headings, bullet and numbered
+// lists, **bold**, *italic*, `code` and [links](https://...). All text is
+// escaped and only http, https and mailto links are kept. The output is
+// well-formed XML, as required by the MJML parser.
+func Markdown(src string) template.HTML {
+	var b strings.Builder
+	var para []string
+	list := ""
+
+	flushPara := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>" + strings.Join(para, "<br />") + "</p>")
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			b.WriteString("</" + list + ">")
+			list = ""
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			flushPara()
+			closeList()
+
+		case headingRe.MatchString(line):
+			flushPara()
+			closeList()
+			m := headingRe.FindStringSubmatch(line)
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">" + inlineMarkdown(m[2]) + "</" + tag + ">")
+
+		case bulletRe.MatchString(line), orderedRe.MatchString(line):
+			flushPara()
+			kind, item := "ul", bulletRe.FindStringSubmatch(line)
+			if item == nil {
+				kind, item = "ol", orderedRe.FindStringSubmatch(line)
+			}
+			if list != kind {
+				closeList()
+				b.WriteString("<" + kind + ">")
+				list = kind
+			}
+			b.WriteString("<li>" + inlineMarkdown(item[1]) + "</li>")
+
+		default:
+			closeList()
+			para = append(para, inlineMarkdown(line))
+		}
+	}
+	flushPara()
+	closeList()
+
+	return template.HTML(b.String())
+}
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,3})\s+(.*)$`)
+	bulletRe  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedRe = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+
+	codeRe   = regexp.MustCompile("`([^`]+)`")
+	linkRe   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe   = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicRe = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// inlineMarkdown escapes s and applies inline formatting. Code spans are
+// formatted separately so their contents are left untouched.
+func inlineMarkdown(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range codeRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(inlineFormat(s[last:m[0]]))
+		b.WriteString("<code>" + html.EscapeString(s[m[2]:m[3]]) + "</code>")
+		last = m[1]
+	}
+	b.WriteString(inlineFormat(s[last:]))
+	return b.String()
+}
+
+func inlineFormat(s string) string {
+	s = html.EscapeString(s)
+
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		parts := linkRe.FindStringSubmatch(m)
+		if !safeLink(html.UnescapeString(parts[2])) {
+			return parts[1]
+		}
+		return `<a href="` + parts[2] + `">` + parts[1] + `</a>`
+	})
+	s = boldRe.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = italicRe.ReplaceAllString(s, "<em>$1$2</em>")
+	return s
+}
+
+func safeLink(href string) bool {
+	lower := strings.ToLower(href)
+	for _, scheme := range []string{"https://", "http://", "mailto:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -16,15 +16,34 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	texttemplate "text/template"
 
 	"github.com/joeblew999/plat-mjml/pkg/font"
 	"github.com/preslavrachev/gomjml/mjml"
 )
 
+// PartialDirs are subdirectories of a template directory that hold shared
+// partials and layouts instead of templates. Every file in them is parsed
+// into each template's set, so templates can use {{template "footer" .}}.
+var PartialDirs = []string{"_partials", "layouts"}
+
+// IsPartialDir reports whether a directory name is one of PartialDirs.
+func IsPartialDir(name string) bool {
+	for _, dir := range PartialDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
 // Renderer handles MJML template loading, caching, and rendering
 type Renderer struct {
 	templates   map[string]*template.Template
-	cache       map[string]string // Cache for rendered HTML
+	texts       map[string]*texttemplate.Template // Optional .txt companions for the plain-text part
+	partials    *template.Template                // Shared partials and layouts, cloned into each template
+	funcs       template.FuncMap                  // Built-in helpers merged with WithFuncs
+	cache       map[string]string                 // Cache for rendered HTML
 	mu          sync.RWMutex
 	options     *RenderOptions
 	fontManager *font.Manager
@@ -32,12 +51,13 @@ type Renderer struct {
 
 // RenderOptions configures the MJML renderer behavior
 type RenderOptions struct {
-	EnableCache      bool   // Cache rendered HTML for performance
-	EnableDebug      bool   // Add debug attributes to HTML
-	EnableValidation bool   // Validate MJML before rendering
-	TemplateDir      string // Default directory for templates
-	EnableFonts      bool   // Enable Google Fonts integration
-	FontDir          string // Font cache directory (empty = default from config)
+	EnableCache      bool             // Cache rendered HTML for performance
+	EnableDebug      bool             // Add debug attributes to HTML
+	EnableValidation bool             // Validate MJML before rendering
+	TemplateDir      string           // Default directory for templates
+	EnableFonts      bool             // Enable Google Fonts integration
+	FontDir          string           // Font cache directory (empty = default from config)
+	Funcs            template.FuncMap // Extra template functions (see WithFuncs)
 }
 
 // RendererOption configures the renderer
@@ -94,15 +114,20 @@ func NewRenderer(opts ...RendererOption) *Renderer {
 		TemplateDir:      "./templates",
 		EnableFonts:      true,
 	}
-	
+
 	for _, opt := range opts {
 		opt(options)
 	}
 
 	renderer := &Renderer{
 		templates: make(map[string]*template.Template),
+		texts:     make(map[string]*texttemplate.Template),
 		cache:     make(map[string]string),
 		options:   options,
+		funcs:     DefaultFuncs(),
+	}
+	for name, fn := range options.Funcs {
+		renderer.funcs[name] = fn
 	}
 
 	// Initialize font manager if fonts are enabled
@@ -122,18 +147,18 @@ func (r *Renderer) LoadTemplate(name, content string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	tmpl, err := template.New(name).Parse(content)
+	tmpl, err := r.parse(name, content)
 	if err != nil {
-		return fmt.Errorf("failed to parse template %s: %w", name, err)
+		return err
 	}
 
 	r.templates[name] = tmpl
-	
+
 	// Clear cache for this template
 	if r.options.EnableCache {
-		delete(r.cache, name)
+		r.clearCacheFor(name)
 	}
-	
+
 	return nil
 }
 
@@ -143,38 +168,88 @@ func (r *Renderer) LoadTemplateFromFile(name, filePath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read template file %s: %w", filePath, err)
 	}
-	
+
 	return r.LoadTemplate(name, string(content))
 }
 
-// LoadTemplatesFromDir loads all .mjml files from a directory
+// LoadTemplatesFromDir loads all .mjml files from a directory, along with the
+// .txt companions next to them used for the plain-text part. Partials and layouts in
+// PartialDirs are loaded first and shared by every template.
 func (r *Renderer) LoadTemplatesFromDir(dir string) error {
+	if err := r.LoadPartialsFromDir(dir); err != nil {
+		return err
+	}
+
 	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		
-		if d.IsDir() || !strings.HasSuffix(path, ".mjml") {
+
+		if d.IsDir() {
+			if path != dir && IsPartialDir(d.Name()) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		
+		if isTextCompanion(path) {
+			return r.LoadTextTemplateFromFile(strings.TrimSuffix(filepath.Base(path), ".txt"), path)
+		}
+		if !strings.HasSuffix(path, ".mjml") {
+			return nil
+		}
+
 		// Use filename without extension as template name
 		name := strings.TrimSuffix(filepath.Base(path), ".mjml")
 		return r.LoadTemplateFromFile(name, path)
 	})
 }
 
+// LoadPartialsFromDir loads only the partials and layouts in the PartialDirs of
+// a directory, for when the templates themselves come from elsewhere.
+func (r *Renderer) LoadPartialsFromDir(dir string) error {
+	partials, err := loadPartials(dir, r.funcs)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.partials = partials
+	r.mu.Unlock()
+	return nil
+}
+
 // ReplaceTemplatesFromDir atomically replaces all templates by loading from a
 // directory. This holds the write lock for the entire operation so no requests
 // see a partially-loaded state.
 func (r *Renderer) ReplaceTemplatesFromDir(dir string) error {
 	newTemplates := make(map[string]*template.Template)
+	newTexts := make(map[string]*texttemplate.Template)
+
+	partials, err := loadPartials(dir, r.funcs)
+	if err != nil {
+		return err
+	}
 
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
-		if d.IsDir() || !strings.HasSuffix(path, ".mjml") {
+		if d.IsDir() {
+			if path != dir && IsPartialDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isTextCompanion(path) {
+			name := strings.TrimSuffix(filepath.Base(path), ".txt")
+			tmpl, err := parseTextFile(r.funcs, name, path)
+			if err != nil {
+				return err
+			}
+			newTexts[name] = tmpl
+			return nil
+		}
+		if !strings.HasSuffix(path, ".mjml") {
 			return nil
 		}
 
@@ -184,9 +259,9 @@ func (r *Renderer) ReplaceTemplatesFromDir(dir string) error {
 			return fmt.Errorf("failed to read template file %s: %w", path, err)
 		}
 
-		tmpl, err := template.New(name).Parse(string(content))
+		tmpl, err := parse(partials, r.funcs, name, string(content))
 		if err != nil {
-			return fmt.Errorf("failed to parse template %s: %w", name, err)
+			return err
 		}
 
 		newTemplates[name] = tmpl
@@ -198,6 +273,8 @@ func (r *Renderer) ReplaceTemplatesFromDir(dir string) error {
 
 	r.mu.Lock()
 	r.templates = newTemplates
+	r.texts = newTexts
+	r.partials = partials
 	r.cache = make(map[string]string)
 	r.mu.Unlock()
 
@@ -206,22 +283,29 @@ func (r *Renderer) ReplaceTemplatesFromDir(dir string) error {
 
 // RenderTemplate renders a template with the given data to HTML
 func (r *Renderer) RenderTemplate(name string, data any) (string, error) {
+	return r.renderTemplate(name, data, r.options.EnableCache)
+}
+
+// renderTemplate renders a template to HTML, reading and filling the
+// render cache when cache is set.
+func (r *Renderer) renderTemplate(name string, data any, cache bool) (string, error) {
 	r.mu.RLock()
 	tmpl, exists := r.templates[name]
 	r.mu.RUnlock()
-	
+
 	if !exists {
 		return "", fmt.Errorf("template %s not found", name)
 	}
 
 	// Create deterministic cache key based on template name and data content
-	cacheKey, err := r.createCacheKey(name, data)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cache key for template %s: %w", name, err)
-	}
-	
-	// Check cache if enabled
-	if r.options.EnableCache {
+	var cacheKey string
+	if cache {
+		key, err := r.createCacheKey(name, data)
+		if err != nil {
+			return "", fmt.Errorf("failed to create cache key for template %s: %w", name, err)
+		}
+		cacheKey = key
+
 		r.mu.RLock()
 		if cached, found := r.cache[cacheKey]; found {
 			r.mu.RUnlock()
@@ -239,13 +323,13 @@ func (r *Renderer) RenderTemplate(name string, data any) (string, error) {
 	mjmlContent := mjmlBuf.String()
 
 	// Convert MJML to HTML
-	html, err := r.renderMJML(mjmlContent)
+	html, err := r.renderMJML(mjmlContent, cache)
 	if err != nil {
 		return "", fmt.Errorf("failed to render MJML for template %s: %w", name, err)
 	}
 
 	// Cache result if enabled
-	if r.options.EnableCache {
+	if cache {
 		r.mu.Lock()
 		r.cache[cacheKey] = html
 		r.mu.Unlock()
@@ -254,20 +338,149 @@ func (r *Renderer) RenderTemplate(name string, data any) (string, error) {
 	return html, nil
 }
 
+// Render renders a template and returns the HTML together with its
+// plain-text alternative and subject. The text comes from the template's
+// .txt companion when it has one, otherwise it is derived from the HTML.
+func (r *Renderer) Render(name string, data any) (*Result, error) {
+	html, err := r.RenderTemplate(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return r.ResultFor(name, html, data)
+}
+
+// RenderUncached is Render without the render cache, for data unique to a
+// single email, such as a recipient's own unsubscribe link, which would
+// only fill the cache with entries that are never read again.
+func (r *Renderer) RenderUncached(name string, data any) (*Result, error) {
+	html, err := r.renderTemplate(name, data, false)
+	if err != nil {
+		return nil, err
+	}
+	return r.ResultFor(name, html, data)
+}
+
 // RenderString renders MJML content directly to HTML
 func (r *Renderer) RenderString(mjmlContent string) (string, error) {
-	return r.renderMJML(mjmlContent)
+	return r.renderMJML(mjmlContent, r.options.EnableCache)
 }
 
-// renderMJML converts MJML content to HTML using gomjml
-func (r *Renderer) renderMJML(mjmlContent string) (string, error) {
+// RenderContent renders template content with the given data without loading
+// it into the renderer. Used for previews and historical template versions.
+func (r *Renderer) RenderContent(name, content string, data any) (string, error) {
+	tmpl, err := parse(r.sharedPartials(), r.funcs, name, content)
+	if err != nil {
+		return "", err
+	}
+
+	var mjmlBuf bytes.Buffer
+	if err := tmpl.Execute(&mjmlBuf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", name, err)
+	}
+
+	html, err := r.renderMJML(mjmlBuf.String(), r.options.EnableCache)
+	if err != nil {
+		return "", fmt.Errorf("failed to render MJML for template %s: %w", name, err)
+	}
+
+	return html, nil
+}
+
+// CheckTemplate reports whether content parses as a template without
+// loading it into the renderer.
+func (r *Renderer) CheckTemplate(name, content string) error {
+	_, err := parse(r.sharedPartials(), r.funcs, name, content)
+	return err
+}
+
+// parse parses template content into a new template set. Caller must hold r.mu.
+func (r *Renderer) parse(name, content string) (*template.Template, error) {
+	return parse(r.partials, r.funcs, name, content)
+}
+
+// sharedPartials returns the current partials set.
+func (r *Renderer) sharedPartials() *template.Template {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.partials
+}
+
+// parse parses template content into a copy of the partials set, so each
+// template can override layout blocks without affecting the others.
+func parse(partials *template.Template, funcs template.FuncMap, name, content string) (*template.Template, error) {
+	tmpl, err := parseSet(partials, funcs, name, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+func parseSet(partials *template.Template, funcs template.FuncMap, name, content string) (*template.Template, error) {
+	if partials == nil {
+		return template.New(name).Funcs(funcs).Parse(content)
+	}
+
+	set, err := partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return set.New(name).Parse(content)
+}
+
+// loadPartials parses every .mjml file in the PartialDirs of dir into one
+// template set. Each file is available under its base name, along with any
+// templates it defines. Returns nil when there are no partials.
+func loadPartials(dir string, funcs template.FuncMap) (*template.Template, error) {
+	var partials *template.Template
+
+	for _, sub := range PartialDirs {
+		root := filepath.Join(dir, sub)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".mjml") {
+				return nil
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read partial %s: %w", path, err)
+			}
+
+			name := strings.TrimSuffix(filepath.Base(path), ".mjml")
+			if partials == nil {
+				partials = template.New(name).Funcs(funcs)
+			} else {
+				partials = partials.New(name)
+			}
+			if _, err := partials.Parse(string(content)); err != nil {
+				return fmt.Errorf("failed to parse partial %s: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return partials, nil
+}
+
+// renderMJML converts MJML content to HTML using gomjml, with its AST cache
+// when cache is set
+func (r *Renderer) renderMJML(mjmlContent string, cache bool) (string, error) {
 	var mjmlOpts []mjml.RenderOption
-	
+
 	if r.options.EnableDebug {
 		mjmlOpts = append(mjmlOpts, mjml.WithDebugTags(true))
 	}
-	
-	if r.options.EnableCache {
+
+	if cache {
 		mjmlOpts = append(mjmlOpts, mjml.WithCache())
 	}
 
@@ -283,7 +496,7 @@ func (r *Renderer) renderMJML(mjmlContent string) (string, error) {
 func (r *Renderer) ListTemplates() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	names := make([]string, 0, len(r.templates))
 	for name := range r.templates {
 		names = append(names, name)
@@ -295,7 +508,7 @@ func (r *Renderer) ListTemplates() []string {
 func (r *Renderer) HasTemplate(name string) bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	_, exists := r.templates[name]
 	return exists
 }
@@ -304,15 +517,21 @@ func (r *Renderer) HasTemplate(name string) bool {
 func (r *Renderer) RemoveTemplate(name string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
+
 	delete(r.templates, name)
-	
+	delete(r.texts, name)
+
 	// Clear cache entries for this template
 	if r.options.EnableCache {
-		for key := range r.cache {
-			if strings.HasPrefix(key, name+"_") {
-				delete(r.cache, key)
-			}
+		r.clearCacheFor(name)
+	}
+}
+
+// clearCacheFor drops cached HTML for a template. Caller must hold r.mu.
+func (r *Renderer) clearCacheFor(name string) {
+	for key := range r.cache {
+		if strings.HasPrefix(key, name+"_") {
+			delete(r.cache, key)
 		}
 	}
 }
@@ -322,10 +541,10 @@ func (r *Renderer) ClearCache() {
 	if !r.options.EnableCache {
 		return
 	}
-	
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
+
 	r.cache = make(map[string]string)
 }
 
@@ -334,10 +553,10 @@ func (r *Renderer) GetCacheSize() int {
 	if !r.options.EnableCache {
 		return 0
 	}
-	
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	return len(r.cache)
 }
 
@@ -348,13 +567,13 @@ func (r *Renderer) createCacheKey(name string, data any) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize data for caching: %w", err)
 	}
-	
+
 	// Create hash of template name + data content
 	hasher := sha256.New()
 	hasher.Write([]byte(name))
 	hasher.Write(dataBytes)
 	hash := fmt.Sprintf("%x", hasher.Sum(nil))
-	
+
 	return fmt.Sprintf("%s_%s", name, hash[:16]), nil // Use first 16 chars of hash
 }
 
@@ -363,7 +582,7 @@ func (r *Renderer) LoadFont(family string, weight int) error {
 	if !r.options.EnableFonts || r.fontManager == nil {
 		return fmt.Errorf("font management is disabled")
 	}
-	
+
 	return r.fontManager.Cache(family, weight)
 }
 
@@ -433,7 +652,7 @@ func (r *Renderer) ListCachedFonts() []font.FontInfo {
 	if !r.options.EnableFonts || r.fontManager == nil {
 		return nil
 	}
-	
+
 	return r.fontManager.List()
 }
 
@@ -442,6 +661,6 @@ func (r *Renderer) IsFontCached(family string, weight int) bool {
 	if !r.options.EnableFonts || r.fontManager == nil {
 		return false
 	}
-	
+
 	return r.fontManager.Available(family, weight)
-}
\ No newline at end of file
+}
@@ -2,6 +2,7 @@ package mjml
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -21,23 +22,23 @@ func TestRendererOptions(t *testing.T) {
 		WithValidation(false),
 		WithFonts(true),
 	)
-	
+
 	if renderer == nil {
 		t.Fatal("NewRenderer with options returned nil")
 	}
-	
+
 	if !renderer.options.EnableCache {
 		t.Error("Cache option not set")
 	}
-	
+
 	if !renderer.options.EnableDebug {
 		t.Error("Debug option not set")
 	}
-	
+
 	if renderer.options.EnableValidation {
 		t.Error("Validation should be disabled")
 	}
-	
+
 	if !renderer.options.EnableFonts {
 		t.Error("Fonts option not set")
 	}
@@ -45,7 +46,7 @@ func TestRendererOptions(t *testing.T) {
 
 func TestLoadTemplateFromFile(t *testing.T) {
 	renderer := NewRenderer()
-	
+
 	// Create a temporary MJML template
 	content := `<mjml>
 		<mj-head>
@@ -59,24 +60,24 @@ func TestLoadTemplateFromFile(t *testing.T) {
 			</mj-section>
 		</mj-body>
 	</mjml>`
-	
+
 	tmpFile, err := os.CreateTemp("", "test_template_*.mjml")
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.Remove(tmpFile.Name())
-	
+
 	_, err = tmpFile.WriteString(content)
 	if err != nil {
 		t.Fatal(err)
 	}
 	tmpFile.Close()
-	
+
 	err = renderer.LoadTemplateFromFile("test", tmpFile.Name())
 	if err != nil {
 		t.Fatalf("LoadTemplateFromFile failed: %v", err)
 	}
-	
+
 	if !renderer.HasTemplate("test") {
 		t.Error("Template was not loaded")
 	}
@@ -84,7 +85,7 @@ func TestLoadTemplateFromFile(t *testing.T) {
 
 func TestLoadTemplatesFromFiles(t *testing.T) {
 	renderer := NewRenderer()
-	
+
 	// Test loading from template directory
 	err := renderer.LoadTemplatesFromDir("templates")
 	if err != nil {
@@ -92,7 +93,7 @@ func TestLoadTemplatesFromFiles(t *testing.T) {
 		// This is expected in unit tests - templates are in pkg/mjml/templates
 		return
 	}
-	
+
 	templates := renderer.ListTemplates()
 	if len(templates) == 0 {
 		t.Error("No templates loaded from directory")
@@ -101,7 +102,7 @@ func TestLoadTemplatesFromFiles(t *testing.T) {
 
 func TestRenderTemplate(t *testing.T) {
 	renderer := NewRenderer()
-	
+
 	// Load a simple template
 	renderer.LoadTemplate("simple", `<mjml>
 		<mj-head><mj-title>{{.Subject}}</mj-title></mj-head>
@@ -113,19 +114,19 @@ func TestRenderTemplate(t *testing.T) {
 			</mj-section>
 		</mj-body>
 	</mjml>`)
-	
+
 	testData := TestData()
 	data := testData["simple"]
-	
+
 	html, err := renderer.RenderTemplate("simple", data)
 	if err != nil {
 		t.Fatalf("RenderTemplate failed: %v", err)
 	}
-	
+
 	if html == "" {
 		t.Error("RenderTemplate returned empty HTML")
 	}
-	
+
 	if !strings.Contains(html, "<!doctype html>") {
 		t.Error("Generated HTML doesn't contain DOCTYPE")
 	}
@@ -133,7 +134,7 @@ func TestRenderTemplate(t *testing.T) {
 
 func TestTemplateCache(t *testing.T) {
 	renderer := NewRenderer(WithCache(true))
-	
+
 	renderer.LoadTemplate("cached", `<mjml>
 		<mj-head><mj-title>{{.Subject}}</mj-title></mj-head>
 		<mj-body>
@@ -144,27 +145,27 @@ func TestTemplateCache(t *testing.T) {
 			</mj-section>
 		</mj-body>
 	</mjml>`)
-	
+
 	testData := TestData()
 	data := testData["simple"]
-	
+
 	// First render
 	_, err := renderer.RenderTemplate("cached", data)
 	if err != nil {
 		t.Fatal(err)
 	}
-	
+
 	// Cache should have one entry
 	if renderer.GetCacheSize() != 1 {
 		t.Errorf("Expected cache size 1, got %d", renderer.GetCacheSize())
 	}
-	
+
 	// Second render should use cache
 	_, err = renderer.RenderTemplate("cached", data)
 	if err != nil {
 		t.Fatal(err)
 	}
-	
+
 	// Cache size should still be 1
 	if renderer.GetCacheSize() != 1 {
 		t.Errorf("Expected cache size 1, got %d", renderer.GetCacheSize())
@@ -173,7 +174,7 @@ func TestTemplateCache(t *testing.T) {
 
 func TestEmailDataStructs(t *testing.T) {
 	now := time.Now()
-	
+
 	emailData := EmailData{
 		Name:        "Test User",
 		Email:       "test@example.com",
@@ -183,16 +184,16 @@ func TestEmailDataStructs(t *testing.T) {
 		Title:       "Test Title",
 		Message:     "Test message",
 	}
-	
+
 	if emailData.Name != "Test User" {
 		t.Error("EmailData fields not set correctly")
 	}
-	
+
 	welcomeData := WelcomeEmailData{
 		EmailData:     emailData,
 		ActivationURL: "https://example.com/activate",
 	}
-	
+
 	if welcomeData.ActivationURL != "https://example.com/activate" {
 		t.Error("WelcomeEmailData fields not set correctly")
 	}
@@ -200,19 +201,19 @@ func TestEmailDataStructs(t *testing.T) {
 
 func TestCanonicalTestDataStructures(t *testing.T) {
 	testData := TestData()
-	
+
 	expectedTemplates := []string{"welcome", "reset_password", "notification", "simple", "premium_newsletter"}
-	
+
 	for _, name := range expectedTemplates {
 		data, exists := testData[name]
 		if !exists {
 			t.Errorf("Test data for %s not found", name)
 		}
-		
+
 		if data == nil {
 			t.Errorf("Test data for %s is nil", name)
 		}
-		
+
 		// Check that data has expected structure based on type
 		switch v := data.(type) {
 		case EmailData:
@@ -233,4 +234,81 @@ func TestCanonicalTestDataStructures(t *testing.T) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestPartialsAndLayouts(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"layouts/base.mjml": `{{define "base"}}<mjml><mj-body>{{block "content" .}}<mj-section><mj-column><mj-text>Default</mj-text></mj-column></mj-section>{{end}}{{template "footer" .}}</mj-body></mjml>{{end}}`,
+		// A partial without a define is available under its file name
+		"_partials/footer.mjml": `<mj-section><mj-column><mj-text>Footer for {{.Name}}</mj-text></mj-column></mj-section>`,
+		"custom.mjml":           `{{define "content"}}<mj-section><mj-column><mj-text>Custom {{.Name}}</mj-text></mj-column></mj-section>{{end}}{{template "base" .}}`,
+		"plain.mjml":            `{{template "base" .}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	renderer := NewRenderer(WithFonts(false))
+	if err := renderer.LoadTemplatesFromDir(dir); err != nil {
+		t.Fatalf("LoadTemplatesFromDir failed: %v", err)
+	}
+
+	if renderer.HasTemplate("footer") || renderer.HasTemplate("base") {
+		t.Error("Partials should not be loaded as templates")
+	}
+	if len(renderer.ListTemplates()) != 2 {
+		t.Errorf("Expected 2 templates, got %v", renderer.ListTemplates())
+	}
+
+	data := map[string]any{"Name": "Ada"}
+
+	// Overriding a block in one template must not leak into another
+	custom, err := renderer.RenderTemplate("custom", data)
+	if err != nil {
+		t.Fatalf("Render custom failed: %v", err)
+	}
+	if !strings.Contains(custom, "Custom Ada") || !strings.Contains(custom, "Footer for Ada") {
+		t.Error("Custom template did not render its block and the shared footer")
+	}
+
+	plain, err := renderer.RenderTemplate("plain", data)
+	if err != nil {
+		t.Fatalf("Render plain failed: %v", err)
+	}
+	if !strings.Contains(plain, "Default") || strings.Contains(plain, "Custom") {
+		t.Error("Plain template should render the layout's default block")
+	}
+
+	// Content loaded later, e.g. from the template store, sees the partials too
+	if err := renderer.LoadTemplate("runtime", `{{template "base" .}}`); err != nil {
+		t.Fatalf("LoadTemplate with layout failed: %v", err)
+	}
+	if _, err := renderer.RenderTemplate("runtime", data); err != nil {
+		t.Errorf("Render runtime template failed: %v", err)
+	}
+
+	// A single brand change in the partial reaches every template on reload
+	footer := filepath.Join(dir, "_partials", "footer.mjml")
+	if err := os.WriteFile(footer, []byte(`<mj-section><mj-column><mj-text>New footer</mj-text></mj-column></mj-section>`), 0644); err != nil {
+		t.Fatalf("Failed to update footer: %v", err)
+	}
+	if err := renderer.ReplaceTemplatesFromDir(dir); err != nil {
+		t.Fatalf("ReplaceTemplatesFromDir failed: %v", err)
+	}
+	for _, name := range []string{"custom", "plain"} {
+		html, err := renderer.RenderTemplate(name, data)
+		if err != nil {
+			t.Fatalf("Render %s after reload failed: %v", name, err)
+		}
+		if !strings.Contains(html, "New footer") {
+			t.Errorf("Template %s did not pick up the updated footer", name)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package mjml
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Result is a rendered email: the HTML body, a plain-text alternative and
+// the subject taken from the template's mj-title.
+type Result struct {
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+	Subject string `json:"subject"`
+}
+
+// NewResult derives the plain-text part and subject from rendered HTML.
+func NewResult(htmlContent string) *Result {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return &Result{HTML: htmlContent}
+	}
+
+	return &Result{
+		HTML:    htmlContent,
+		Text:    nodeText(doc),
+		Subject: subject(doc),
+	}
+}
+
+// ResultFor builds the Result for HTML rendered from the named template.
+// When the template has a .txt companion it is executed with data to produce
+// the plain-text part; otherwise the text is derived from the HTML.
+func (r *Renderer) ResultFor(name, htmlContent string, data any) (*Result, error) {
+	r.mu.RLock()
+	tmpl, ok := r.texts[name]
+	r.mu.RUnlock()
+	if !ok {
+		return NewResult(htmlContent), nil
+	}
+	return resultWithText(name, htmlContent, tmpl, data)
+}
+
+// ResultForText is ResultFor with the text companion given as content,
+// such as that of a historical template version. Empty text derives the
+// plain-text part from the HTML.
+func (r *Renderer) ResultForText(name, htmlContent, text string, data any) (*Result, error) {
+	if text == "" {
+		return NewResult(htmlContent), nil
+	}
+	tmpl, err := parseText(r.funcs, name, text)
+	if err != nil {
+		return nil, err
+	}
+	return resultWithText(name, htmlContent, tmpl, data)
+}
+
+func resultWithText(name, htmlContent string, tmpl *texttemplate.Template, data any) (*Result, error) {
+	result := NewResult(htmlContent)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute text template %s: %w", name, err)
+	}
+	result.Text = strings.TrimSpace(buf.String())
+	return result, nil
+}
+
+// LoadTextTemplate loads a plain-text companion for the template with the
+// given name. It uses text/template, so output is not HTML-escaped.
+func (r *Renderer) LoadTextTemplate(name, content string) error {
+	tmpl, err := parseText(r.funcs, name, content)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.texts[name] = tmpl
+	r.mu.Unlock()
+	return nil
+}
+
+// LoadTemplateWithText loads a template together with its plain-text
+// companion, or without one when text is empty. Both are parsed before
+// either is loaded, so a failure leaves the previous pair in place.
+func (r *Renderer) LoadTemplateWithText(name, content, text string) error {
+	var textTmpl *texttemplate.Template
+	if text != "" {
+		var err error
+		if textTmpl, err = parseText(r.funcs, name, text); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tmpl, err := r.parse(name, content)
+	if err != nil {
+		return err
+	}
+	r.templates[name] = tmpl
+	if textTmpl != nil {
+		r.texts[name] = textTmpl
+	} else {
+		delete(r.texts, name)
+	}
+	if r.options.EnableCache {
+		r.clearCacheFor(name)
+	}
+	return nil
+}
+
+// CheckTextTemplate reports whether content parses as a plain-text
+// companion, without loading it.
+func (r *Renderer) CheckTextTemplate(name, content string) error {
+	_, err := parseText(r.funcs, name, content)
+	return err
+}
+
+// LoadTextTemplateFromFile loads a plain-text companion from a file
+func (r *Renderer) LoadTextTemplateFromFile(name, filePath string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read text template file %s: %w", filePath, err)
+	}
+	return r.LoadTextTemplate(name, string(content))
+}
+
+// HasTextTemplate reports whether a template has a plain-text companion.
+func (r *Renderer) HasTextTemplate(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.texts[name]
+	return ok
+}
+
+// isTextCompanion reports whether path is a .txt file next to a .mjml
+// template of the same name.
+func isTextCompanion(path string) bool {
+	if !strings.HasSuffix(path, ".txt") {
+		return false
+	}
+	_, err := os.Stat(strings.TrimSuffix(path, ".txt") + ".mjml")
+	return err == nil
+}
+
+func parseTextFile(funcs map[string]any, name, path string) (*texttemplate.Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read text template file %s: %w", path, err)
+	}
+	return parseText(funcs, name, string(content))
+}
+
+func parseText(funcs map[string]any, name, content string) (*texttemplate.Template, error) {
+	tmpl, err := texttemplate.New(name).Funcs(funcs).Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// HTMLToText converts rendered email HTML to a readable plain-text version.
+// Links are numbered and listed as footnotes, and data tables are flattened
+// to one line per row.
+func HTMLToText(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	return nodeText(doc)
+}
+
+// subject returns the document title, which gomjml fills from mj-title.
+func subject(doc *html.Node) string {
+	var title string
+	var find func(*html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+			var b strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					b.WriteString(c.Data)
+				}
+			}
+			title = strings.Join(strings.Fields(b.String()), " ")
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	find(doc)
+	return title
+}
+
+func nodeText(doc *html.Node) string {
+	w := &textWriter{}
+	w.walk(doc)
+
+	text := strings.TrimSpace(w.b.String())
+	if len(w.links) == 0 {
+		return text
+	}
+
+	var notes strings.Builder
+	notes.WriteString(text)
+	notes.WriteString("\n\nLinks:")
+	for i, href := range w.links {
+		fmt.Fprintf(&notes, "\n[%d] %s", i+1, href)
+	}
+	return strings.TrimSpace(notes.String())
+}
+
+// textWriter accumulates text with collapsed whitespace and at most one
+// blank line between blocks.
+type textWriter struct {
+	b        strings.Builder
+	newlines int      // trailing newlines already written
+	space    bool     // whitespace pending before the next word
+	links    []string // footnoted URLs, numbered from 1
+}
+
+func (w *textWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+		if hidden(n) {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Head, atom.Style, atom.Script, atom.Title:
+			return
+		case atom.Br:
+			w.lineBreak()
+			return
+		case atom.Hr:
+			w.newline(1)
+			w.write("----------------------------------------")
+			w.newline(1)
+			return
+		case atom.Img:
+			return
+		case atom.A:
+			w.link(n)
+			return
+		case atom.Tr:
+			if isDataRow(n) {
+				w.row(n)
+				return
+			}
+		case atom.Li:
+			w.newline(1)
+			w.write("- ")
+		}
+	}
+
+	block := isBlock(n)
+	if block > 0 {
+		w.newline(block)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	if block > 0 {
+		w.newline(block)
+	}
+}
+
+// link writes the anchor text followed by a footnote reference. Links
+// without text, such as linked images, are written out in full.
+func (w *textWriter) link(n *html.Node) {
+	start := w.b.Len()
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	label := strings.TrimSpace(w.b.String()[start:])
+
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || href == label {
+		return
+	}
+	if label == "" {
+		w.text(href)
+		return
+	}
+	w.text(" [" + strconv.Itoa(w.footnote(href)) + "]")
+}
+
+// footnote returns the number for href, reusing it for repeated links.
+func (w *textWriter) footnote(href string) int {
+	for i, l := range w.links {
+		if l == href {
+			return i + 1
+		}
+	}
+	w.links = append(w.links, href)
+	return len(w.links)
+}
+
+// row writes the cells of a data table row on one line.
+func (w *textWriter) row(n *html.Node) {
+	var cells []string
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		cell := &textWriter{links: w.links}
+		cell.walk(c)
+		w.links = cell.links
+		if text := strings.TrimSpace(cell.b.String()); text != "" {
+			cells = append(cells, text)
+		}
+	}
+	if len(cells) == 0 {
+		return
+	}
+
+	w.newline(1)
+	w.write(strings.Join(cells, " | "))
+	w.newline(1)
+}
+
+func (w *textWriter) text(s string) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			w.space = true
+		}
+		return
+	}
+	if strings.TrimLeftFunc(s, unicode.IsSpace) != s {
+		w.space = true
+	}
+	if w.space && w.newlines == 0 && w.b.Len() > 0 {
+		w.b.WriteByte(' ')
+	}
+	w.write(strings.Join(fields, " "))
+	w.space = strings.TrimRightFunc(s, unicode.IsSpace) != s
+}
+
+func (w *textWriter) write(s string) {
+	w.b.WriteString(s)
+	w.newlines = 0
+	w.space = false
+}
+
+// newline ensures the output ends with at least n newlines.
+func (w *textWriter) newline(n int) {
+	if w.b.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+	w.space = false
+}
+
+// lineBreak always emits a newline, so consecutive <br> tags are kept.
+func (w *textWriter) lineBreak() {
+	if w.newlines < 2 {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+	w.space = false
+}
+
+// isDataRow reports whether a table row holds tabular data rather than
+// layout: it has several cells and none of them contain block content.
+// MJML wraps every section and column in layout tables, which are walked
+// as ordinary blocks instead.
+func isDataRow(tr *html.Node) bool {
+	cells := 0
+	for c := tr.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.DataAtom != atom.Td && c.DataAtom != atom.Th {
+			return false
+		}
+		if hasBlock(c) {
+			return false
+		}
+		cells++
+	}
+	return cells > 1
+}
+
+func hasBlock(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isBlock(c) > 0 || hasBlock(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlock returns the number of newlines to surround an element with.
+func isBlock(n *html.Node) int {
+	if n.Type != html.ElementNode {
+		return 0
+	}
+	switch n.DataAtom {
+	case atom.P, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6,
+		atom.Table, atom.Ul, atom.Ol, atom.Blockquote, atom.Pre:
+		return 2
+	case atom.Div, atom.Tr, atom.Li, atom.Section, atom.Header, atom.Footer:
+		return 1
+	}
+	return 0
+}
+
+// hidden reports elements such as the mj-preview preheader that are not
+// shown to readers.
+func hidden(n *html.Node) bool {
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	return strings.Contains(style, "display:none")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
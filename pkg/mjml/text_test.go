@@ -0,0 +1,123 @@
+package mjml
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewResult(t *testing.T) {
+	renderer := NewRenderer(WithFonts(false))
+
+	content := `<mjml>
+	<mj-head>
+		<mj-title>{{.Subject}}</mj-title>
+		<mj-preview>Hidden preheader</mj-preview>
+	</mj-head>
+	<mj-body>
+		<mj-section>
+			<mj-column>
+				<mj-text>Hello   {{.Name}},<br/>thanks for joining.</mj-text>
+				<mj-button href="https://example.com/start">Get started</mj-button>
+			</mj-column>
+		</mj-section>
+	</mj-body>
+</mjml>`
+
+	if err := renderer.LoadTemplate("welcome", content); err != nil {
+		t.Fatalf("Failed to load template: %v", err)
+	}
+
+	result, err := renderer.Render("welcome", map[string]any{"Name": "Ada", "Subject": "Welcome aboard"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	if result.Subject != "Welcome aboard" {
+		t.Errorf("Expected subject from mj-title, got %q", result.Subject)
+	}
+	if !strings.Contains(result.Text, "Hello Ada,\nthanks for joining.") {
+		t.Errorf("Expected collapsed text with line break, got:\n%s", result.Text)
+	}
+	if !strings.Contains(result.Text, "Get started [1]") || !strings.HasSuffix(result.Text, "Links:\n[1] https://example.com/start") {
+		t.Errorf("Expected button link as footnote, got:\n%s", result.Text)
+	}
+	if strings.Contains(result.Text, "Hidden preheader") {
+		t.Error("Hidden preheader should not appear in text")
+	}
+	if strings.Contains(result.Text, "{") || strings.Contains(result.Text, "<") {
+		t.Errorf("Text contains markup or CSS:\n%s", result.Text)
+	}
+}
+
+func TestHTMLToTextTablesAndLinks(t *testing.T) {
+	renderer := NewRenderer(WithFonts(false))
+
+	html, err := renderer.RenderString(`<mjml><mj-body><mj-section><mj-column>
+		<mj-text>Read the <a href="https://example.com/docs">docs</a> or <a href="https://example.com/docs">guide</a>.</mj-text>
+		<mj-table>
+			<tr><th>Item</th><th>Qty</th><th>Price</th></tr>
+			<tr><td>Widget</td><td>2</td><td><a href="https://example.com/w">$10.00</a></td></tr>
+		</mj-table>
+	</mj-column></mj-section></mj-body></mjml>`)
+	if err != nil {
+		t.Fatalf("RenderString failed: %v", err)
+	}
+
+	text := HTMLToText(html)
+	for _, want := range []string{
+		"Read the docs [1] or guide [1].",
+		"Item | Qty | Price\nWidget | 2 | $10.00 [2]",
+		"Links:\n[1] https://example.com/docs\n[2] https://example.com/w",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in text, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestTextCompanion(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"receipt.mjml": `<mjml><mj-body><mj-section><mj-column><mj-text>Hi {{.Name}}</mj-text></mj-column></mj-section></mj-body></mjml>`,
+		"receipt.txt":  "Hi {{.Name}} & co,\nyou paid {{.Total | money \"USD\"}}.\n",
+		"README.txt":   "Not a template {{",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	renderer := NewRenderer(WithFonts(false))
+	if err := renderer.LoadTemplatesFromDir(dir); err != nil {
+		t.Fatalf("LoadTemplatesFromDir failed: %v", err)
+	}
+	if !renderer.HasTextTemplate("receipt") {
+		t.Fatal("Text companion was not loaded")
+	}
+	if renderer.HasTextTemplate("README") {
+		t.Error("A .txt file without a matching .mjml should not be loaded")
+	}
+
+	result, err := renderer.Render("receipt", map[string]any{"Name": "Ada", "Total": 5})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.Text != "Hi Ada & co,\nyou paid $5.00." {
+		t.Errorf("Expected text from companion template, got %q", result.Text)
+	}
+
+	if err := renderer.ReplaceTemplatesFromDir(dir); err != nil {
+		t.Fatalf("ReplaceTemplatesFromDir failed: %v", err)
+	}
+	if !renderer.HasTextTemplate("receipt") {
+		t.Error("Text companion was dropped by ReplaceTemplatesFromDir")
+	}
+
+	renderer.RemoveTemplate("receipt")
+	if renderer.HasTextTemplate("receipt") {
+		t.Error("RemoveTemplate should remove the text companion")
+	}
+}
@@ -0,0 +1,120 @@
+package mjml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/preslavrachev/gomjml/mjml"
+)
+
+// Validation stages
+const (
+	StageTemplate = "template" // html/template parse or execution
+	StageMJML     = "mjml"     // gomjml compilation
+)
+
+// TemplateError describes a single problem found while validating a template.
+// For the mjml stage, Line refers to the MJML produced by executing the template.
+type TemplateError struct {
+	Stage   string `json:"stage"`
+	Line    int    `json:"line,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e TemplateError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s: line %d: %s", e.Stage, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Stage, e.Message)
+}
+
+// ValidationError is returned by Validate and lists every problem found.
+type ValidationError struct {
+	Name   string
+	Errors []TemplateError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, te := range e.Errors {
+		msgs[i] = te.Error()
+	}
+	return fmt.Sprintf("template %s is invalid: %s", e.Name, strings.Join(msgs, "; "))
+}
+
+// templateErrRe matches html/template errors such as
+// "template: welcome:12: unexpected EOF" or "template: welcome:3:8: executing ...".
+var templateErrRe = regexp.MustCompile(`^(?:html/)?template: [^:]*:(\d+)(?::\d+)?: (.*)$`)
+
+// Validate parses content as a template, executes it with data and compiles
+// the result with gomjml. Problems are returned as a *ValidationError. When
+// data is nil the template is executed with an empty map, and execution
+// errors caused by the missing data are not reported.
+func (r *Renderer) Validate(name, content string, data any) error {
+	tmpl, err := parseSet(r.sharedPartials(), r.funcs, name, content)
+	if err != nil {
+		return &ValidationError{Name: name, Errors: []TemplateError{templateError(err)}}
+	}
+
+	strict := data != nil
+	if data == nil {
+		data = map[string]any{}
+	}
+
+	var mjmlBuf bytes.Buffer
+	if err := tmpl.Execute(&mjmlBuf, data); err != nil {
+		if !strict {
+			return nil
+		}
+		return &ValidationError{Name: name, Errors: []TemplateError{templateError(err)}}
+	}
+
+	if _, err := r.renderMJML(mjmlBuf.String(), r.options.EnableCache); err != nil {
+		return &ValidationError{Name: name, Errors: mjmlErrors(err)}
+	}
+
+	return nil
+}
+
+func templateError(err error) TemplateError {
+	te := TemplateError{Stage: StageTemplate, Message: err.Error()}
+
+	var escErr *template.Error
+	if errors.As(err, &escErr) && escErr.Line > 0 {
+		te.Line = escErr.Line
+		te.Message = escErr.Description
+		return te
+	}
+
+	if m := templateErrRe.FindStringSubmatch(err.Error()); m != nil {
+		te.Line, _ = strconv.Atoi(m[1])
+		te.Message = m[2]
+	}
+	return te
+}
+
+func mjmlErrors(err error) []TemplateError {
+	var compileErr mjml.Error
+	if errors.As(err, &compileErr) && len(compileErr.Details) > 0 {
+		errs := make([]TemplateError, len(compileErr.Details))
+		for i, d := range compileErr.Details {
+			errs[i] = TemplateError{Stage: StageMJML, Line: d.Line, Tag: d.TagName, Message: d.Message}
+		}
+		return errs
+	}
+
+	te := TemplateError{Stage: StageMJML, Message: strings.TrimPrefix(err.Error(), "gomjml render failed: ")}
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		te.Line = syntaxErr.Line
+		te.Message = syntaxErr.Msg
+	}
+	return []TemplateError{te}
+}
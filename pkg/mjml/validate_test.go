@@ -0,0 +1,79 @@
+package mjml
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	renderer := NewRenderer(WithFonts(false))
+
+	valid := `<mjml>
+	<mj-body>
+		<mj-section>
+			<mj-column>
+				<mj-text>Hello {{.Name}}</mj-text>
+			</mj-column>
+		</mj-section>
+	</mj-body>
+</mjml>`
+
+	if err := renderer.Validate("valid", valid, nil); err != nil {
+		t.Fatalf("Expected valid template, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		data    any
+		stage   string
+		line    int
+	}{
+		{
+			name:    "template syntax",
+			content: "<mjml>\n<mj-body>\n{{.Name</mj-body>\n</mjml>",
+			stage:   StageTemplate,
+			line:    3,
+		},
+		{
+			name:    "template execution",
+			content: "<mjml>\n<mj-body>{{.User.Name}}</mj-body>\n</mjml>",
+			data:    struct{ Name string }{"Ada"},
+			stage:   StageTemplate,
+			line:    2,
+		},
+		{
+			name:    "invalid attribute",
+			content: "<mjml>\n<mj-body>\n<mj-section bogus=\"1\"></mj-section>\n</mj-body>\n</mjml>",
+			stage:   StageMJML,
+			line:    3,
+		},
+		{
+			name:    "malformed markup",
+			content: "<mjml>\n<mj-body>\n<mj-section>\n</mj-body>\n</mjml>",
+			stage:   StageMJML,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := renderer.Validate("broken", tt.content, tt.data)
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if len(verr.Errors) == 0 {
+				t.Fatal("ValidationError has no details")
+			}
+
+			got := verr.Errors[0]
+			if got.Stage != tt.stage {
+				t.Errorf("Expected stage %q, got %q (%s)", tt.stage, got.Stage, got.Message)
+			}
+			if tt.line > 0 && got.Line != tt.line {
+				t.Errorf("Expected line %d, got %d (%s)", tt.line, got.Line, got.Message)
+			}
+		})
+	}
+}
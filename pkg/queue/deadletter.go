@@ -0,0 +1,245 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Failure is one failed delivery attempt of an email.
+type Failure struct {
+	Attempt  int       `json:"attempt"`
+	Class    string    `json:"class"` // transient, throttled or permanent
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetter is an email that delivery gave up on, kept with its queued
+// payload and failure history so it can be inspected and requeued.
+type DeadLetter struct {
+	Job      *EmailJob       `json:"job"`
+	Class    string          `json:"class"`
+	Error    string          `json:"error"`
+	Attempts int             `json:"attempts"`
+	FailedAt time.Time       `json:"failed_at"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	History  []*Failure      `json:"history,omitempty"`
+}
+
+// DeadLetterFilter selects dead letters. Empty fields match everything.
+type DeadLetterFilter struct {
+	IDs      []string
+	Class    string
+	Template string
+	Tenant   string
+	Limit    int // 0 for no limit
+}
+
+// RecordFailure adds a failed attempt to the failure history of an email.
+func (q *Queue) RecordFailure(ctx context.Context, id string, attempt int, class string, err error) error {
+	_, dbErr := q.db.ExecContext(ctx, `
+		INSERT INTO email_failures (email_id, attempt, class, error) VALUES (?, ?, ?, ?)
+	`, id, attempt, class, nullError(err))
+	return dbErr
+}
+
+// Failures returns the failure history of an email, oldest first.
+func (q *Queue) Failures(ctx context.Context, id string) ([]*Failure, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT attempt, class, error, failed_at FROM email_failures
+		WHERE email_id = ? ORDER BY rowid
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []*Failure
+	for rows.Next() {
+		var f Failure
+		var errStr sql.NullString
+		if err := rows.Scan(&f.Attempt, &f.Class, &errStr, &f.FailedAt); err != nil {
+			return nil, err
+		}
+		f.Error = errStr.String
+		failures = append(failures, &f)
+	}
+	return failures, rows.Err()
+}
+
+// DeadLetter moves an email that delivery gave up on into the dead-letter
+// store, keeping a copy of its queued payload.
+func (q *Queue) DeadLetter(ctx context.Context, id, class string, err error) error {
+	job, dbErr := q.GetStatus(ctx, id)
+	if dbErr != nil {
+		return dbErr
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	body, dbErr := q.payload(ctx, job)
+	if dbErr != nil {
+		return dbErr
+	}
+
+	_, dbErr = q.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO dead_letters (email_id, payload, class, error, attempts)
+		VALUES (?, ?, ?, ?, ?)
+	`, id, string(body), class, nullError(err), job.Attempts)
+	return dbErr
+}
+
+// DeadLetters returns the dead letters matching filter, most recent first,
+// without their payload and history.
+func (q *Queue) DeadLetters(ctx context.Context, filter DeadLetterFilter) ([]*DeadLetter, error) {
+	where, args := filter.where()
+	query := `
+		SELECT d.class, d.error, d.attempts, d.created_at, ` + qualify("e", emailColumns) + `
+		FROM dead_letters d JOIN emails e ON e.id = d.email_id
+	` + where + ` ORDER BY d.created_at DESC, d.rowid DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*DeadLetter
+	for rows.Next() {
+		var d DeadLetter
+		var errStr sql.NullString
+		job, err := scanEmail(prefixScanner{rows, []any{&d.Class, &errStr, &d.Attempts, &d.FailedAt}})
+		if err != nil {
+			return nil, err
+		}
+		d.Job = job
+		d.Error = errStr.String
+		letters = append(letters, &d)
+	}
+	return letters, rows.Err()
+}
+
+// GetDeadLetter returns the dead letter of an email with its payload and
+// failure history, or nil when the email is not dead-lettered.
+func (q *Queue) GetDeadLetter(ctx context.Context, id string) (*DeadLetter, error) {
+	letters, err := q.DeadLetters(ctx, DeadLetterFilter{IDs: []string{id}})
+	if err != nil || len(letters) == 0 {
+		return nil, err
+	}
+	d := letters[0]
+
+	var payload string
+	if err := q.db.QueryRowContext(ctx, `SELECT payload FROM dead_letters WHERE email_id = ?`, id).Scan(&payload); err != nil {
+		return nil, fmt.Errorf("load payload: %w", err)
+	}
+	d.Payload = json.RawMessage(payload)
+
+	if d.History, err = q.Failures(ctx, id); err != nil {
+		return nil, fmt.Errorf("load failures: %w", err)
+	}
+	return d, nil
+}
+
+// RequeueDeadLetters puts the dead letters matching filter back in the
+// queue, as Retry does, and returns how many were requeued. It carries on
+// past emails that cannot be requeued and returns their errors joined.
+func (q *Queue) RequeueDeadLetters(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	letters, err := q.DeadLetters(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	var errs []error
+	for _, d := range letters {
+		if err := q.Retry(ctx, d.Job.ID); err != nil {
+			errs = append(errs, fmt.Errorf("requeue %s: %w", d.Job.ID, err))
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// PurgeDeadLetters discards the dead letters matching filter and returns
+// how many were removed. The emails themselves stay failed.
+func (q *Queue) PurgeDeadLetters(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	letters, err := q.DeadLetters(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, d := range letters {
+		if err := q.removeDeadLetter(ctx, d.Job.ID); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// removeDeadLetter deletes the dead letter of an email, if any.
+func (q *Queue) removeDeadLetter(ctx context.Context, id string) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE email_id = ?`, id); err != nil {
+		return fmt.Errorf("remove dead letter: %w", err)
+	}
+	return nil
+}
+
+// prefixScanner scans leading columns into dest before passing the rest
+// of a row on.
+type prefixScanner struct {
+	scanner
+	dest []any
+}
+
+func (s prefixScanner) Scan(dest ...any) error {
+	return s.scanner.Scan(append(s.dest, dest...)...)
+}
+
+// qualify prefixes each column in a comma-separated list with a table alias.
+func qualify(alias, columns string) string {
+	cols := strings.Split(columns, ",")
+	for i, c := range cols {
+		cols[i] = alias + "." + strings.TrimSpace(c)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// where returns the WHERE clause and arguments selecting the filter's dead
+// letters, joined to their emails as e.
+func (f DeadLetterFilter) where() (string, []any) {
+	var conds []string
+	var args []any
+	if len(f.IDs) > 0 {
+		conds = append(conds, "d.email_id IN (?"+strings.Repeat(", ?", len(f.IDs)-1)+")")
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+	if f.Class != "" {
+		conds = append(conds, "d.class = ?")
+		args = append(args, f.Class)
+	}
+	if f.Template != "" {
+		conds = append(conds, "e.template_slug = ?")
+		args = append(args, f.Template)
+	}
+	if f.Tenant != "" {
+		conds = append(conds, "e.tenant = ?")
+		args = append(args, f.Tenant)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
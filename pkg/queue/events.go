@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types recorded in an email's lifecycle.
+const (
+	EventQueued       = "queued"     // enqueued, or put back in the queue
+	EventRendered     = "rendered"   // template rendered for an attempt
+	EventProcessing   = "processing" // picked up by a worker
+	EventDeferred     = "deferred"   // will be tried again later
+	EventSent         = "sent"       // accepted by a provider for some recipients
+	EventFailed       = "failed"     // delivery gave up on some or all recipients
+	EventCancelled    = "cancelled"
+	EventSuppressed   = "suppressed" // recipients skipped as suppressed
+	EventBounced      = "bounced"
+	EventOpened       = "opened"
+	EventClicked      = "clicked"
+	EventUnsubscribed = "unsubscribed" // a recipient opted out through its unsubscribe link
+)
+
+// Event is an entry in the lifecycle log of an email.
+type Event struct {
+	ID        string         `json:"id"`
+	EmailID   string         `json:"email_id"`
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// RecordEvent adds an event to the lifecycle log of an email and passes it
+// to the listeners registered with OnEvent.
+func (q *Queue) RecordEvent(ctx context.Context, id, eventType string, details map[string]any) error {
+	var detailsJSON sql.NullString
+	if len(details) > 0 {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("marshal event details: %w", err)
+		}
+		detailsJSON = sql.NullString{String: string(b), Valid: true}
+	}
+
+	event := &Event{
+		ID:        uuid.New().String(),
+		EmailID:   id,
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Details:   details,
+	}
+	if _, err := q.db.ExecContext(ctx, `
+		INSERT INTO email_events (id, email_id, event_type, timestamp, details) VALUES (?, ?, ?, ?, ?)
+	`, event.ID, id, eventType, event.Timestamp, detailsJSON); err != nil {
+		return err
+	}
+
+	for _, fn := range q.listeners {
+		fn(ctx, event)
+	}
+	return nil
+}
+
+// OnEvent registers fn to be called with every event recorded, after it is
+// stored. It must be called before the queue is used.
+func (q *Queue) OnEvent(fn func(ctx context.Context, event *Event)) {
+	q.listeners = append(q.listeners, fn)
+}
+
+// Events returns the lifecycle log of an email, oldest first.
+func (q *Queue) Events(ctx context.Context, id string) ([]*Event, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT id, email_id, event_type, timestamp, details FROM email_events
+		WHERE email_id = ? ORDER BY rowid
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		var details sql.NullString
+		if err := rows.Scan(&e.ID, &e.EmailID, &e.Type, &e.Timestamp, &details); err != nil {
+			return nil, err
+		}
+		if details.Valid && details.String != "" {
+			if err := json.Unmarshal([]byte(details.String), &e.Details); err != nil {
+				return nil, fmt.Errorf("unmarshal event details: %w", err)
+			}
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"maragu.dev/goqite"
+)
+
+var (
+	// ErrNotFound is returned when an email ID does not exist.
+	ErrNotFound = errors.New("email not found")
+	// ErrStatus is returned when an email's status does not allow an
+	// operation, such as cancelling an email that was already sent.
+	ErrStatus = errors.New("not allowed for email status")
+)
+
+// Cancel stops a pending, scheduled or retrying email from being sent.
+// Recipients not yet sent to are marked cancelled.
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	if err := q.updateIn(ctx, id, []string{"pending", "retry"}, "status = 'cancelled'"); err != nil {
+		return err
+	}
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE email_recipients SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP
+		WHERE email_id = ? AND status IN ('pending', 'retry')
+	`, id); err != nil {
+		return fmt.Errorf("cancel recipients: %w", err)
+	}
+
+	q.RecordEvent(ctx, id, EventCancelled, nil)
+
+	msgID, err := q.messageFor(ctx, id)
+	if err != nil || msgID == "" {
+		return err
+	}
+	return q.queue.Delete(ctx, msgID)
+}
+
+// Reschedule moves the send time of a pending, scheduled or retrying email.
+// A time in the past sends it as soon as possible.
+func (q *Queue) Reschedule(ctx context.Context, id string, at time.Time) error {
+	msgID, err := q.messageFor(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Check before updating, so an email that cannot be moved keeps its
+	// send time
+	if msgID == "" {
+		if err := q.checkStatus(ctx, id, "pending", "retry"); err != nil {
+			return err
+		}
+		return fmt.Errorf("email %s is not in the queue", id)
+	}
+	if err := q.updateIn(ctx, id, []string{"pending", "retry"}, "scheduled_at = ?", at.UTC()); err != nil {
+		return err
+	}
+	q.RecordEvent(ctx, id, EventDeferred, map[string]any{
+		"reason":       "rescheduled",
+		"scheduled_at": at.UTC().Format(time.RFC3339),
+	})
+	return q.queue.Extend(ctx, msgID, max(time.Until(at), 0))
+}
+
+// Retry puts a failed, partially sent or cancelled email back in the
+// queue with its attempts reset, removing it from the dead-letter store.
+// Only failed and cancelled recipients are sent to again; those sent to,
+// bounced or suppressed are not.
+func (q *Queue) Retry(ctx context.Context, id string) error {
+	job, err := q.GetStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	body, err := q.payload(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	if err := q.updateIn(ctx, id, []string{"failed", "partial", "cancelled"}, "status = 'pending', attempts = 0, error = NULL"); err != nil {
+		return err
+	}
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE email_recipients SET status = 'pending', error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE email_id = ? AND status IN ('failed', 'cancelled')
+	`, id); err != nil {
+		return fmt.Errorf("reset recipients: %w", err)
+	}
+
+	if err := q.send(ctx, body, job.Priority, job.ScheduledAt); err != nil {
+		q.UpdateStatus(ctx, id, "failed", err)
+		return err
+	}
+	q.RecordEvent(ctx, id, EventQueued, map[string]any{"retry_of": job.Status})
+	return q.removeDeadLetter(ctx, id)
+}
+
+// updateIn applies set to an email whose status is one of from, or
+// returns ErrNotFound or ErrStatus.
+func (q *Queue) updateIn(ctx context.Context, id string, from []string, set string, args ...any) error {
+	query := `UPDATE emails SET ` + set + `, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+	args = append(args, id)
+	for _, status := range from {
+		args = append(args, status)
+	}
+
+	res, err := q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	job, err := q.GetStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return fmt.Errorf("%w: email %s is %s", ErrStatus, id, job.Status)
+}
+
+// checkStatus returns ErrNotFound or ErrStatus unless an email's status is
+// one of from.
+func (q *Queue) checkStatus(ctx context.Context, id string, from ...string) error {
+	job, err := q.GetStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if !slices.Contains(from, job.Status) {
+		return fmt.Errorf("%w: email %s is %s", ErrStatus, id, job.Status)
+	}
+	return nil
+}
+
+// messageFor returns the ID of the goqite message for an email, or "" when
+// it is not in the queue.
+func (q *Queue) messageFor(ctx context.Context, id string) (goqite.ID, error) {
+	var msgID goqite.ID
+	err := q.db.QueryRowContext(ctx, `
+		SELECT id FROM goqite WHERE queue = ? AND json_extract(CAST(body AS TEXT), '$.id') = ?
+	`, q.name, id).Scan(&msgID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("find queued message: %w", err)
+	}
+	return msgID, nil
+}
+
+// payload returns the queued payload stored for job. Emails stored before
+// payloads were kept are rebuilt from their columns, without custom
+// headers and attachments.
+func (q *Queue) payload(ctx context.Context, job *EmailJob) ([]byte, error) {
+	var payload sql.NullString
+	if err := q.db.QueryRowContext(ctx, `SELECT payload FROM emails WHERE id = ?`, job.ID).Scan(&payload); err != nil {
+		return nil, fmt.Errorf("load payload: %w", err)
+	}
+	if payload.Valid && payload.String != "" {
+		return []byte(payload.String), nil
+	}
+	return json.Marshal(job)
+}
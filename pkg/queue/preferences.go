@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Preferences returns the template categories a recipient chose to get,
+// true, or not, false. Categories they unsubscribed from with a link are
+// false too. Categories they never chose are missing.
+func (q *Queue) Preferences(ctx context.Context, addr string) (map[string]bool, error) {
+	addr = normalizeAddress(addr)
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT category, subscribed FROM preferences WHERE address = ?
+	`, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]bool)
+	for rows.Next() {
+		var category string
+		var subscribed bool
+		if err := rows.Scan(&category, &subscribed); err != nil {
+			return nil, err
+		}
+		prefs[category] = subscribed
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	unsubscribed, err := q.Suppressions(ctx, SuppressionFilter{Address: addr, Reason: SuppressUnsubscribe})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range unsubscribed {
+		if s.Address == addr && s.Category != "" {
+			prefs[s.Category] = false
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreferences records the template categories a recipient chose to
+// get, true, or not, false. Choosing to get a category again lifts an
+// unsubscribe from it; other suppressions, such as bounces, stay.
+func (q *Queue) SetPreferences(ctx context.Context, addr string, prefs map[string]bool) error {
+	addr = normalizeAddress(addr)
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for category, subscribed := range prefs {
+		if category == "" {
+			return fmt.Errorf("preference for %s has no category", addr)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO preferences (address, category, subscribed) VALUES (?, ?, ?)
+			ON CONFLICT (address, category) DO UPDATE SET
+				subscribed = excluded.subscribed,
+				updated_at = CURRENT_TIMESTAMP
+		`, addr, category, subscribed); err != nil {
+			return fmt.Errorf("set preference for %s: %w", category, err)
+		}
+		if subscribed {
+			if _, err := tx.ExecContext(ctx, `
+				DELETE FROM suppressions WHERE address = ? AND category = ? AND reason = ?
+			`, addr, category, SuppressUnsubscribe); err != nil {
+				return fmt.Errorf("resubscribe to %s: %w", category, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// optedOut returns, as suppressions, the preferences of the normalized
+// addresses in addrs that opted out of category.
+func (q *Queue) optedOut(ctx context.Context, addrs []any, category string) ([]*Suppression, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT address, updated_at FROM preferences
+		WHERE address IN (?`+strings.Repeat(", ?", len(addrs)-1)+`)
+		  AND category = ? AND subscribed = 0
+	`, append(append([]any{}, addrs...), category)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Suppression
+	for rows.Next() {
+		s := &Suppression{Category: category, Reason: SuppressPreference, Source: "preferences"}
+		if err := rows.Scan(&s.Address, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
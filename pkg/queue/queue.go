@@ -6,9 +6,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/joeblew999/plat-mjml/pkg/mail"
 	"maragu.dev/goqite"
 )
 
@@ -19,28 +21,90 @@ const (
 	PriorityHigh   = 2 // Password reset, security alerts
 )
 
+var priorityNames = map[string]int{
+	"low":    PriorityLow,
+	"normal": PriorityNormal,
+	"high":   PriorityHigh,
+}
+
+// ParsePriority converts "low", "normal" or "high" to a priority level.
+// An empty string is normal priority.
+func ParsePriority(s string) (int, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	p, ok := priorityNames[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("invalid priority %q: use low, normal or high", s)
+	}
+	return p, nil
+}
+
+// PriorityName returns the name of a priority level.
+func PriorityName(p int) string {
+	for name, level := range priorityNames {
+		if level == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", p)
+}
+
 // EmailJob represents an email to be sent.
 type EmailJob struct {
-	ID           string         `json:"id"`
-	TemplateSlug string         `json:"template_slug"`
-	Recipients   []string       `json:"recipients"`
-	Subject      string         `json:"subject"`
-	Data         map[string]any `json:"data,omitempty"`
-	Status       string         `json:"status"`
-	Priority     int            `json:"priority"`
-	Attempts     int            `json:"attempts"`
-	MaxAttempts  int            `json:"max_attempts"`
-	ScheduledAt  *time.Time     `json:"scheduled_at,omitempty"`
-	Error        string         `json:"error,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
+	ID           string `json:"id"`
+	TemplateSlug string `json:"template_slug"`
+	// TemplateVersion pins the job to a specific template version. When
+	// zero, the live version is used and recorded once the job is rendered.
+	TemplateVersion int            `json:"template_version,omitempty"`
+	Recipients      []string       `json:"recipients"`
+	Cc              []string       `json:"cc,omitempty"`
+	Bcc             []string       `json:"bcc,omitempty"`
+	ReplyTo         string         `json:"reply_to,omitempty"`
+	Subject         string         `json:"subject"`
+	Data            map[string]any `json:"data,omitempty"`
+	Status          string         `json:"status"`
+	Priority        int            `json:"priority"`
+	Attempts        int            `json:"attempts"`
+	MaxAttempts     int            `json:"max_attempts"`
+	ScheduledAt     *time.Time     `json:"scheduled_at,omitempty"`
+	Error           string         `json:"error,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+
+	// Tenant selects the tenant's mail providers. Provider is the provider
+	// the email was last sent through.
+	Tenant   string `json:"tenant,omitempty"`
+	Provider string `json:"provider,omitempty"`
+
+	// IdempotencyKey identifies the request that created the job. A job
+	// enqueued again with the same key, for the same tenant, within the
+	// queue's idempotency window is not queued twice.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Headers and Attachments have no columns of their own in the emails
+	// table; they are kept in the stored payload of the job.
+	Headers     map[string]string `json:"headers,omitempty"`
+	Attachments []mail.Attachment `json:"attachments,omitempty"`
 }
 
+// DefaultIdempotencyWindow is how long idempotency keys are remembered.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// VisibilityTimeout is how long a received job is hidden from other
+// workers. Workers extend it while they process a job, and recipient claims
+// left by a worker that stopped expire after it.
+const VisibilityTimeout = time.Minute
+
 // Queue manages email jobs using goqite.
 type Queue struct {
 	db      *sql.DB
 	queue   *goqite.Queue
 	name    string
 	workers int
+
+	idempotencyWindow time.Duration
+	listeners         []func(context.Context, *Event)
+	category          func(ctx context.Context, slug string) string
 }
 
 // NewQueue creates a new email queue.
@@ -50,17 +114,39 @@ func NewQueue(db *sql.DB, name string, workers int) (*Queue, error) {
 		DB:        db,
 		Name:      name,
 		SQLFlavor: goqite.SQLFlavorSQLite,
+		Timeout:   VisibilityTimeout,
 	})
 
 	return &Queue{
-		db:      db,
-		queue:   q,
-		name:    name,
-		workers: workers,
+		db:                db,
+		queue:             q,
+		name:              name,
+		workers:           workers,
+		idempotencyWindow: DefaultIdempotencyWindow,
 	}, nil
 }
 
-// Enqueue adds an email job to the queue.
+// SetIdempotencyWindow sets how long an idempotency key keeps returning
+// the job it created.
+func (q *Queue) SetIdempotencyWindow(d time.Duration) {
+	q.idempotencyWindow = d
+}
+
+// SetCategoryFunc sets how the category of a template is found, so that
+// suppressions scoped to a category are applied by Enqueue. Without it,
+// only suppressions covering every category are.
+func (q *Queue) SetCategoryFunc(fn func(ctx context.Context, slug string) string) {
+	q.category = fn
+}
+
+// Enqueue adds an email job to the queue. Callers must set Priority; the
+// zero value is PriorityLow.
+//
+// When job has an IdempotencyKey already used within the idempotency
+// window, nothing is queued and the ID of the existing job is returned.
+//
+// Suppressed recipients are not sent to. When every recipient is
+// suppressed, nothing is queued and a *SuppressedError is returned.
 func (q *Queue) Enqueue(ctx context.Context, job EmailJob) (string, error) {
 	if job.ID == "" {
 		job.ID = uuid.New().String()
@@ -68,38 +154,92 @@ func (q *Queue) Enqueue(ctx context.Context, job EmailJob) (string, error) {
 	if job.MaxAttempts == 0 {
 		job.MaxAttempts = 3
 	}
-	if job.Priority == 0 {
-		job.Priority = PriorityNormal
-	}
 	job.CreatedAt = time.Now()
 
+	// A reused idempotency key returns its job, even if its recipients
+	// have been suppressed since
+	existing, err := q.existingJob(ctx, job)
+	if err != nil {
+		return "", fmt.Errorf("check idempotency key: %w", err)
+	}
+	if existing != "" {
+		return existing, nil
+	}
+
+	var category string
+	if q.category != nil {
+		category = q.category(ctx, job.TemplateSlug)
+	}
+	addrs := job.Addresses()
+	suppressed, err := q.Suppressed(ctx, addrs, category)
+	if err != nil {
+		return "", fmt.Errorf("check suppressions: %w", err)
+	}
+	if AllSuppressed(addrs, suppressed) {
+		return "", &SuppressedError{Addresses: addrs}
+	}
+
 	body, err := json.Marshal(job)
 	if err != nil {
 		return "", fmt.Errorf("marshal job: %w", err)
 	}
 
-	// Calculate delay based on scheduled time
-	var delay time.Duration
-	if job.ScheduledAt != nil && job.ScheduledAt.After(time.Now()) {
-		delay = time.Until(*job.ScheduledAt)
+	// The email, its recipients and its queue message are stored together,
+	// so an email is never left without its message or the other way round
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
 	}
+	defer tx.Rollback()
 
-	if err := q.queue.Send(ctx, goqite.Message{
-		Body:     body,
-		Delay:    delay,
-		Priority: job.Priority,
-	}); err != nil {
+	// Keys reused by a concurrent request are caught here
+	id, err := q.storeEmail(ctx, tx, job, body)
+	if err != nil {
+		return "", fmt.Errorf("store email: %w", err)
+	}
+	if id != job.ID {
+		return id, nil
+	}
+	if err := q.queue.SendTx(ctx, tx, message(body, job.Priority, job.ScheduledAt)); err != nil {
 		return "", fmt.Errorf("send to queue: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
 
-	// Also store in emails table for tracking
-	if err := q.storeEmail(ctx, job); err != nil {
-		return "", fmt.Errorf("store email: %w", err)
+	details := map[string]any{
+		"recipients": len(job.Addresses()),
+		"priority":   PriorityName(job.Priority),
+	}
+	if job.ScheduledAt != nil {
+		details["scheduled_at"] = job.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	q.RecordEvent(ctx, job.ID, EventQueued, details)
+	if err := q.suppressRecipients(ctx, job.ID, suppressed); err != nil {
+		return job.ID, err
 	}
 
 	return job.ID, nil
 }
 
+// send adds a job's payload to goqite, delayed until scheduledAt.
+func (q *Queue) send(ctx context.Context, body []byte, priority int, scheduledAt *time.Time) error {
+	if err := q.queue.Send(ctx, message(body, priority, scheduledAt)); err != nil {
+		return fmt.Errorf("send to queue: %w", err)
+	}
+	return nil
+}
+
+// message returns the goqite message for a job's payload, delayed until
+// scheduledAt.
+func message(body []byte, priority int, scheduledAt *time.Time) goqite.Message {
+	var delay time.Duration
+	if scheduledAt != nil && scheduledAt.After(time.Now()) {
+		delay = time.Until(*scheduledAt)
+	}
+	return goqite.Message{Body: body, Delay: delay, Priority: priority}
+}
+
 // Schedule adds an email job to be sent at a specific time.
 func (q *Queue) Schedule(ctx context.Context, job EmailJob, at time.Time) (string, error) {
 	job.ScheduledAt = &at
@@ -129,6 +269,29 @@ func (q *Queue) Extend(ctx context.Context, msg *goqite.Message, d time.Duration
 	return q.queue.Extend(ctx, msg.ID, d)
 }
 
+// Requeue puts a received job back in the queue as a new message, delayed
+// by d. Unlike Extend, the new message starts with no receives, so a job
+// can be deferred any number of times without goqite giving up on it.
+func (q *Queue) Requeue(ctx context.Context, job *EmailJob, msg *goqite.Message, d time.Duration) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := q.queue.SendTx(ctx, tx, goqite.Message{
+		Body:     msg.Body,
+		Delay:    max(d, 0),
+		Priority: job.Priority,
+	}); err != nil {
+		return fmt.Errorf("send to queue: %w", err)
+	}
+	if err := q.queue.DeleteTx(ctx, tx, msg.ID); err != nil {
+		return fmt.Errorf("delete from queue: %w", err)
+	}
+	return tx.Commit()
+}
+
 // Delete removes a message from the queue (job completed).
 func (q *Queue) Delete(ctx context.Context, msg *goqite.Message) error {
 	return q.queue.Delete(ctx, msg.ID)
@@ -137,21 +300,11 @@ func (q *Queue) Delete(ctx context.Context, msg *goqite.Message) error {
 // GetStatus returns the status of an email by ID.
 func (q *Queue) GetStatus(ctx context.Context, id string) (*EmailJob, error) {
 	row := q.db.QueryRowContext(ctx, `
-		SELECT id, template_slug, recipients, subject, data, status,
-		       priority, attempts, max_attempts, scheduled_at, sent_at, error, created_at
+		SELECT `+emailColumns+`
 		FROM emails WHERE id = ?
 	`, id)
 
-	var job EmailJob
-	var recipients, data string
-	var scheduledAt, sentAt sql.NullTime
-	var errStr sql.NullString
-
-	err := row.Scan(
-		&job.ID, &job.TemplateSlug, &recipients, &job.Subject, &data,
-		&job.Status, &job.Priority, &job.Attempts, &job.MaxAttempts,
-		&scheduledAt, &sentAt, &errStr, &job.CreatedAt,
-	)
+	job, err := scanEmail(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -159,38 +312,31 @@ func (q *Queue) GetStatus(ctx context.Context, id string) (*EmailJob, error) {
 		return nil, err
 	}
 
-	if err := json.Unmarshal([]byte(recipients), &job.Recipients); err != nil {
-		return nil, fmt.Errorf("unmarshal recipients: %w", err)
-	}
-	if err := json.Unmarshal([]byte(data), &job.Data); err != nil {
-		return nil, fmt.Errorf("unmarshal data: %w", err)
-	}
-	if errStr.Valid {
-		job.Error = errStr.String
-	}
-	if scheduledAt.Valid {
-		job.ScheduledAt = &scheduledAt.Time
-	}
-
-	return &job, nil
+	return job, nil
 }
 
 // UpdateStatus updates the status of an email.
 func (q *Queue) UpdateStatus(ctx context.Context, id, status string, err error) error {
-	var errStr sql.NullString
-	if err != nil {
-		errStr = sql.NullString{String: err.Error(), Valid: true}
-	}
-
 	_, dbErr := q.db.ExecContext(ctx, `
 		UPDATE emails
-		SET status = ?, error = ?, updated_at = CURRENT_TIMESTAMP,
-		    attempts = attempts + 1
+		SET status = ?, error = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
-	`, status, errStr, id)
+	`, status, nullError(err), id)
 	return dbErr
 }
 
+// RecordAttempt counts a delivery attempt for an email and returns the
+// number of attempts made so far.
+func (q *Queue) RecordAttempt(ctx context.Context, id string) (int, error) {
+	var attempts int
+	err := q.db.QueryRowContext(ctx, `
+		UPDATE emails SET attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+		RETURNING attempts
+	`, id).Scan(&attempts)
+	return attempts, err
+}
+
 // MarkSent marks an email as successfully sent.
 func (q *Queue) MarkSent(ctx context.Context, id, messageID string) error {
 	_, err := q.db.ExecContext(ctx, `
@@ -202,11 +348,28 @@ func (q *Queue) MarkSent(ctx context.Context, id, messageID string) error {
 	return err
 }
 
+// SetTemplateVersion records the template version an email was rendered with.
+func (q *Queue) SetTemplateVersion(ctx context.Context, id string, version int) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE emails SET template_version = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, version, id)
+	return err
+}
+
+// SetProvider records the mail provider an email was sent through.
+func (q *Queue) SetProvider(ctx context.Context, id, provider string) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE emails SET provider = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, provider, id)
+	return err
+}
+
 // List returns jobs from the queue with optional status filter.
 func (q *Queue) List(ctx context.Context, status string, limit int) ([]*EmailJob, error) {
 	query := `
-		SELECT id, template_slug, recipients, subject, data, status,
-		       priority, attempts, max_attempts, scheduled_at, sent_at, error, created_at
+		SELECT ` + emailColumns + `
 		FROM emails
 	`
 	args := []any{}
@@ -227,34 +390,11 @@ func (q *Queue) List(ctx context.Context, status string, limit int) ([]*EmailJob
 
 	var jobs []*EmailJob
 	for rows.Next() {
-		var job EmailJob
-		var recipients, data string
-		var scheduledAt, sentAt sql.NullTime
-		var errStr sql.NullString
-
-		err := rows.Scan(
-			&job.ID, &job.TemplateSlug, &recipients, &job.Subject, &data,
-			&job.Status, &job.Priority, &job.Attempts, &job.MaxAttempts,
-			&scheduledAt, &sentAt, &errStr, &job.CreatedAt,
-		)
+		job, err := scanEmail(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := json.Unmarshal([]byte(recipients), &job.Recipients); err != nil {
-			return nil, fmt.Errorf("unmarshal recipients: %w", err)
-		}
-		if err := json.Unmarshal([]byte(data), &job.Data); err != nil {
-			return nil, fmt.Errorf("unmarshal data: %w", err)
-		}
-		if errStr.Valid {
-			job.Error = errStr.String
-		}
-		if scheduledAt.Valid {
-			job.ScheduledAt = &scheduledAt.Time
-		}
-
-		jobs = append(jobs, &job)
+		jobs = append(jobs, job)
 	}
 
 	return jobs, nil
@@ -283,27 +423,150 @@ func (q *Queue) Stats(ctx context.Context) (map[string]int, error) {
 	return stats, nil
 }
 
-func (q *Queue) storeEmail(ctx context.Context, job EmailJob) error {
+// existingJob returns the ID of the job holding the idempotency key of job
+// within the idempotency window, or "" when there is none. Keys older than
+// the window are released for reuse.
+func (q *Queue) existingJob(ctx context.Context, job EmailJob) (string, error) {
+	if job.IdempotencyKey == "" {
+		return "", nil
+	}
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE emails SET idempotency_key = NULL
+		WHERE idempotency_key = ? AND COALESCE(tenant, '') = ? AND created_at < datetime('now', ?)
+	`, job.IdempotencyKey, job.Tenant, fmt.Sprintf("-%d seconds", int(q.idempotencyWindow.Seconds()))); err != nil {
+		return "", fmt.Errorf("expire idempotency key: %w", err)
+	}
+
+	var id string
+	err := q.db.QueryRowContext(ctx, `
+		SELECT id FROM emails WHERE idempotency_key = ? AND COALESCE(tenant, '') = ?
+	`, job.IdempotencyKey, job.Tenant).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// storeEmail inserts job, its queued payload and its recipients in tx and
+// returns its ID, or the ID of the job that took its idempotency key since
+// existingJob was checked.
+func (q *Queue) storeEmail(ctx context.Context, tx *sql.Tx, job EmailJob, payload []byte) (string, error) {
 	recipients, err := json.Marshal(job.Recipients)
 	if err != nil {
-		return fmt.Errorf("marshal recipients: %w", err)
+		return "", fmt.Errorf("marshal recipients: %w", err)
 	}
 	data, err := json.Marshal(job.Data)
 	if err != nil {
-		return fmt.Errorf("marshal data: %w", err)
+		return "", fmt.Errorf("marshal data: %w", err)
 	}
 
+	// Stored in UTC: the SQLite driver cannot read back unnamed zone offsets
 	var scheduledAt sql.NullTime
 	if job.ScheduledAt != nil {
-		scheduledAt = sql.NullTime{Time: *job.ScheduledAt, Valid: true}
+		scheduledAt = sql.NullTime{Time: job.ScheduledAt.UTC(), Valid: true}
+	}
+	var templateVersion sql.NullInt64
+	if job.TemplateVersion > 0 {
+		templateVersion = sql.NullInt64{Int64: int64(job.TemplateVersion), Valid: true}
 	}
 
-	_, err = q.db.ExecContext(ctx, `
-		INSERT INTO emails (id, template_slug, recipients, subject, data, status,
-		                    priority, attempts, max_attempts, scheduled_at, created_at)
-		VALUES (?, ?, ?, ?, ?, 'pending', ?, 0, ?, ?, CURRENT_TIMESTAMP)
-	`, job.ID, job.TemplateSlug, string(recipients), job.Subject, string(data),
-		job.Priority, job.MaxAttempts, scheduledAt)
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO emails (id, template_slug, template_version, recipients, cc, bcc, reply_to,
+		                    subject, data, status, priority, attempts, max_attempts,
+		                    scheduled_at, tenant, idempotency_key, payload, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', ?, 0, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT DO NOTHING
+	`, job.ID, job.TemplateSlug, templateVersion, string(recipients),
+		addressList(job.Cc), addressList(job.Bcc), nullString(job.ReplyTo),
+		job.Subject, string(data), job.Priority, job.MaxAttempts, scheduledAt,
+		nullString(job.Tenant), nullString(job.IdempotencyKey), string(payload))
+	if err != nil {
+		return "", err
+	}
 
-	return err
+	if n, _ := res.RowsAffected(); n == 0 {
+		var id string
+		err := tx.QueryRowContext(ctx, `
+			SELECT id FROM emails WHERE idempotency_key = ? AND COALESCE(tenant, '') = ?
+		`, job.IdempotencyKey, job.Tenant).Scan(&id)
+		if err != nil {
+			return "", fmt.Errorf("email %s already exists", job.ID)
+		}
+		return id, nil
+	}
+
+	return job.ID, storeRecipients(ctx, tx, job)
+}
+
+// emailColumns lists the emails columns read by scanEmail.
+const emailColumns = `id, template_slug, template_version, recipients, cc, bcc, reply_to,
+		       subject, data, status, priority, attempts, max_attempts,
+		       scheduled_at, sent_at, error, tenant, provider, idempotency_key, created_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEmail(row scanner) (*EmailJob, error) {
+	var job EmailJob
+	var recipients, data string
+	var cc, bcc, replyTo, errStr, tenant, provider, idempotencyKey sql.NullString
+	var scheduledAt, sentAt sql.NullTime
+	var templateVersion sql.NullInt64
+
+	if err := row.Scan(
+		&job.ID, &job.TemplateSlug, &templateVersion, &recipients, &cc, &bcc, &replyTo,
+		&job.Subject, &data, &job.Status, &job.Priority, &job.Attempts, &job.MaxAttempts,
+		&scheduledAt, &sentAt, &errStr, &tenant, &provider, &idempotencyKey, &job.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(recipients), &job.Recipients); err != nil {
+		return nil, fmt.Errorf("unmarshal recipients: %w", err)
+	}
+	if err := json.Unmarshal([]byte(data), &job.Data); err != nil {
+		return nil, fmt.Errorf("unmarshal data: %w", err)
+	}
+	for _, f := range []struct {
+		col  sql.NullString
+		dest *[]string
+	}{{cc, &job.Cc}, {bcc, &job.Bcc}} {
+		if f.col.Valid && f.col.String != "" {
+			if err := json.Unmarshal([]byte(f.col.String), f.dest); err != nil {
+				return nil, fmt.Errorf("unmarshal addresses: %w", err)
+			}
+		}
+	}
+	job.ReplyTo = replyTo.String
+	job.Error = errStr.String
+	job.Tenant = tenant.String
+	job.Provider = provider.String
+	job.IdempotencyKey = idempotencyKey.String
+	if scheduledAt.Valid {
+		job.ScheduledAt = &scheduledAt.Time
+	}
+	job.TemplateVersion = int(templateVersion.Int64)
+
+	return &job, nil
+}
+
+// addressList stores an optional address list as JSON, or NULL when empty.
+func addressList(addrs []string) sql.NullString {
+	if len(addrs) == 0 {
+		return sql.NullString{}
+	}
+	b, _ := json.Marshal(addrs)
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullError(err error) sql.NullString {
+	if err == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: err.Error(), Valid: true}
 }
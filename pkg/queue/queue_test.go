@@ -0,0 +1,549 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+)
+
+func newTestQueue(t *testing.T) *Queue {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	q, err := NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	return q
+}
+
+func TestEnqueueRoundTrip(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	at := time.Date(2030, 1, 1, 10, 0, 0, 0, time.FixedZone("", 2*60*60))
+	id, err := q.Enqueue(ctx, EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"a@example.com"},
+		Cc:           []string{"c@example.com"},
+		Bcc:          []string{"b@example.com"},
+		ReplyTo:      "support@example.com",
+		Subject:      "Hello",
+		Data:         map[string]any{"Name": "Ada"},
+		Priority:     PriorityLow,
+		ScheduledAt:  &at,
+		Tenant:       "acme",
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	job, err := q.GetStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if job == nil {
+		t.Fatal("GetStatus returned nil for enqueued job")
+	}
+
+	if job.Priority != PriorityLow {
+		t.Errorf("Expected low priority to be kept, got %d", job.Priority)
+	}
+	if len(job.Cc) != 1 || len(job.Bcc) != 1 || job.ReplyTo != "support@example.com" {
+		t.Errorf("Addresses not persisted: cc=%v bcc=%v reply-to=%q", job.Cc, job.Bcc, job.ReplyTo)
+	}
+	if job.Tenant != "acme" {
+		t.Errorf("Expected tenant acme, got %q", job.Tenant)
+	}
+	if job.Data["Name"] != "Ada" {
+		t.Errorf("Data not persisted: %v", job.Data)
+	}
+	if job.ScheduledAt == nil || !job.ScheduledAt.Equal(at) {
+		t.Errorf("Expected scheduled_at %v, got %v", at, job.ScheduledAt)
+	}
+
+	jobs, err := q.List(ctx, "pending", 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != id {
+		t.Errorf("Expected enqueued job in pending list, got %d jobs", len(jobs))
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"", PriorityNormal, true},
+		{"low", PriorityLow, true},
+		{"HIGH", PriorityHigh, true},
+		{"urgent", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePriority(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParsePriority(%q) = %d, %v", tt.in, got, err)
+		}
+		if tt.ok && PriorityName(got) != map[int]string{0: "low", 1: "normal", 2: "high"}[got] {
+			t.Errorf("PriorityName(%d) = %q", got, PriorityName(got))
+		}
+	}
+}
+
+func TestRecipientDeliveries(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"a@example.com", "b@example.com"},
+		Bcc:          []string{"a@example.com", "audit@example.com"},
+		Subject:      "Hello",
+		Priority:     PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	recipients, err := q.Recipients(ctx, id)
+	if err != nil {
+		t.Fatalf("Recipients failed: %v", err)
+	}
+	if len(recipients) != 3 || recipients[2].Address != "audit@example.com" || recipients[0].Status != "pending" {
+		t.Fatalf("Expected 3 pending recipients, got %+v", recipients)
+	}
+
+	q.RecordDelivery(ctx, id, "a@example.com", "ses", "msg-1", nil, false)
+	q.RecordDelivery(ctx, id, "b@example.com", "ses", "", errors.New("timeout"), false)
+	q.FailRecipients(ctx, id, errors.New("gave up"))
+
+	recipients, _ = q.Recipients(ctx, id)
+	a, b, audit := recipients[0], recipients[1], recipients[2]
+	if a.Status != "sent" || a.MessageID != "msg-1" || a.Attempts != 1 || a.SentAt == nil {
+		t.Errorf("Unexpected sent recipient %+v", a)
+	}
+	if b.Status != "failed" || b.Error != "gave up" || b.Attempts != 1 {
+		t.Errorf("Unexpected failed recipient %+v", b)
+	}
+	if audit.Status != "failed" || audit.Attempts != 0 {
+		t.Errorf("Unexpected unattempted recipient %+v", audit)
+	}
+
+	if n, err := q.RecordAttempt(ctx, id); err != nil || n != 1 {
+		t.Errorf("Expected 1 attempt, got %d, %v", n, err)
+	}
+}
+
+func TestEnqueueIdempotencyKey(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	job := EmailJob{
+		TemplateSlug:   "receipt",
+		Recipients:     []string{"a@example.com"},
+		Subject:        "Your receipt",
+		Priority:       PriorityNormal,
+		IdempotencyKey: "order-42",
+	}
+	first, err := q.Enqueue(ctx, job)
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	again, err := q.Enqueue(ctx, job)
+	if err != nil || again != first {
+		t.Fatalf("Expected reused key to return %s, got %s, %v", first, again, err)
+	}
+
+	// A retried request still gets its job after the address is suppressed
+	if err := q.Suppress(ctx, Suppression{Address: "a@example.com", Reason: SuppressUnsubscribe}); err != nil {
+		t.Fatalf("Suppress failed: %v", err)
+	}
+	if again, err := q.Enqueue(ctx, job); err != nil || again != first {
+		t.Fatalf("Expected reused key to return %s after suppression, got %s, %v", first, again, err)
+	}
+	if err := q.Unsuppress(ctx, "a@example.com", ""); err != nil {
+		t.Fatalf("Unsuppress failed: %v", err)
+	}
+
+	other := job
+	other.Tenant = "acme"
+	if id, _ := q.Enqueue(ctx, other); id == first {
+		t.Error("Keys should be scoped to the tenant")
+	}
+
+	// Only one message per distinct job is queued
+	for i := 0; i < 2; i++ {
+		if received, _, err := q.Receive(ctx); err != nil || received == nil {
+			t.Fatalf("Expected job %d in queue, got %v", i, err)
+		}
+	}
+	if received, _, _ := q.Receive(ctx); received != nil {
+		t.Errorf("Duplicate job %s was queued", received.ID)
+	}
+
+	// Keys are released after the idempotency window
+	if _, err := q.db.ExecContext(ctx, `UPDATE emails SET created_at = datetime('now', '-2 days') WHERE id = ?`, first); err != nil {
+		t.Fatalf("Failed to age job: %v", err)
+	}
+	if id, err := q.Enqueue(ctx, job); err != nil || id == first {
+		t.Errorf("Expected a new job after the window, got %s, %v", id, err)
+	}
+	if stored, _ := q.GetStatus(ctx, first); stored.IdempotencyKey != "" {
+		t.Errorf("Expected expired key to be released, got %q", stored.IdempotencyKey)
+	}
+}
+
+func TestEnqueueFailureStoresNothing(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	// The queue message cannot be stored
+	if _, err := q.db.ExecContext(ctx, `DROP TABLE goqite`); err != nil {
+		t.Fatalf("Failed to drop queue table: %v", err)
+	}
+	_, err := q.Enqueue(ctx, EmailJob{
+		TemplateSlug: "receipt",
+		Recipients:   []string{"a@example.com", "b@example.com"},
+		Subject:      "Your receipt",
+		Priority:     PriorityNormal,
+	})
+	if err == nil {
+		t.Fatal("Expected Enqueue to fail")
+	}
+
+	for _, table := range []string{"emails", "email_recipients"} {
+		var n int
+		if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil || n != 0 {
+			t.Errorf("Expected no rows in %s, got %d, %v", table, n, err)
+		}
+	}
+}
+
+func TestCancelRescheduleRetry(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	at := time.Now().Add(24 * time.Hour)
+	id, err := q.Schedule(ctx, EmailJob{
+		TemplateSlug: "newsletter",
+		Recipients:   []string{"a@example.com", "b@example.com", "c@example.com"},
+		Subject:      "News",
+		Priority:     PriorityNormal,
+		Headers:      map[string]string{"X-Campaign": "spring"},
+	}, at)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	// Moving the send time into the past makes the job receivable now
+	if err := q.Reschedule(ctx, id, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Reschedule failed: %v", err)
+	}
+	job, msg, err := q.Receive(ctx)
+	if err != nil || job == nil || job.ID != id {
+		t.Fatalf("Expected rescheduled job to be received, got %v, %v", job, err)
+	}
+	q.Extend(ctx, msg, time.Hour)
+
+	if err := q.Cancel(ctx, id); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if stored, _ := q.GetStatus(ctx, id); stored.Status != "cancelled" {
+		t.Errorf("Expected cancelled status, got %q", stored.Status)
+	}
+	if err := q.Cancel(ctx, id); !errors.Is(err, ErrStatus) {
+		t.Errorf("Expected ErrStatus cancelling twice, got %v", err)
+	}
+	if err := q.Cancel(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// Retry requeues the stored payload for recipients not yet sent to,
+	// leaving bounced ones alone
+	q.RecordDelivery(ctx, id, "a@example.com", "ses", "msg-1", nil, false)
+	q.RecordDelivery(ctx, id, "c@example.com", "ses", "msg-1", nil, false)
+	q.BounceRecipient(ctx, id, "c@example.com", "No such user")
+	if err := q.Retry(ctx, id); err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if err := q.Reschedule(ctx, id, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Reschedule failed: %v", err)
+	}
+	job, _, err = q.Receive(ctx)
+	if err != nil || job == nil || job.ID != id {
+		t.Fatalf("Expected retried job to be received, got %v, %v", job, err)
+	}
+	if job.Headers["X-Campaign"] != "spring" {
+		t.Errorf("Expected headers from the stored payload, got %v", job.Headers)
+	}
+
+	recipients, _ := q.Recipients(ctx, id)
+	if recipients[0].Status != "sent" || recipients[1].Status != "pending" || recipients[2].Status != "bounced" {
+		t.Errorf("Expected only the cancelled recipient to be reset, got %s, %s, %s",
+			recipients[0].Status, recipients[1].Status, recipients[2].Status)
+	}
+}
+
+func TestRescheduleWithoutMessage(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	id, err := q.Schedule(ctx, EmailJob{
+		TemplateSlug: "newsletter",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "News",
+		Priority:     PriorityNormal,
+	}, at)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	msgID, _ := q.messageFor(ctx, id)
+	q.queue.Delete(ctx, msgID)
+
+	if err := q.Reschedule(ctx, id, at.Add(time.Hour)); err == nil {
+		t.Fatal("Expected rescheduling an email that is not queued to fail")
+	}
+	if stored, _ := q.GetStatus(ctx, id); !stored.ScheduledAt.Equal(at) {
+		t.Errorf("Expected the send time to be kept, got %v", stored.ScheduledAt)
+	}
+	if err := q.Reschedule(ctx, "missing", at); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	var ids []string
+	for _, class := range []string{"transient", "transient", "permanent"} {
+		id, err := q.Enqueue(ctx, EmailJob{
+			TemplateSlug: "receipt",
+			Recipients:   []string{"a@example.com"},
+			Subject:      "Your receipt",
+			Priority:     PriorityNormal,
+		})
+		if err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+		_, msg, _ := q.Receive(ctx)
+		failure := errors.New("smtp 421 service not available")
+		q.RecordAttempt(ctx, id)
+		q.RecordFailure(ctx, id, 1, class, failure)
+		q.UpdateStatus(ctx, id, "failed", failure)
+		q.Delete(ctx, msg)
+		if err := q.DeadLetter(ctx, id, class, failure); err != nil {
+			t.Fatalf("DeadLetter failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	transient, err := q.DeadLetters(ctx, DeadLetterFilter{Class: "transient"})
+	if err != nil || len(transient) != 2 {
+		t.Fatalf("Expected 2 transient dead letters, got %d, %v", len(transient), err)
+	}
+	if transient[0].Job.TemplateSlug != "receipt" || transient[0].Attempts != 1 {
+		t.Errorf("Unexpected dead letter %+v", transient[0])
+	}
+
+	dead, err := q.GetDeadLetter(ctx, ids[0])
+	if err != nil || dead == nil || len(dead.History) != 1 || dead.History[0].Class != "transient" {
+		t.Fatalf("Expected dead letter with history, got %+v, %v", dead, err)
+	}
+	var payload EmailJob
+	if err := json.Unmarshal(dead.Payload, &payload); err != nil || payload.ID != ids[0] {
+		t.Errorf("Expected the queued payload, got %s, %v", dead.Payload, err)
+	}
+
+	if n, err := q.RequeueDeadLetters(ctx, DeadLetterFilter{Class: "transient"}); err != nil || n != 2 {
+		t.Fatalf("Expected 2 requeued, got %d, %v", n, err)
+	}
+	for i := 0; i < 2; i++ {
+		if job, _, err := q.Receive(ctx); err != nil || job == nil {
+			t.Fatalf("Expected requeued job %d, got %v", i, err)
+		}
+	}
+	if stored, _ := q.GetStatus(ctx, ids[0]); stored.Status != "pending" || stored.Attempts != 0 {
+		t.Errorf("Expected requeued job to be pending, got %s after %d", stored.Status, stored.Attempts)
+	}
+
+	if n, err := q.PurgeDeadLetters(ctx, DeadLetterFilter{}); err != nil || n != 1 {
+		t.Fatalf("Expected 1 purged, got %d, %v", n, err)
+	}
+	if left, _ := q.DeadLetters(ctx, DeadLetterFilter{}); len(left) != 0 {
+		t.Errorf("Expected no dead letters left, got %d", len(left))
+	}
+}
+
+func TestEvents(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	at := time.Now().Add(time.Hour)
+	id, err := q.Schedule(ctx, EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Hello",
+		Priority:     PriorityHigh,
+	}, at)
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+	if err := q.Cancel(ctx, id); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+
+	events, err := q.Events(ctx, id)
+	if err != nil {
+		t.Fatalf("Events failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventQueued || events[1].Type != EventCancelled {
+		t.Fatalf("Expected queued and cancelled events, got %+v", events)
+	}
+	if events[0].Details["priority"] != "high" || events[0].Details["scheduled_at"] != at.UTC().Format(time.RFC3339) {
+		t.Errorf("Unexpected queued details %v", events[0].Details)
+	}
+	if events[1].Details != nil {
+		t.Errorf("Expected no details for cancelled, got %v", events[1].Details)
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+	q.SetCategoryFunc(func(ctx context.Context, slug string) string {
+		if slug == "newsletter" {
+			return "marketing"
+		}
+		return "transactional"
+	})
+
+	past := time.Now().Add(-time.Hour)
+	for _, s := range []Suppression{
+		{Address: "Gone@Example.com", Reason: SuppressBounce, Source: "dsn"},
+		{Address: "reader@example.com", Category: "marketing", Reason: SuppressUnsubscribe},
+		{Address: "old@example.com", Reason: SuppressManual, ExpiresAt: &past},
+	} {
+		if err := q.Suppress(ctx, s); err != nil {
+			t.Fatalf("Suppress failed: %v", err)
+		}
+	}
+
+	// Every recipient suppressed
+	_, err := q.Enqueue(ctx, EmailJob{TemplateSlug: "receipt", Recipients: []string{"gone@example.com"}, Subject: "Hi", Priority: PriorityNormal})
+	var suppressedErr *SuppressedError
+	if !errors.Is(err, ErrSuppressed) || !errors.As(err, &suppressedErr) || suppressedErr.Addresses[0] != "gone@example.com" {
+		t.Fatalf("Expected ErrSuppressed, got %v", err)
+	}
+	_, err = q.Enqueue(ctx, EmailJob{TemplateSlug: "receipt", Recipients: []string{"gone@example.com", "GONE@example.com"}, Subject: "Hi", Priority: PriorityNormal})
+	if !errors.Is(err, ErrSuppressed) {
+		t.Fatalf("Expected ErrSuppressed for a repeated address, got %v", err)
+	}
+
+	// Category scoped and expired suppressions
+	id, err := q.Enqueue(ctx, EmailJob{TemplateSlug: "newsletter", Recipients: []string{"reader@example.com", "old@example.com"}, Subject: "News", Priority: PriorityLow})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	recipients, _ := q.Recipients(ctx, id)
+	if recipients[0].Status != "suppressed" || recipients[0].Error != "suppressed: unsubscribe" || recipients[1].Status != "pending" {
+		t.Errorf("Expected only reader@example.com to be suppressed, got %+v %+v", recipients[0], recipients[1])
+	}
+	if _, err := q.Enqueue(ctx, EmailJob{TemplateSlug: "receipt", Recipients: []string{"reader@example.com"}, Subject: "Receipt", Priority: PriorityNormal}); err != nil {
+		t.Errorf("Expected a marketing suppression not to apply to other categories, got %v", err)
+	}
+
+	list, _ := q.Suppressions(ctx, SuppressionFilter{})
+	if len(list) != 2 {
+		t.Errorf("Expected 2 active suppressions, got %d", len(list))
+	}
+	if list, _ := q.Suppressions(ctx, SuppressionFilter{Address: "GONE"}); len(list) != 1 || list[0].Address != "gone@example.com" {
+		t.Errorf("Expected to find gone@example.com, got %v", list)
+	}
+
+	// CSV round trip
+	var buf bytes.Buffer
+	if err := q.ExportSuppressions(ctx, &buf); err != nil {
+		t.Fatalf("ExportSuppressions failed: %v", err)
+	}
+	if err := q.Unsuppress(ctx, "gone@example.com", ""); err != nil {
+		t.Fatalf("Unsuppress failed: %v", err)
+	}
+	if err := q.Unsuppress(ctx, "gone@example.com", ""); !errors.Is(err, ErrNotSuppressed) {
+		t.Errorf("Expected ErrNotSuppressed, got %v", err)
+	}
+	n, err := q.ImportSuppressions(ctx, &buf, "import")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 suppressions imported, got %d, %v", n, err)
+	}
+	suppressed, _ := q.Suppressed(ctx, []string{"gone@example.com"}, "")
+	if s := suppressed["gone@example.com"]; s == nil || s.Reason != SuppressBounce || s.Source != "dsn" {
+		t.Errorf("Expected the exported suppression back, got %+v", s)
+	}
+
+	n, err = q.ImportSuppressions(ctx, strings.NewReader("gdpr@example.com\nnew@example.com,marketing,unsubscribe\n"), "import")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 headerless suppressions imported, got %d, %v", n, err)
+	}
+	suppressed, _ = q.Suppressed(ctx, []string{"gdpr@example.com", "new@example.com"}, "marketing")
+	if suppressed["gdpr@example.com"].Reason != SuppressManual || suppressed["gdpr@example.com"].Source != "import" ||
+		suppressed["new@example.com"].Reason != SuppressUnsubscribe {
+		t.Errorf("Unexpected imported suppressions %+v", suppressed)
+	}
+	if _, err := q.ImportSuppressions(ctx, strings.NewReader("not an address\n"), "import"); err == nil {
+		t.Error("Expected an invalid address to fail the import")
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+	q.SetCategoryFunc(func(ctx context.Context, slug string) string { return slug })
+
+	q.Suppress(ctx, Suppression{Address: "reader@example.com", Category: "digest", Reason: SuppressUnsubscribe})
+	if err := q.SetPreferences(ctx, "Reader@example.com", map[string]bool{"marketing": false, "product": true}); err != nil {
+		t.Fatalf("SetPreferences failed: %v", err)
+	}
+	prefs, err := q.Preferences(ctx, "reader@example.com")
+	if err != nil || len(prefs) != 3 || prefs["marketing"] || !prefs["product"] || prefs["digest"] {
+		t.Fatalf("Unexpected preferences %v, %v", prefs, err)
+	}
+
+	// Opted out categories are skipped like suppressions
+	suppressed, _ := q.Suppressed(ctx, []string{"READER@example.com", "other@example.com"}, "marketing")
+	if s := suppressed["READER@example.com"]; len(suppressed) != 1 || s == nil || s.Reason != SuppressPreference {
+		t.Errorf("Expected the opt-out to suppress, got %+v", suppressed)
+	}
+	if suppressed, _ := q.Suppressed(ctx, []string{"reader@example.com"}, "product"); len(suppressed) != 0 {
+		t.Errorf("Expected subscribed categories to be sent, got %+v", suppressed)
+	}
+	if _, err := q.Enqueue(ctx, EmailJob{TemplateSlug: "marketing", Recipients: []string{"reader@example.com"}, Subject: "Sale", Priority: PriorityLow}); !errors.Is(err, ErrSuppressed) {
+		t.Errorf("Expected ErrSuppressed, got %v", err)
+	}
+
+	// Opting back in lifts the unsubscribe
+	q.SetPreferences(ctx, "reader@example.com", map[string]bool{"digest": true, "marketing": true})
+	if suppressed, _ := q.Suppressed(ctx, []string{"reader@example.com"}, "digest"); len(suppressed) != 0 {
+		t.Errorf("Expected the unsubscribe to be lifted, got %+v", suppressed)
+	}
+	if _, err := q.Enqueue(ctx, EmailJob{TemplateSlug: "marketing", Recipients: []string{"reader@example.com"}, Subject: "Sale", Priority: PriorityLow}); err != nil {
+		t.Errorf("Enqueue failed: %v", err)
+	}
+}
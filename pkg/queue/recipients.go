@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Recipient is the delivery state of one address an email is sent to.
+type Recipient struct {
+	Address   string     `json:"address"`
+	Status    string     `json:"status"` // pending, retry, sending, sent, failed, bounced, suppressed or cancelled
+	Attempts  int        `json:"attempts"`
+	Provider  string     `json:"provider,omitempty"`
+	MessageID string     `json:"message_id,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Done reports whether no further attempts will be made for the recipient.
+func (r *Recipient) Done() bool {
+	switch r.Status {
+	case "sent", "failed", "bounced", "suppressed":
+		return true
+	}
+	return false
+}
+
+// Addresses returns the To, Cc and Bcc addresses of a job, without duplicates.
+func (job *EmailJob) Addresses() []string {
+	seen := make(map[string]bool)
+	var addrs []string
+	for _, list := range [][]string{job.Recipients, job.Cc, job.Bcc} {
+		for _, addr := range list {
+			if !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// storeRecipients creates a pending delivery record for each address of job
+// in tx.
+func storeRecipients(ctx context.Context, tx *sql.Tx, job EmailJob) error {
+	for _, addr := range job.Addresses() {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO email_recipients (email_id, address) VALUES (?, ?)
+		`, job.ID, addr); err != nil {
+			return fmt.Errorf("store recipient %s: %w", addr, err)
+		}
+	}
+	return nil
+}
+
+// Recipients returns the delivery state of each address of an email, in
+// the order they were stored.
+func (q *Queue) Recipients(ctx context.Context, id string) ([]*Recipient, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT address, status, attempts, provider, message_id, error, sent_at, updated_at
+		FROM email_recipients WHERE email_id = ?
+		ORDER BY rowid
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*Recipient
+	for rows.Next() {
+		var r Recipient
+		var provider, messageID, errStr sql.NullString
+		var sentAt sql.NullTime
+		if err := rows.Scan(&r.Address, &r.Status, &r.Attempts, &provider, &messageID, &errStr, &sentAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		r.Provider = provider.String
+		r.MessageID = messageID.String
+		r.Error = errStr.String
+		if sentAt.Valid {
+			r.SentAt = &sentAt.Time
+		}
+		recipients = append(recipients, &r)
+	}
+	return recipients, rows.Err()
+}
+
+// RecordDelivery records an attempt to deliver an email to addr: sent
+// through provider with the message ID it reported when err is nil,
+// otherwise the error with status retry, or failed when permanent.
+func (q *Queue) RecordDelivery(ctx context.Context, id, addr, provider, messageID string, err error, permanent bool) error {
+	status := "sent"
+	switch {
+	case err != nil && permanent:
+		status = "failed"
+	case err != nil:
+		status = "retry"
+	}
+
+	_, dbErr := q.db.ExecContext(ctx, `
+		INSERT INTO email_recipients (email_id, address, status, attempts, provider, message_id, error, sent_at)
+		VALUES (?, ?, ?, 1, ?, ?, ?, CASE WHEN ? = 'sent' THEN CURRENT_TIMESTAMP END)
+		ON CONFLICT (email_id, address) DO UPDATE SET
+			status = excluded.status,
+			attempts = attempts + 1,
+			provider = excluded.provider,
+			message_id = excluded.message_id,
+			error = excluded.error,
+			sent_at = excluded.sent_at,
+			updated_at = CURRENT_TIMESTAMP
+	`, id, addr, status, nullString(provider), nullString(messageID), nullError(err), status)
+	return dbErr
+}
+
+// ClaimRecipients marks those of addrs that are pending or being retried
+// as being sent and returns them. Recipients claimed by another worker are
+// left alone, unless their claim is older than VisibilityTimeout, so a
+// recipient is only sent to by one worker at a time. RecordDelivery ends
+// the claim.
+func (q *Queue) ClaimRecipients(ctx context.Context, id string, addrs []string) ([]string, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	args := []any{id}
+	for _, addr := range addrs {
+		args = append(args, addr)
+	}
+	args = append(args, fmt.Sprintf("-%d seconds", int(VisibilityTimeout.Seconds())))
+
+	rows, err := q.db.QueryContext(ctx, `
+		UPDATE email_recipients SET status = 'sending', updated_at = CURRENT_TIMESTAMP
+		WHERE email_id = ? AND address IN (?`+strings.Repeat(", ?", len(addrs)-1)+`)
+		  AND (status IN ('pending', 'retry') OR (status = 'sending' AND updated_at <= datetime('now', ?)))
+		RETURNING address
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []string
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, addr)
+	}
+	return claimed, rows.Err()
+}
+
+// FailRecipients marks the recipients of an email that have not been sent
+// to or already failed as failed with err.
+func (q *Queue) FailRecipients(ctx context.Context, id string, err error) error {
+	_, dbErr := q.db.ExecContext(ctx, `
+		UPDATE email_recipients
+		SET status = 'failed', error = COALESCE(?, error), updated_at = CURRENT_TIMESTAMP
+		WHERE email_id = ? AND status NOT IN ('sent', 'failed', 'bounced', 'suppressed')
+	`, nullError(err), id)
+	return dbErr
+}
+
+// EmailForMessage returns the ID of the email a provider accepted with
+// messageID, or "" when no recipient was sent that message.
+func (q *Queue) EmailForMessage(ctx context.Context, messageID string) (string, error) {
+	var id string
+	err := q.db.QueryRowContext(ctx, `
+		SELECT email_id FROM email_recipients WHERE message_id = ? LIMIT 1
+	`, messageID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+// BounceRecipient marks a recipient of an email as bounced with reason,
+// after the message was accepted for it. It does nothing when addr is not
+// a recipient of the email.
+func (q *Queue) BounceRecipient(ctx context.Context, id, addr, reason string) error {
+	_, err := q.db.ExecContext(ctx, `
+		UPDATE email_recipients
+		SET status = 'bounced', error = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE email_id = ? AND lower(address) = ?
+	`, nullString(reason), id, normalizeAddress(addr))
+	return err
+}
@@ -0,0 +1,368 @@
+package queue
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Suppression reasons.
+const (
+	SuppressBounce      = "bounce"      // the address hard bounced
+	SuppressComplaint   = "complaint"   // the recipient reported the email as spam
+	SuppressUnsubscribe = "unsubscribe" // the recipient opted out
+	SuppressManual      = "manual"      // added by an operator, such as for a GDPR request
+
+	// SuppressPreference is the reason given by Suppressed for recipients
+	// that opted out of a category on their preference page.
+	SuppressPreference = "preference"
+)
+
+var (
+	// ErrSuppressed is returned by Enqueue when every recipient of a job is
+	// suppressed. The error is a *SuppressedError.
+	ErrSuppressed = errors.New("all recipients are suppressed")
+	// ErrNotSuppressed is returned when removing a suppression that does
+	// not exist.
+	ErrNotSuppressed = errors.New("address is not suppressed")
+)
+
+// SuppressedError lists the suppressed addresses a job was refused for.
+type SuppressedError struct {
+	Addresses []string
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrSuppressed, strings.Join(e.Addresses, ", "))
+}
+
+func (e *SuppressedError) Unwrap() error {
+	return ErrSuppressed
+}
+
+// Suppression is an address that should no longer be sent to, for every
+// template or only those of one category, until it expires.
+type Suppression struct {
+	Address   string     `json:"address"`
+	Category  string     `json:"category,omitempty"` // empty for every category
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source,omitempty"`   // where the reason came from, such as dsn or ses
+	EmailID   string     `json:"email_id,omitempty"` // the email that caused it, if known
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// SuppressionFilter selects suppressions. Empty fields match everything.
+type SuppressionFilter struct {
+	Address  string // part of the address
+	Category string
+	Reason   string
+	Limit    int // 0 for no limit
+}
+
+// Suppress adds an address to the suppression list, replacing its
+// suppression in the same category.
+func (q *Queue) Suppress(ctx context.Context, s Suppression) error {
+	addr := normalizeAddress(s.Address)
+	if addr == "" {
+		return errors.New("suppression has no address")
+	}
+	if s.Reason == "" {
+		s.Reason = SuppressManual
+	}
+	var expiresAt sql.NullTime
+	if s.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: s.ExpiresAt.UTC(), Valid: true}
+	}
+
+	_, err := q.db.ExecContext(ctx, `
+		INSERT INTO suppressions (address, category, reason, source, email_id, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (address, category) DO UPDATE SET
+			reason = excluded.reason,
+			source = excluded.source,
+			email_id = excluded.email_id,
+			expires_at = excluded.expires_at,
+			created_at = CURRENT_TIMESTAMP
+	`, addr, s.Category, s.Reason, nullString(s.Source), nullString(s.EmailID), expiresAt)
+	return err
+}
+
+// Unsuppress removes the suppression of an address in a category, "" for
+// the one covering every category.
+func (q *Queue) Unsuppress(ctx context.Context, addr, category string) error {
+	res, err := q.db.ExecContext(ctx, `
+		DELETE FROM suppressions WHERE address = ? AND category = ?
+	`, normalizeAddress(addr), category)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotSuppressed, addr)
+	}
+	return nil
+}
+
+// AllSuppressed reports whether every one of addrs is among suppressed, as
+// returned by Suppressed for them. Duplicates and addresses differing only
+// in case count once.
+func AllSuppressed(addrs []string, suppressed map[string]*Suppression) bool {
+	for _, addr := range addrs {
+		if suppressed[addr] == nil {
+			return false
+		}
+	}
+	return len(addrs) > 0
+}
+
+// Suppressed returns the active suppressions among addrs that apply to
+// mail of category, keyed by address as given. A suppression covering
+// every category wins over one for category, which wins over the
+// recipient's preferences: those that opted out of category are returned
+// with reason SuppressPreference.
+func (q *Queue) Suppressed(ctx context.Context, addrs []string, category string) (map[string]*Suppression, error) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	byAddress := make(map[string][]string, len(addrs))
+	args := make([]any, 0, len(addrs)+2)
+	for _, addr := range addrs {
+		norm := normalizeAddress(addr)
+		if _, ok := byAddress[norm]; !ok {
+			args = append(args, norm)
+		}
+		byAddress[norm] = append(byAddress[norm], addr)
+	}
+	args = append(args, category, time.Now().UTC())
+
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT `+suppressionColumns+` FROM suppressions
+		WHERE address IN (?`+strings.Repeat(", ?", len(byAddress)-1)+`)
+		  AND category IN ('', ?)
+		  AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY category DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressed := make(map[string]*Suppression)
+	for rows.Next() {
+		s, err := scanSuppression(rows)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range byAddress[s.Address] {
+			suppressed[addr] = s
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if category == "" {
+		return suppressed, nil
+	}
+	optedOut, err := q.optedOut(ctx, args[:len(byAddress)], category)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range optedOut {
+		for _, addr := range byAddress[s.Address] {
+			if _, ok := suppressed[addr]; !ok {
+				suppressed[addr] = s
+			}
+		}
+	}
+	return suppressed, nil
+}
+
+// Suppressions returns the active suppressions matching filter, most
+// recent first.
+func (q *Queue) Suppressions(ctx context.Context, filter SuppressionFilter) ([]*Suppression, error) {
+	query := `SELECT ` + suppressionColumns + ` FROM suppressions WHERE (expires_at IS NULL OR expires_at > ?)`
+	args := []any{time.Now().UTC()}
+	if filter.Address != "" {
+		query += ` AND address LIKE ?`
+		args = append(args, "%"+normalizeAddress(filter.Address)+"%")
+	}
+	if filter.Category != "" {
+		query += ` AND category = ?`
+		args = append(args, filter.Category)
+	}
+	if filter.Reason != "" {
+		query += ` AND reason = ?`
+		args = append(args, filter.Reason)
+	}
+	query += ` ORDER BY created_at DESC, address`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Suppression
+	for rows.Next() {
+		s, err := scanSuppression(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// suppressionCSVHeader is the header of exported suppression lists.
+var suppressionCSVHeader = []string{"address", "category", "reason", "source", "expires_at", "created_at"}
+
+// ExportSuppressions writes the active suppressions to w as CSV, with a
+// header row.
+func (q *Queue) ExportSuppressions(ctx context.Context, w io.Writer) error {
+	list, err := q.Suppressions(ctx, SuppressionFilter{})
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(suppressionCSVHeader)
+	for _, s := range list {
+		var expiresAt string
+		if s.ExpiresAt != nil {
+			expiresAt = s.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		cw.Write([]string{s.Address, s.Category, s.Reason, s.Source, expiresAt, s.CreatedAt.UTC().Format(time.RFC3339)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportSuppressions adds the suppressions in a CSV list, such as one
+// written by ExportSuppressions, and returns how many were added. Columns
+// are named by a header row, or else are address, category, reason,
+// source and expires_at, all but address optional. The reason defaults to
+// manual and the source to source.
+func (q *Queue) ImportSuppressions(ctx context.Context, r io.Reader, source string) (int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	columns := map[string]int{}
+	for i, name := range suppressionCSVHeader {
+		columns[name] = i
+	}
+	imported := 0
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, err
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			columns = map[string]int{}
+			for i, name := range record {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		addr, err := mail.ParseAddress(field("address"))
+		if err != nil {
+			return imported, fmt.Errorf("line %d: invalid address %q", line, field("address"))
+		}
+		s := Suppression{
+			Address:  addr.Address,
+			Category: field("category"),
+			Reason:   field("reason"),
+			Source:   field("source"),
+		}
+		if s.Source == "" {
+			s.Source = source
+		}
+		if v := field("expires_at"); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return imported, fmt.Errorf("line %d: invalid expires_at %q", line, v)
+			}
+			s.ExpiresAt = &t
+		}
+		if err := q.Suppress(ctx, s); err != nil {
+			return imported, fmt.Errorf("line %d: %w", line, err)
+		}
+		imported++
+	}
+}
+
+// suppressRecipients marks the recipients of an email that are suppressed
+// so they are not sent to, and records a suppressed event listing them.
+func (q *Queue) suppressRecipients(ctx context.Context, id string, suppressed map[string]*Suppression) error {
+	if len(suppressed) == 0 {
+		return nil
+	}
+	reasons := make(map[string]any, len(suppressed))
+	for addr, s := range suppressed {
+		if _, err := q.db.ExecContext(ctx, `
+			UPDATE email_recipients
+			SET status = 'suppressed', error = ?, updated_at = CURRENT_TIMESTAMP
+			WHERE email_id = ? AND address = ? AND status NOT IN ('sent', 'failed', 'bounced')
+		`, "suppressed: "+s.Reason, id, addr); err != nil {
+			return fmt.Errorf("suppress recipient %s: %w", addr, err)
+		}
+		reasons[addr] = s.Reason
+	}
+	return q.RecordEvent(ctx, id, EventSuppressed, map[string]any{"recipients": reasons})
+}
+
+// SuppressRecipients marks the recipients of an email that are suppressed
+// for mail of category, so they are skipped, and returns the suppressions
+// found. Recipients already sent to are left alone.
+func (q *Queue) SuppressRecipients(ctx context.Context, id string, addrs []string, category string) (map[string]*Suppression, error) {
+	suppressed, err := q.Suppressed(ctx, addrs, category)
+	if err != nil {
+		return nil, err
+	}
+	return suppressed, q.suppressRecipients(ctx, id, suppressed)
+}
+
+// suppressionColumns lists the suppressions columns read by
+// scanSuppression.
+const suppressionColumns = `address, category, reason, source, email_id, expires_at, created_at`
+
+func scanSuppression(row scanner) (*Suppression, error) {
+	var s Suppression
+	var source, emailID sql.NullString
+	var expiresAt sql.NullTime
+	if err := row.Scan(&s.Address, &s.Category, &s.Reason, &source, &emailID, &expiresAt, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.Source = source.String
+	s.EmailID = emailID.String
+	if expiresAt.Valid {
+		s.ExpiresAt = &expiresAt.Time
+	}
+	return &s, nil
+}
+
+// normalizeAddress returns the form addresses are suppressed under.
+func normalizeAddress(addr string) string {
+	return strings.ToLower(strings.TrimSpace(addr))
+}
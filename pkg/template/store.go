@@ -0,0 +1,544 @@
+// Package template provides a SQLite-backed store for MJML email templates.
+//
+// Templates persisted here are loaded into an mjml.Renderer at startup and
+// kept in sync on every change, so they can be edited at runtime without
+// redeploying the templates directory.
+package template
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Template statuses.
+const (
+	StatusDraft     = "draft"     // Saved but not served by the renderer
+	StatusPublished = "published" // Live and loaded into the renderer
+	StatusArchived  = "archived"  // Retired, kept for history
+)
+
+var (
+	// ErrNotFound is returned when a template slug does not exist.
+	ErrNotFound = errors.New("template not found")
+	// ErrExists is returned when creating a template whose slug is taken.
+	ErrExists = errors.New("template already exists")
+)
+
+// Template is a persisted MJML template. Content, Text and Version
+// describe the live (published) version; newer unpublished versions are
+// drafts. Text is the optional plain-text companion, a text/template;
+// without it the plain-text part is derived from the HTML.
+type Template struct {
+	ID            string         `json:"id"`
+	Slug          string         `json:"slug"`
+	Name          string         `json:"name"`
+	Content       string         `json:"content"`
+	Text          string         `json:"text,omitempty"`
+	Version       int            `json:"version"`
+	LatestVersion int            `json:"latest_version"`
+	Status        string         `json:"status"`
+	Category      string         `json:"category,omitempty"`
+	Metadata      map[string]any `json:"metadata,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	PublishedAt   *time.Time     `json:"published_at,omitempty"`
+}
+
+// Store persists templates in the templates and template_versions tables
+// and keeps a renderer in sync with the published set.
+type Store struct {
+	db       *sql.DB
+	renderer *mjml.Renderer
+}
+
+// NewStore creates a template store. The tables must be created by
+// db.Migrate() before calling this. If renderer is non-nil, every change
+// to a published template is applied to it immediately.
+func NewStore(db *sql.DB, renderer *mjml.Renderer) *Store {
+	return &Store{db: db, renderer: renderer}
+}
+
+// Load makes the renderer serve exactly the published templates: each is
+// loaded, replacing any template of the same name, and every other
+// template, such as a draft or one loaded from disk, is removed.
+func (s *Store) Load(ctx context.Context) error {
+	if s.renderer == nil {
+		return nil
+	}
+
+	templates, err := s.List(ctx, StatusPublished)
+	if err != nil {
+		return err
+	}
+
+	published := make(map[string]bool, len(templates))
+	for _, t := range templates {
+		if err := s.sync(t); err != nil {
+			return fmt.Errorf("load template %s: %w", t.Slug, err)
+		}
+		published[t.Slug] = true
+	}
+	for _, name := range s.renderer.ListTemplates() {
+		if !published[name] {
+			s.renderer.RemoveTemplate(name)
+		}
+	}
+
+	logx.Infow("Templates loaded from database", logx.Field("count", len(templates)))
+	return nil
+}
+
+// ImportDir creates a published template for every .mjml file in dir whose
+// slug is not yet in the store, with its .txt companion as the text part.
+// Existing templates are left untouched so runtime edits survive restarts,
+// except that those stored without a text part take their companion.
+// Deleted slugs are not imported again, and partials are left to the
+// renderer. Returns the number of templates imported.
+func (s *Store) ImportDir(ctx context.Context, dir string) (int, error) {
+	imported := 0
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if path != dir && mjml.IsPartialDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".mjml") {
+			return nil
+		}
+
+		slug := strings.TrimSuffix(filepath.Base(path), ".mjml")
+		text, err := os.ReadFile(strings.TrimSuffix(path, ".mjml") + ".txt")
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("read text template file for %s: %w", slug, err)
+		}
+
+		existing, err := s.Get(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if existing.Text == "" && len(text) > 0 {
+				return s.setText(ctx, existing, string(text))
+			}
+			return nil
+		}
+		if deleted, err := s.deleted(ctx, slug); err != nil || deleted {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read template file %s: %w", path, err)
+		}
+
+		if err := s.Create(ctx, &Template{
+			Slug:     slug,
+			Name:     nameFromSlug(slug),
+			Content:  string(content),
+			Text:     string(text),
+			Status:   StatusPublished,
+			Metadata: map[string]any{"source": path},
+		}); err != nil {
+			return err
+		}
+		imported++
+		return nil
+	})
+	if err != nil {
+		return imported, err
+	}
+
+	return imported, nil
+}
+
+// Create inserts a new template as version 1. Status defaults to published.
+func (s *Store) Create(ctx context.Context, t *Template) error {
+	if t.Slug == "" {
+		return fmt.Errorf("template slug is required")
+	}
+	if t.Name == "" {
+		t.Name = nameFromSlug(t.Slug)
+	}
+	if t.Status == "" {
+		t.Status = StatusPublished
+	}
+
+	existing, err := s.Get(ctx, t.Slug)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrExists
+	}
+
+	metadata, err := marshalMetadata(t.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := s.check(t); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	t.ID = uuid.New().String()
+	t.Version = 1
+	t.LatestVersion = 1
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	t.PublishedAt = nil
+	if t.Status == StatusPublished {
+		t.PublishedAt = &now
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO templates (id, slug, name, content, text, version, status, category,
+			                       metadata, created_at, updated_at, published_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, t.ID, t.Slug, t.Name, t.Content, nullString(t.Text), t.Version, t.Status, nullString(t.Category),
+			metadata, now, now, nullTime(t.PublishedAt)); err != nil {
+			return fmt.Errorf("insert template: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM deleted_templates WHERE slug = ?`, t.Slug); err != nil {
+			return fmt.Errorf("clear deleted template: %w", err)
+		}
+		return insertVersion(ctx, tx, t.ID, t.Version, t.Content, t.Text)
+	})
+	if err != nil {
+		return err
+	}
+	return s.sync(t)
+}
+
+// Update saves changes to an existing template. A change of content or
+// text is recorded as a new version in template_versions and becomes the
+// live version immediately; use SaveDraft to stage content without
+// publishing.
+func (s *Store) Update(ctx context.Context, t *Template) error {
+	current, err := s.Get(ctx, t.Slug)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return ErrNotFound
+	}
+
+	if t.Name == "" {
+		t.Name = current.Name
+	}
+	if t.Status == "" {
+		t.Status = current.Status
+	}
+
+	metadata, err := marshalMetadata(t.Metadata)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	t.ID = current.ID
+	t.CreatedAt = current.CreatedAt
+	t.UpdatedAt = now
+	t.Version = current.Version
+	t.LatestVersion = current.LatestVersion
+	t.PublishedAt = current.PublishedAt
+	if t.Status == StatusPublished && current.Status != StatusPublished {
+		t.PublishedAt = &now
+	}
+
+	contentChanged := t.Content != current.Content || t.Text != current.Text
+	if contentChanged {
+		t.Version = current.LatestVersion + 1
+		t.LatestVersion = t.Version
+	}
+	if err := s.check(t); err != nil {
+		return err
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE templates
+			SET name = ?, content = ?, text = ?, version = ?, status = ?, category = ?,
+			    metadata = ?, updated_at = ?, published_at = ?
+			WHERE id = ?
+		`, t.Name, t.Content, nullString(t.Text), t.Version, t.Status, nullString(t.Category),
+			metadata, now, nullTime(t.PublishedAt), t.ID); err != nil {
+			return fmt.Errorf("update template: %w", err)
+		}
+		if contentChanged {
+			return insertVersion(ctx, tx, t.ID, t.Version, t.Content, t.Text)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return s.sync(t)
+}
+
+// Delete removes a template and its version history, and records the slug
+// so ImportDir does not seed it again.
+func (s *Store) Delete(ctx context.Context, slug string) error {
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM templates WHERE slug = ?`, slug)
+		if err != nil {
+			return fmt.Errorf("delete template: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO deleted_templates (slug, deleted_at) VALUES (?, CURRENT_TIMESTAMP)
+			ON CONFLICT(slug) DO UPDATE SET deleted_at = excluded.deleted_at
+		`, slug); err != nil {
+			return fmt.Errorf("record deleted template: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.renderer != nil {
+		s.renderer.RemoveTemplate(slug)
+	}
+	return nil
+}
+
+// Get returns the template with the given slug, or nil if it does not exist.
+func (s *Store) Get(ctx context.Context, slug string) (*Template, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM templates WHERE slug = ?
+	`, slug)
+
+	t, err := scanTemplate(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// List returns templates ordered by slug with an optional status filter.
+func (s *Store) List(ctx context.Context, status string) ([]*Template, error) {
+	query := `
+		SELECT ` + templateColumns + `
+		FROM templates
+	`
+	args := []any{}
+
+	if status != "" && status != "all" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY slug"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []*Template
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+// Categories returns the categories templates are in, in order.
+func (s *Store) Categories(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT category FROM templates
+		WHERE category IS NOT NULL AND category != ''
+		ORDER BY category
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// deleted reports whether a template with the given slug was deleted.
+func (s *Store) deleted(ctx context.Context, slug string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM deleted_templates WHERE slug = ?`, slug).Scan(&n)
+	return n > 0, err
+}
+
+// check reports whether a published template and its text part parse, so
+// content the renderer would reject is never committed.
+func (s *Store) check(t *Template) error {
+	if s.renderer == nil || t.Status != StatusPublished {
+		return nil
+	}
+	if err := s.renderer.CheckTemplate(t.Slug, t.Content); err != nil {
+		return err
+	}
+	return s.renderer.CheckTextTemplate(t.Slug, t.Text)
+}
+
+// sync applies a template to the renderer: published templates are
+// (re)loaded together with their text part, anything else is removed. It
+// runs once the change is committed, after check, so the renderer never
+// serves content the database does not have.
+func (s *Store) sync(t *Template) error {
+	if s.renderer == nil {
+		return nil
+	}
+	if t.Status != StatusPublished {
+		s.renderer.RemoveTemplate(t.Slug)
+		return nil
+	}
+	return s.renderer.LoadTemplateWithText(t.Slug, t.Content, t.Text)
+}
+
+// setText sets the text part of a template stored without one, on the
+// template and its live version, without making a new version.
+func (s *Store) setText(ctx context.Context, t *Template, text string) error {
+	t.Text = text
+	if err := s.check(t); err != nil {
+		return err
+	}
+	err := s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE templates SET text = ? WHERE id = ?`, text, t.ID); err != nil {
+			return fmt.Errorf("set template text: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE template_versions SET text = ? WHERE template_id = ? AND version = ?
+		`, text, t.ID, t.Version); err != nil {
+			return fmt.Errorf("set template version text: %w", err)
+		}
+		return nil
+	})
+	if err != nil || t.Status != StatusPublished {
+		return err
+	}
+	return s.sync(t)
+}
+
+func (s *Store) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertVersion(ctx context.Context, tx *sql.Tx, templateID string, version int, content, text string) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO template_versions (id, template_id, version, content, text, created_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, uuid.New().String(), templateID, version, content, nullString(text)); err != nil {
+		return fmt.Errorf("insert template version: %w", err)
+	}
+	return nil
+}
+
+// templateColumns selects a template row along with its newest version number.
+const templateColumns = `id, slug, name, content, COALESCE(text, ''), version,
+		       (SELECT COALESCE(MAX(v.version), templates.version) FROM template_versions v
+		        WHERE v.template_id = templates.id),
+		       status, category, metadata, created_at, updated_at, published_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTemplate(row scanner) (*Template, error) {
+	var t Template
+	var category, metadata sql.NullString
+	var publishedAt sql.NullTime
+
+	if err := row.Scan(
+		&t.ID, &t.Slug, &t.Name, &t.Content, &t.Text, &t.Version, &t.LatestVersion,
+		&t.Status, &category, &metadata, &t.CreatedAt, &t.UpdatedAt, &publishedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	t.Category = category.String
+	if metadata.Valid && metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &t.Metadata); err != nil {
+			return nil, fmt.Errorf("unmarshal metadata: %w", err)
+		}
+	}
+	if publishedAt.Valid {
+		t.PublishedAt = &publishedAt.Time
+	}
+
+	return &t, nil
+}
+
+func marshalMetadata(metadata map[string]any) (sql.NullString, error) {
+	if len(metadata) == 0 {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("marshal metadata: %w", err)
+	}
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+// nameFromSlug turns "reset_password" into "Reset Password".
+func nameFromSlug(slug string) string {
+	words := strings.FieldsFunc(slug, func(r rune) bool { return r == '_' || r == '-' })
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,340 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+)
+
+const testContent = `<mjml>
+	<mj-body>
+		<mj-section>
+			<mj-column>
+				<mj-text>Hello {{.Name}}</mj-text>
+			</mj-column>
+		</mj-section>
+	</mj-body>
+</mjml>`
+
+func newTestStore(t *testing.T) (*Store, *mjml.Renderer) {
+	t.Helper()
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	renderer := mjml.NewRenderer(mjml.WithFonts(false))
+	return NewStore(database.DB, renderer), renderer
+}
+
+func TestStoreCreateFeedsRenderer(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	err := store.Create(ctx, &Template{
+		Slug:     "greeting",
+		Content:  testContent,
+		Category: "transactional",
+		Metadata: map[string]any{"owner": "growth"},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if !renderer.HasTemplate("greeting") {
+		t.Fatal("Created template was not loaded into the renderer")
+	}
+
+	got, err := store.Get(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("Get returned nil for existing template")
+	}
+	if got.Name != "Greeting" || got.Status != StatusPublished || got.Version != 1 {
+		t.Errorf("Unexpected template: name=%q status=%q version=%d", got.Name, got.Status, got.Version)
+	}
+	if got.Category != "transactional" || got.Metadata["owner"] != "growth" {
+		t.Errorf("Category/metadata not persisted: %q %v", got.Category, got.Metadata)
+	}
+
+	if err := store.Create(ctx, &Template{Slug: "greeting", Content: testContent}); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists for duplicate slug, got %v", err)
+	}
+}
+
+func TestStoreUpdateAndDelete(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, &Template{Slug: "greeting", Content: testContent}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	updated := &Template{Slug: "greeting", Content: testContent + "\n<!-- v2 -->"}
+	if err := store.Update(ctx, updated); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Expected version 2 after content change, got %d", updated.Version)
+	}
+
+	var versions int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM template_versions WHERE template_id = ?`, updated.ID).Scan(&versions); err != nil {
+		t.Fatalf("Count versions failed: %v", err)
+	}
+	if versions != 2 {
+		t.Errorf("Expected 2 version rows, got %d", versions)
+	}
+
+	// Unpublishing removes the template from the renderer
+	if err := store.Update(ctx, &Template{Slug: "greeting", Content: updated.Content, Status: StatusDraft}); err != nil {
+		t.Fatalf("Update to draft failed: %v", err)
+	}
+	if renderer.HasTemplate("greeting") {
+		t.Error("Draft template should not be loaded into the renderer")
+	}
+
+	if err := store.Delete(ctx, "greeting"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "greeting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting missing template, got %v", err)
+	}
+}
+
+func TestStoreCategories(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	for slug, category := range map[string]string{"digest": "product", "sale": "marketing", "promo": "marketing", "receipt": ""} {
+		if err := store.Create(ctx, &Template{Slug: slug, Content: testContent, Category: category}); err != nil {
+			t.Fatalf("Create %s failed: %v", slug, err)
+		}
+	}
+	categories, err := store.Categories(ctx)
+	if err != nil || strings.Join(categories, ",") != "marketing,product" {
+		t.Errorf("Expected marketing,product, got %v, %v", categories, err)
+	}
+}
+
+func TestStoreRejectsInvalidTemplate(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, &Template{Slug: "broken", Content: "{{.Name"}); err == nil {
+		t.Fatal("Expected parse error for invalid template")
+	}
+
+	got, err := store.Get(ctx, "broken")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != nil {
+		t.Error("Invalid template should not have been persisted")
+	}
+}
+
+func TestStoreFailedUpdateKeepsRenderer(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, &Template{Slug: "greeting", Content: testContent, Text: "Hello {{.Name}}"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// The text part is valid but the HTML is not, so neither is applied
+	if err := store.Update(ctx, &Template{Slug: "greeting", Content: "{{.Name", Text: "Changed {{.Name}}"}); err == nil {
+		t.Fatal("Expected parse error for invalid template")
+	}
+	result, err := renderer.Render("greeting", map[string]any{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if result.Text != "Hello Ada" {
+		t.Errorf("Expected the stored text part to be kept, got %q", result.Text)
+	}
+	if got, _ := store.Get(ctx, "greeting"); got.Version != 1 || got.Text != "Hello {{.Name}}" {
+		t.Errorf("Expected version 1 to be kept, got %+v", got)
+	}
+}
+
+func TestStoreImportDir(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	for _, name := range []string{"welcome", "reset_password"} {
+		if err := os.WriteFile(filepath.Join(dir, name+".mjml"), []byte(testContent), 0644); err != nil {
+			t.Fatalf("Failed to write template: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "welcome.txt"), []byte("Hello {{.Name}}, in plain text"), 0644)
+
+	imported, err := store.ImportDir(ctx, dir)
+	if err != nil {
+		t.Fatalf("ImportDir failed: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("Expected 2 templates imported, got %d", imported)
+	}
+
+	// Second import is a no-op, except for adding text parts to templates
+	// stored without one
+	os.WriteFile(filepath.Join(dir, "reset_password.txt"), []byte("Reset it"), 0644)
+	imported, err = store.ImportDir(ctx, dir)
+	if err != nil {
+		t.Fatalf("Second ImportDir failed: %v", err)
+	}
+	if imported != 0 {
+		t.Errorf("Expected no templates re-imported, got %d", imported)
+	}
+
+	got, err := store.Get(ctx, "reset_password")
+	if err != nil || got == nil {
+		t.Fatalf("Imported template missing: %v", err)
+	}
+	if got.Name != "Reset Password" || got.Text != "Reset it" || got.Version != 1 {
+		t.Errorf("Expected name derived from slug and the text part added to v1, got %q, %q and v%d", got.Name, got.Text, got.Version)
+	}
+
+	renderer.RemoveTemplate("welcome")
+	if err := store.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !renderer.HasTemplate("welcome") || !renderer.HasTextTemplate("welcome") {
+		t.Error("Load did not restore template and its text part into renderer")
+	}
+	if got, _ := store.Get(ctx, "welcome"); got.Text != "Hello {{.Name}}, in plain text" {
+		t.Errorf("Expected the .txt companion to be stored, got %q", got.Text)
+	}
+
+	// A deleted template stays deleted until it is created again
+	if err := store.Delete(ctx, "welcome"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if imported, err := store.ImportDir(ctx, dir); err != nil || imported != 0 {
+		t.Fatalf("Expected deleted template not to be re-imported, got %d (%v)", imported, err)
+	}
+	if got, _ := store.Get(ctx, "welcome"); got != nil {
+		t.Error("Deleted template was re-imported")
+	}
+	if err := store.Create(ctx, &Template{Slug: "welcome", Content: testContent}); err != nil {
+		t.Fatalf("Create after delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "welcome"); err != nil {
+		t.Fatalf("Second delete failed: %v", err)
+	}
+}
+
+func TestStoreLoadServesOnlyPublished(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, &Template{Slug: "live", Content: testContent}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Create(ctx, &Template{Slug: "draft", Content: testContent, Status: StatusDraft}); err != nil {
+		t.Fatalf("Create draft failed: %v", err)
+	}
+
+	// Templates the store does not publish, such as ones loaded from disk
+	for _, name := range []string{"draft", "stray"} {
+		if err := renderer.LoadTemplate(name, testContent); err != nil {
+			t.Fatalf("LoadTemplate failed: %v", err)
+		}
+	}
+
+	if err := store.Load(ctx); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !renderer.HasTemplate("live") {
+		t.Error("Expected the published template to be served")
+	}
+	for _, name := range []string{"draft", "stray"} {
+		if renderer.HasTemplate(name) {
+			t.Errorf("Expected %s to be removed from the renderer", name)
+		}
+	}
+}
+
+func TestStoreDraftPublishRollback(t *testing.T) {
+	store, renderer := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.Create(ctx, &Template{Slug: "greeting", Content: testContent, Text: "Hello {{.Name}}, in plain text"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	v2 := strings.Replace(testContent, "Hello", "Welcome", 1)
+	draft, err := store.SaveDraft(ctx, "greeting", v2, "Welcome {{.Name}}, in plain text")
+	if err != nil {
+		t.Fatalf("SaveDraft failed: %v", err)
+	}
+	if draft.Version != 2 {
+		t.Errorf("Expected draft version 2, got %d", draft.Version)
+	}
+
+	// The draft is not live yet
+	html, err := renderer.RenderTemplate("greeting", map[string]any{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(html, "Hello Ada") {
+		t.Error("Live template changed before publish")
+	}
+
+	got, _ := store.Get(ctx, "greeting")
+	if got.Version != 1 || got.LatestVersion != 2 {
+		t.Errorf("Expected live v1 and latest v2, got %d and %d", got.Version, got.LatestVersion)
+	}
+
+	if _, err := store.Publish(ctx, "greeting", 2); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	live, err := renderer.Render("greeting", map[string]any{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(live.HTML, "Welcome Ada") || live.Text != "Welcome Ada, in plain text" {
+		t.Errorf("Published version not loaded into renderer, text %q", live.Text)
+	}
+
+	// Historical versions stay renderable, with their own text part
+	old, err := store.RenderVersion(ctx, "greeting", 1, map[string]any{"Name": "Ada"})
+	if err != nil {
+		t.Fatalf("RenderVersion failed: %v", err)
+	}
+	if !strings.Contains(old.HTML, "Hello Ada") || old.Text != "Hello Ada, in plain text" {
+		t.Errorf("RenderVersion did not render version 1, text %q", old.Text)
+	}
+
+	rolled, err := store.Rollback(ctx, "greeting", 1)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if rolled.Version != 3 || rolled.Content != testContent || rolled.Text != "Hello {{.Name}}, in plain text" {
+		t.Errorf("Expected rollback to publish v1 content as v3, got v%d", rolled.Version)
+	}
+
+	versions, err := store.ListVersions(ctx, "greeting")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 3 || !versions[0].Live || versions[1].Live {
+		t.Errorf("Unexpected version history: %d versions, newest live=%v", len(versions), versions[0].Live)
+	}
+
+	if _, err := store.Publish(ctx, "greeting", 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound publishing missing version, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package template
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/pkg/mjml"
+)
+
+// Version is an immutable snapshot of template content and its text part.
+type Version struct {
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Text      string    `json:"text,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Live      bool      `json:"live"`
+}
+
+// SaveDraft records content and its text part as a new version without
+// publishing it. The live version keeps being served until Publish is
+// called.
+func (s *Store) SaveDraft(ctx context.Context, slug, content, text string) (*Version, error) {
+	current, err := s.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrNotFound
+	}
+
+	if s.renderer != nil {
+		if err := s.renderer.CheckTemplate(slug, content); err != nil {
+			return nil, err
+		}
+		if err := s.renderer.CheckTextTemplate(slug, text); err != nil {
+			return nil, err
+		}
+	}
+
+	v := &Version{
+		Version:   current.LatestVersion + 1,
+		Content:   content,
+		Text:      text,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		if err := insertVersion(ctx, tx, current.ID, v.Version, content, text); err != nil {
+			return err
+		}
+
+		// A template that has never been published tracks its latest draft
+		if current.Status != StatusPublished {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE templates SET content = ?, text = ?, version = ?, updated_at = ? WHERE id = ?
+			`, content, nullString(text), v.Version, v.CreatedAt, current.ID)
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			UPDATE templates SET updated_at = ? WHERE id = ?
+		`, v.CreatedAt, current.ID)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save draft: %w", err)
+	}
+
+	return v, nil
+}
+
+// Publish makes the given version live and loads it into the renderer.
+func (s *Store) Publish(ctx context.Context, slug string, version int) (*Template, error) {
+	t, err := s.Get(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+
+	v, err := s.GetVersion(ctx, slug, version)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s version %d", ErrNotFound, slug, version)
+	}
+
+	now := time.Now().UTC()
+	t.Content = v.Content
+	t.Text = v.Text
+	t.Version = v.Version
+	t.Status = StatusPublished
+	t.UpdatedAt = now
+	t.PublishedAt = &now
+	if err := s.check(t); err != nil {
+		return nil, err
+	}
+
+	err = s.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE templates
+			SET content = ?, text = ?, version = ?, status = ?, updated_at = ?, published_at = ?
+			WHERE id = ?
+		`, t.Content, nullString(t.Text), t.Version, t.Status, now, now, t.ID); err != nil {
+			return fmt.Errorf("publish template: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sync(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Rollback restores the content of a prior version. The content is saved as
+// a new version and published, so history stays linear and auditable.
+func (s *Store) Rollback(ctx context.Context, slug string, version int) (*Template, error) {
+	v, err := s.GetVersion(ctx, slug, version)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s version %d", ErrNotFound, slug, version)
+	}
+
+	draft, err := s.SaveDraft(ctx, slug, v.Content, v.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Publish(ctx, slug, draft.Version)
+}
+
+// GetVersion returns a specific version of a template, or nil if it does
+// not exist.
+func (s *Store) GetVersion(ctx context.Context, slug string, version int) (*Version, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT v.version, v.content, COALESCE(v.text, ''), v.created_at, v.version = t.version AND t.status = ?
+		FROM template_versions v
+		JOIN templates t ON t.id = v.template_id
+		WHERE t.slug = ? AND v.version = ?
+	`, StatusPublished, slug, version)
+
+	var v Version
+	if err := row.Scan(&v.Version, &v.Content, &v.Text, &v.CreatedAt, &v.Live); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListVersions returns the version history of a template, newest first.
+func (s *Store) ListVersions(ctx context.Context, slug string) ([]*Version, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT v.version, v.content, COALESCE(v.text, ''), v.created_at, v.version = t.version AND t.status = ?
+		FROM template_versions v
+		JOIN templates t ON t.id = v.template_id
+		WHERE t.slug = ?
+		ORDER BY v.version DESC
+	`, StatusPublished, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*Version
+	for rows.Next() {
+		var v Version
+		if err := rows.Scan(&v.Version, &v.Content, &v.Text, &v.CreatedAt, &v.Live); err != nil {
+			return nil, err
+		}
+		versions = append(versions, &v)
+	}
+
+	return versions, rows.Err()
+}
+
+// RenderVersion renders a specific (possibly historical or draft) version
+// of a template with the given data, along with the text part of that
+// version.
+func (s *Store) RenderVersion(ctx context.Context, slug string, version int, data any) (*mjml.Result, error) {
+	if s.renderer == nil {
+		return nil, fmt.Errorf("template store has no renderer")
+	}
+
+	v, err := s.GetVersion(ctx, slug, version)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: %s version %d", ErrNotFound, slug, version)
+	}
+
+	html, err := s.renderer.RenderContent(slug, v.Content, data)
+	if err != nil {
+		return nil, err
+	}
+	return s.renderer.ResultForText(slug, html, v.Text, data)
+}
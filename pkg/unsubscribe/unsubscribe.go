@@ -0,0 +1,127 @@
+// Package unsubscribe signs the per-recipient unsubscribe and preference
+// links put in emails and records the opt-outs they receive in the
+// suppression list.
+package unsubscribe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed or not signed
+// with the links' secret.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Sources of an opt-out, recorded on its suppression.
+const (
+	SourceOneClick = "one-click" // a mail client's RFC 8058 POST
+	SourceLink     = "link"      // the recipient confirmed on the unsubscribe page
+)
+
+// Claims identify the recipient a link is for, the template category and
+// the email it was sent in.
+type Claims struct {
+	Address  string `json:"a"`
+	Category string `json:"c,omitempty"`
+	EmailID  string `json:"e,omitempty"`
+}
+
+// Links makes and verifies unsubscribe and preference links, signed with
+// HMAC-SHA256 so they cannot be forged for other addresses.
+type Links struct {
+	secret     []byte
+	baseURL    string
+	categories []string
+}
+
+// NewLinks creates links to the unsubscribe and preference pages served
+// under baseURL, the public URL of the UI server, for emails of the given
+// template categories, or every email when none are given.
+func NewLinks(secret, baseURL string, categories []string) *Links {
+	return &Links{
+		secret:     []byte(secret),
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		categories: categories,
+	}
+}
+
+// Applies reports whether emails of a template category get unsubscribe
+// links.
+func (l *Links) Applies(category string) bool {
+	return len(l.categories) == 0 || slices.Contains(l.categories, category)
+}
+
+// URL returns the unsubscribe link for c.
+func (l *Links) URL(c Claims) string {
+	return l.baseURL + "/unsubscribe?token=" + url.QueryEscape(l.Token(c))
+}
+
+// PreferencesURL returns the link to the preference page of the address
+// of c.
+func (l *Links) PreferencesURL(c Claims) string {
+	return l.baseURL + "/preferences?token=" + url.QueryEscape(l.Token(c))
+}
+
+// Token returns c signed: the base64url JSON claims and their signature,
+// separated by a dot.
+func (l *Links) Token(c Claims) string {
+	payload, _ := json.Marshal(c)
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + l.sign(encoded)
+}
+
+// Verify returns the claims of a token made by Token.
+func (l *Links) Verify(token string) (*Claims, error) {
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(l.sign(encoded))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.Address == "" {
+		return nil, ErrInvalidToken
+	}
+	return &c, nil
+}
+
+func (l *Links) sign(encoded string) string {
+	h := hmac.New(sha256.New, l.secret)
+	h.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Record suppresses the address of c for its template category, every
+// category when it has none, and records an unsubscribed event on the
+// email the link was sent in.
+func Record(ctx context.Context, q *queue.Queue, c *Claims, source string) error {
+	if err := q.Suppress(ctx, queue.Suppression{
+		Address:  c.Address,
+		Category: c.Category,
+		Reason:   queue.SuppressUnsubscribe,
+		Source:   source,
+		EmailID:  c.EmailID,
+	}); err != nil {
+		return fmt.Errorf("suppress %s: %w", c.Address, err)
+	}
+	if c.EmailID == "" {
+		return nil
+	}
+	return q.RecordEvent(ctx, c.EmailID, queue.EventUnsubscribed, map[string]any{
+		"recipient": c.Address,
+		"category":  c.Category,
+		"source":    source,
+	})
+}
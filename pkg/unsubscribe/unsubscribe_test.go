@@ -0,0 +1,83 @@
+package unsubscribe
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+func TestToken(t *testing.T) {
+	links := NewLinks("s3cret", "https://mail.example.com", []string{"marketing"})
+	c := Claims{Address: "reader@example.com", Category: "marketing", EmailID: "e1"}
+
+	token := links.Token(c)
+	got, err := links.Verify(token)
+	if err != nil || *got != c {
+		t.Fatalf("Expected %+v, got %+v, %v", c, got, err)
+	}
+	if url := links.URL(c); !strings.HasPrefix(url, "https://mail.example.com/unsubscribe?token=") {
+		t.Errorf("Unexpected URL %q", url)
+	}
+
+	// Another address with the same signature, and another secret
+	forged := links.Token(Claims{Address: "other@example.com"})
+	payload, _, _ := strings.Cut(forged, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	for _, bad := range []string{payload + "." + sig, "", "abc", token + "x"} {
+		if _, err := links.Verify(bad); err != ErrInvalidToken {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+	if _, err := NewLinks("other", "", nil).Verify(token); err != ErrInvalidToken {
+		t.Errorf("Expected a token signed with another secret to be rejected, got %v", err)
+	}
+
+	if !links.Applies("marketing") || links.Applies("transactional") || !NewLinks("s", "", nil).Applies("transactional") {
+		t.Error("Unexpected categories applied")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "news",
+		Recipients:   []string{"reader@example.com"},
+		Subject:      "News",
+		Priority:     queue.PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	c := &Claims{Address: "Reader@example.com", Category: "marketing", EmailID: id}
+	if err := Record(ctx, q, c, SourceOneClick); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+
+	suppressed, _ := q.Suppressed(ctx, []string{"reader@example.com"}, "marketing")
+	if s := suppressed["reader@example.com"]; s == nil || s.Reason != queue.SuppressUnsubscribe || s.Source != SourceOneClick || s.EmailID != id {
+		t.Errorf("Expected an unsubscribe suppression, got %+v", s)
+	}
+	if suppressed, _ := q.Suppressed(ctx, []string{"reader@example.com"}, "transactional"); len(suppressed) != 0 {
+		t.Errorf("Expected other categories to be sent to, got %+v", suppressed)
+	}
+
+	events, _ := q.Events(ctx, id)
+	if last := events[len(events)-1]; last.Type != queue.EventUnsubscribed || last.Details["source"] != SourceOneClick {
+		t.Errorf("Expected an unsubscribed event, got %+v", last)
+	}
+}
@@ -0,0 +1,446 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+	"github.com/zeromicro/go-zero/core/logx"
+	"maragu.dev/goqite"
+)
+
+var (
+	// ErrNotFound is returned when a delivery ID does not exist.
+	ErrNotFound = errors.New("webhook delivery not found")
+	// ErrInFlight is returned when replaying a delivery that is still
+	// pending or being retried.
+	ErrInFlight = errors.New("webhook delivery is still being sent")
+)
+
+// Config holds webhook delivery settings.
+type Config struct {
+	MaxAttempts int
+	Backoff     time.Duration // delay before the first retry, doubled after each
+	MaxBackoff  time.Duration
+	Timeout     time.Duration // per request
+}
+
+// DefaultConfig returns sensible defaults: six attempts over about a
+// quarter of an hour.
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts: 6,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  time.Hour,
+		Timeout:     10 * time.Second,
+	}
+}
+
+// Payload is the JSON body of a callback.
+type Payload struct {
+	ID        string         `json:"id"` // event ID
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	Email     PayloadEmail   `json:"email"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// PayloadEmail describes the email an event is about.
+type PayloadEmail struct {
+	ID         string   `json:"id"`
+	Status     string   `json:"status"`
+	Template   string   `json:"template"`
+	Subject    string   `json:"subject"`
+	Recipients []string `json:"recipients"`
+	Tenant     string   `json:"tenant,omitempty"`
+}
+
+// Delivery is a callback of one event to one endpoint, with the outcome of
+// its last attempt.
+type Delivery struct {
+	ID           string          `json:"id"`
+	Webhook      string          `json:"webhook"`
+	URL          string          `json:"url"`
+	EventID      string          `json:"event_id"`
+	EventType    string          `json:"event_type"`
+	EmailID      string          `json:"email_id"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"` // pending, retry, delivered or failed
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	DeliveredAt  *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// DeliveryFilter selects deliveries from the log. Empty fields match
+// everything.
+type DeliveryFilter struct {
+	Webhook string
+	Status  string
+	EmailID string
+	Limit   int
+}
+
+// Dispatcher sends the events recorded by an email queue to the endpoints
+// subscribed to them. Each callback is logged in webhook_deliveries and sent
+// through its own goqite queue, so retries survive restarts.
+type Dispatcher struct {
+	db        *sql.DB
+	emails    *queue.Queue
+	queue     *goqite.Queue
+	endpoints map[string]*Endpoint
+	names     []string
+	client    *http.Client
+	config    Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the events of emails. The goqite
+// table must be created by db.Migrate() before calling this.
+func NewDispatcher(db *sql.DB, emails *queue.Queue, endpoints []*Endpoint, cfg Config) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Dispatcher{
+		db:     db,
+		emails: emails,
+		queue: goqite.New(goqite.NewOpts{
+			DB:   db,
+			Name: "webhooks",
+			// Each attempt receives the message once. process enforces
+			// MaxAttempts, so goqite must not give up first; the spare
+			// receives cover attempts cut short by a restart.
+			MaxReceive: max(cfg.MaxAttempts, 1) + 3,
+			SQLFlavor:  goqite.SQLFlavorSQLite,
+		}),
+		endpoints: make(map[string]*Endpoint, len(endpoints)),
+		client:    &http.Client{Timeout: cfg.Timeout},
+		config:    cfg,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+	for _, e := range endpoints {
+		if _, ok := d.endpoints[e.Name]; !ok {
+			d.names = append(d.names, e.Name)
+		}
+		d.endpoints[e.Name] = e
+	}
+	emails.OnEvent(d.dispatch)
+	return d
+}
+
+// Endpoints returns the configured endpoints.
+func (d *Dispatcher) Endpoints() []*Endpoint {
+	endpoints := make([]*Endpoint, 0, len(d.names))
+	for _, name := range d.names {
+		endpoints = append(endpoints, d.endpoints[name])
+	}
+	return endpoints
+}
+
+// Start starts the worker that sends callbacks.
+func (d *Dispatcher) Start() {
+	logx.Infow("Webhook dispatcher started", logx.Field("endpoints", len(d.names)))
+	d.wg.Add(1)
+	go d.worker()
+}
+
+// Stop stops the worker, waiting for a callback in flight.
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+	logx.Info("Webhook dispatcher stopped")
+}
+
+// dispatch logs and queues a callback of event to each endpoint subscribed
+// to it.
+func (d *Dispatcher) dispatch(ctx context.Context, event *queue.Event) {
+	var body []byte
+	for _, name := range d.names {
+		endpoint := d.endpoints[name]
+		if !endpoint.wants(event.Type) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = d.payload(ctx, event); err != nil {
+				logx.Errorf("webhook payload for event %s: %v", event.ID, err)
+				return
+			}
+		}
+
+		id := uuid.New().String()
+		if _, err := d.db.ExecContext(ctx, `
+			INSERT INTO webhook_deliveries (id, webhook, url, event_id, event_type, email_id, payload)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, id, endpoint.Name, endpoint.URL, event.ID, event.Type, event.EmailID, string(body)); err != nil {
+			logx.Errorf("log webhook delivery: %v", err)
+			continue
+		}
+		if err := d.queue.Send(ctx, goqite.Message{Body: []byte(id)}); err != nil {
+			logx.Errorf("queue webhook delivery %s: %v", id, err)
+		}
+	}
+}
+
+// payload builds the callback body for an event.
+func (d *Dispatcher) payload(ctx context.Context, event *queue.Event) ([]byte, error) {
+	p := Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		Timestamp: event.Timestamp,
+		Email:     PayloadEmail{ID: event.EmailID},
+		Details:   event.Details,
+	}
+	job, err := d.emails.GetStatus(ctx, event.EmailID)
+	if err != nil {
+		return nil, err
+	}
+	if job != nil {
+		p.Email = PayloadEmail{
+			ID:         job.ID,
+			Status:     job.Status,
+			Template:   job.TemplateSlug,
+			Subject:    job.Subject,
+			Recipients: job.Recipients,
+			Tenant:     job.Tenant,
+		}
+	}
+	return json.Marshal(p)
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		default:
+			msg, err := d.queue.Receive(d.ctx)
+			if err != nil || msg == nil {
+				time.Sleep(time.Second)
+				continue
+			}
+			d.process(msg)
+		}
+	}
+}
+
+// process makes one attempt at the delivery in msg, and schedules a retry
+// with backoff when it fails and attempts remain.
+func (d *Dispatcher) process(msg *goqite.Message) {
+	ctx := d.ctx
+
+	delivery, err := d.GetDelivery(ctx, string(msg.Body))
+	if err != nil || delivery == nil || delivery.Status == "delivered" || delivery.Status == "failed" {
+		d.queue.Delete(ctx, msg.ID)
+		return
+	}
+
+	code, sendErr := d.send(ctx, delivery)
+	attempts := delivery.Attempts + 1
+	status := "delivered"
+	switch {
+	case sendErr != nil && attempts >= d.config.MaxAttempts:
+		status = "failed"
+	case sendErr != nil:
+		status = "retry"
+	}
+
+	var errStr sql.NullString
+	if sendErr != nil {
+		errStr = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+	if _, err := d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_code = ?, error = ?,
+		    delivered_at = CASE WHEN ? = 'delivered' THEN CURRENT_TIMESTAMP END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, status, attempts, sql.NullInt64{Int64: int64(code), Valid: code > 0}, errStr, status, delivery.ID); err != nil {
+		logx.Errorf("update webhook delivery %s: %v", delivery.ID, err)
+	}
+
+	if status == "retry" {
+		backoff := d.backoff(attempts)
+		d.queue.Extend(ctx, msg.ID, backoff)
+		logx.Infow("Webhook delivery retrying",
+			logx.Field("id", delivery.ID),
+			logx.Field("webhook", delivery.Webhook),
+			logx.Field("attempt", attempts),
+			logx.Field("backoff", backoff.String()),
+			logx.Field("error", sendErr.Error()),
+		)
+		return
+	}
+	if status == "failed" {
+		logx.Errorw("Webhook delivery failed",
+			logx.Field("id", delivery.ID),
+			logx.Field("webhook", delivery.Webhook),
+			logx.Field("attempts", attempts),
+			logx.Field("error", sendErr.Error()),
+		)
+	}
+	d.queue.Delete(ctx, msg.ID)
+}
+
+// send posts a delivery's payload, signed with its endpoint's secret, and
+// returns the response status code. Any non-2xx response is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery *Delivery) (int, error) {
+	endpoint, ok := d.endpoints[delivery.Webhook]
+	if !ok {
+		return 0, fmt.Errorf("webhook %q is no longer configured", delivery.Webhook)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "plat-mjml-webhooks")
+	req.Header.Set(HeaderID, delivery.ID)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	backoff := d.config.Backoff * time.Duration(math.Pow(2, float64(attempts-1)))
+	if backoff > d.config.MaxBackoff {
+		return d.config.MaxBackoff
+	}
+	return backoff
+}
+
+// Replay sends a delivered or failed delivery again as a new attempt
+// series and returns it. Deliveries still pending or being retried already
+// have a queued message, so replaying them fails with ErrInFlight.
+func (d *Dispatcher) Replay(ctx context.Context, id string) (*Delivery, error) {
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, error = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('delivered', 'failed')
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		delivery, err := d.GetDelivery(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if delivery == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: %s is %s", ErrInFlight, id, delivery.Status)
+	}
+	if err := d.queue.Send(ctx, goqite.Message{Body: []byte(id)}); err != nil {
+		return nil, fmt.Errorf("queue webhook delivery: %w", err)
+	}
+	return d.GetDelivery(ctx, id)
+}
+
+// deliveryColumns lists the webhook_deliveries columns read by scanDelivery.
+const deliveryColumns = `id, webhook, url, event_id, event_type, email_id, payload, status,
+		       attempts, response_code, error, created_at, delivered_at`
+
+// GetDelivery returns a delivery by ID, or nil when it does not exist.
+func (d *Dispatcher) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	row := d.db.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id)
+	delivery, err := scanDelivery(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return delivery, err
+}
+
+// Deliveries returns the deliveries matching filter, most recent first.
+func (d *Dispatcher) Deliveries(ctx context.Context, filter DeliveryFilter) ([]*Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries`
+	var conds []string
+	var args []any
+	for _, f := range []struct{ col, value string }{
+		{"webhook", filter.Webhook},
+		{"status", filter.Status},
+		{"email_id", filter.EmailID},
+	} {
+		if f.value != "" {
+			conds = append(conds, f.col+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY created_at DESC, rowid DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDelivery(row scanner) (*Delivery, error) {
+	var d Delivery
+	var payload string
+	var code sql.NullInt64
+	var errStr sql.NullString
+	var deliveredAt sql.NullTime
+	if err := row.Scan(&d.ID, &d.Webhook, &d.URL, &d.EventID, &d.EventType, &d.EmailID, &payload, &d.Status,
+		&d.Attempts, &code, &errStr, &d.CreatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	d.ResponseCode = int(code.Int64)
+	d.Error = errStr.String
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return &d, nil
+}
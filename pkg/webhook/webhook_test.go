@@ -0,0 +1,206 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joeblew999/plat-mjml/pkg/db"
+	"github.com/joeblew999/plat-mjml/pkg/queue"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"type":"sent"}`)
+	sig := Sign("s3cret", time.Now(), body)
+
+	if err := Verify("s3cret", sig, body, 5*time.Minute); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+	if err := Verify("other", sig, body, 5*time.Minute); err == nil {
+		t.Error("Expected a wrong secret to fail")
+	}
+	if err := Verify("s3cret", sig, []byte(`{"type":"failed"}`), 5*time.Minute); err == nil {
+		t.Error("Expected a changed body to fail")
+	}
+	old := Sign("s3cret", time.Now().Add(-time.Hour), body)
+	if err := Verify("s3cret", old, body, 5*time.Minute); err == nil {
+		t.Error("Expected an old signature to fail")
+	}
+	if err := Verify("s3cret", "v1=abc", body, 0); err == nil {
+		t.Error("Expected a malformed header to fail")
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	var received []*http.Request
+	var bodies [][]byte
+	crm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+	}))
+	defer crm.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 2
+	d := NewDispatcher(database.DB, q, []*Endpoint{
+		{Name: "crm", URL: crm.URL, Secret: "s3cret", Events: []string{queue.EventSent}},
+		{Name: "audit", URL: down.URL, Secret: "other"},
+	}, cfg)
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	q.RecordEvent(ctx, id, queue.EventSent, map[string]any{"provider": "ses"})
+
+	// audit gets both events, crm only the sent one
+	deliveries, err := d.Deliveries(ctx, DeliveryFilter{EmailID: id})
+	if err != nil || len(deliveries) != 3 {
+		t.Fatalf("Expected 3 deliveries, got %d, %v", len(deliveries), err)
+	}
+
+	process := func() {
+		for {
+			msg, err := d.queue.Receive(ctx)
+			if err != nil {
+				t.Fatalf("Receive failed: %v", err)
+			}
+			if msg == nil {
+				return
+			}
+			d.process(msg)
+			d.queue.Extend(ctx, msg.ID, 0)
+		}
+	}
+	process()
+
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 callback to crm, got %d", len(received))
+	}
+	r := received[0]
+	if err := Verify("s3cret", r.Header.Get(HeaderSignature), bodies[0], time.Minute); err != nil {
+		t.Errorf("Callback signature did not verify: %v", err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("Invalid payload: %v", err)
+	}
+	if payload.Type != queue.EventSent || payload.Email.ID != id || payload.Email.Template != "welcome" || payload.Details["provider"] != "ses" {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+	if r.Header.Get(HeaderEvent) != queue.EventSent || r.Header.Get(HeaderID) == "" {
+		t.Errorf("Unexpected headers %v", r.Header)
+	}
+
+	failed, _ := d.Deliveries(ctx, DeliveryFilter{Webhook: "audit", Status: "failed"})
+	if len(failed) != 2 || failed[0].Attempts != 2 || failed[0].ResponseCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 2 failed audit deliveries after 2 attempts, got %+v", failed)
+	}
+
+	// Replay sends a delivery again
+	delivered, _ := d.Deliveries(ctx, DeliveryFilter{Webhook: "crm"})
+	if delivered[0].Status != "delivered" || delivered[0].DeliveredAt == nil {
+		t.Errorf("Expected crm delivery to be delivered, got %+v", delivered[0])
+	}
+	if _, err := d.Replay(ctx, delivered[0].ID); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	process()
+	if len(received) != 2 || received[1].Header.Get(HeaderID) != r.Header.Get(HeaderID) {
+		t.Errorf("Expected the replayed delivery to be sent again, got %d callbacks", len(received))
+	}
+	if _, err := d.Replay(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound replaying a missing delivery, got %v", err)
+	}
+
+	// A delivery still queued is not replayed, so it is not sent twice
+	q.RecordEvent(ctx, id, queue.EventSent, nil)
+	pending, _ := d.Deliveries(ctx, DeliveryFilter{Webhook: "crm", Status: "pending"})
+	if len(pending) != 1 {
+		t.Fatalf("Expected a pending crm delivery, got %d", len(pending))
+	}
+	if _, err := d.Replay(ctx, pending[0].ID); !errors.Is(err, ErrInFlight) {
+		t.Errorf("Expected ErrInFlight replaying a pending delivery, got %v", err)
+	}
+}
+
+func TestDispatcherDefaultAttempts(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	q, err := queue.NewQueue(database.DB, "emails", 1)
+	if err != nil {
+		t.Fatalf("Failed to create queue: %v", err)
+	}
+
+	calls := 0
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	// Every attempt the default config allows is made, and the delivery
+	// then fails rather than being stranded by goqite's receive limit
+	cfg := DefaultConfig()
+	d := NewDispatcher(database.DB, q, []*Endpoint{{Name: "audit", URL: down.URL, Secret: "s3cret"}}, cfg)
+	ctx := context.Background()
+
+	id, err := q.Enqueue(ctx, queue.EmailJob{
+		TemplateSlug: "welcome",
+		Recipients:   []string{"a@example.com"},
+		Subject:      "Hello",
+		Priority:     queue.PriorityNormal,
+	})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	for {
+		msg, err := d.queue.Receive(ctx)
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if msg == nil {
+			break
+		}
+		d.process(msg)
+		d.queue.Extend(ctx, msg.ID, 0)
+	}
+
+	deliveries, _ := d.Deliveries(ctx, DeliveryFilter{EmailID: id})
+	if len(deliveries) != 1 || deliveries[0].Status != "failed" || deliveries[0].Attempts != cfg.MaxAttempts {
+		t.Fatalf("Expected a failed delivery after %d attempts, got %+v", cfg.MaxAttempts, deliveries)
+	}
+	if calls != cfg.MaxAttempts {
+		t.Errorf("Expected %d callbacks, got %d", cfg.MaxAttempts, calls)
+	}
+}